@@ -0,0 +1,121 @@
+// Command backup exports every config in a store to a JSON backup, or imports one to replace
+// them, so configs can be moved between devices or checked into a team's repo. The vision
+// server must be stopped first, since the store can only be opened by one process at a time.
+//
+//	backup [-db store.db] export [file]
+//	backup [-db store.db] import [file]
+//
+// The backup is written to or read from stdout or stdin if the file isn't given.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/gloworm-vision/gloworm-app/store"
+	"go.etcd.io/bbolt"
+)
+
+func main() {
+	db := flag.String("db", "store.db", "store to export from or import to")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-db store.db] export|import [file]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 || flag.NArg() > 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*db, flag.Arg(0), flag.Arg(1)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(db, command, file string) error {
+	var do func(s store.Store) error
+	switch command {
+	case "export":
+		do = func(s store.Store) error { return export(s, file) }
+	case "import":
+		do = func(s store.Store) error { return restore(s, file) }
+	default:
+		return fmt.Errorf("unknown command %q, expected export or import", command)
+	}
+
+	// the server holds the store open, so fail rather than wait for it
+	s, err := store.OpenBBolt(db, 0666, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return fmt.Errorf("unable to open store (is the vision server running?): %w", err)
+	}
+	defer s.Close()
+
+	return do(s)
+}
+
+// export writes every config in the store to the file, or stdout if it's empty.
+func export(s store.Store, file string) error {
+	backup, err := s.Export()
+	if err != nil {
+		return err
+	}
+
+	if file == "" {
+		return writeBackup(os.Stdout, backup)
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("unable to create backup file: %w", err)
+	}
+
+	err = writeBackup(f, backup)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("unable to close backup file: %w", closeErr)
+	}
+
+	return err
+}
+
+// writeBackup writes the backup as indented JSON, so it diffs well when checked in.
+func writeBackup(w io.Writer, backup store.Backup) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(backup); err != nil {
+		return fmt.Errorf("unable to write backup: %w", err)
+	}
+
+	return nil
+}
+
+// restore replaces every config in the store with those in the backup in the file, or stdin
+// if it's empty.
+func restore(s store.Store, file string) error {
+	var r io.Reader = os.Stdin
+	if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return fmt.Errorf("unable to open backup file: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var backup store.Backup
+	if err := json.NewDecoder(r).Decode(&backup); err != nil {
+		return fmt.Errorf("unable to read backup: %w", err)
+	}
+
+	if err := backup.Validate(); err != nil {
+		return fmt.Errorf("invalid backup: %w", err)
+	}
+
+	return s.Import(backup)
+}
@@ -2,28 +2,208 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/gloworm-vision/gloworm-app/network"
 	"github.com/gloworm-vision/gloworm-app/server"
+	"github.com/gloworm-vision/gloworm-app/source"
 	"github.com/gloworm-vision/gloworm-app/store"
+	"github.com/gloworm-vision/gloworm-app/web"
 	"github.com/sirupsen/logrus"
-	"gocv.io/x/gocv"
 )
 
 func main() {
-	webcam, err := gocv.OpenVideoCapture(0)
+	camera := flag.Int("camera", 0, "camera device ID to capture from")
+	cameras := flag.String("cameras", "", "comma separated id=device ID pairs of additional cameras to capture from, such as left=1,right=2")
+	stereo := flag.String("stereo", "", "comma separated IDs of the left and right -cameras to read together as a stereo pair, if set")
+	video := flag.String("video", "", "video file to read frames from instead of a camera")
+	images := flag.String("images", "", "directory of still images to read frames from instead of a camera")
+	fps := flag.Float64("fps", 30, "frame rate to read video files and images at")
+	memoryStore := flag.Bool("memory-store", false, "keep configs in memory instead of store.db, discarding them on exit, such as for demos")
+	storeFile := flag.String("store-file", "", "keep configs in this JSON file instead of store.db, so they can be edited offline and diffed, if set")
+	snapshots := flag.String("snapshots", "snapshots", "directory to save snapshots of raw and processed frames to")
+	maxSnapshots := flag.Int64("snapshots-max-mb", 1024, "total size in MiB snapshots are kept under, deleting the oldest first")
+	token := flag.String("token", "", "bearer token required to change configs, if set")
+	username := flag.String("username", "", "basic auth username required to change configs, if set")
+	password := flag.String("password", "", "basic auth password required to change configs, if set")
+	origins := flag.String("origins", "", "comma separated origins allowed to make cross-origin requests, or * for any")
+	limelight := flag.Bool("limelight", false, "also publish results to networktables like a Limelight")
+	pipelineEntry := flag.String("pipeline-entry", "/gloworm/pipeline", "networktables entry robot code writes the name or index of the pipeline to switch to, if set")
+	photonCamera := flag.String("photon-camera", "", "camera name to also publish results to networktables like PhotonVision, if set")
+	udp := flag.String("udp", "", "address to also send each result to as a UDP datagram, if set")
+	udpFormat := flag.String("udp-format", server.UDPBinary, "format of UDP results, binary or json")
+	resultHistory := flag.Int("result-history", 4500, "number of recent results kept and served at /results/history")
+	maxStreamClients := flag.Int("max-stream-clients", 8, "most clients that can watch streams at once, or 0 for no limit")
+	recordings := flag.String("recordings", "recordings", "directory to save recordings of the raw and processed streams to")
+	backups := flag.String("backups", "", "directory to periodically save copies of the store to, if set")
+	backupInterval := flag.Duration("backup-interval", time.Hour, "how often the store is copied to -backups, if it changed")
+	maxBackups := flag.Int("backups-max", 24, "number of store copies kept in -backups, deleting the oldest first")
+	recordMatches := flag.Bool("record-matches", false, "record the raw and processed streams while the FMS enables the robot")
+	profiling := flag.Bool("profile", false, "profile how long each pipeline stage takes, served at /profile")
+	debugEndpoints := flag.Bool("debug-endpoints", false, "serve runtime profiles at /debug/pprof and runtime vars at /debug/vars, requiring credentials if set")
+	workers := flag.Int("workers", 1, "number of frames to process in parallel, on devices with multiple cores")
+	showFPS := flag.Bool("show-fps", false, "draw the capture, processed and stream frame rates onto the main stream")
+	tlsCert := flag.String("tls-cert", "", "PEM certificate to serve HTTPS with, if set along with -tls-key")
+	tlsKey := flag.String("tls-key", "", "PEM key to serve HTTPS with, if set along with -tls-cert")
+	selfSigned := flag.Bool("tls-self-signed", false, "generate a self-signed certificate at -tls-cert and -tls-key if they don't exist")
+	httpRedirect := flag.String("http-redirect", "", "address to redirect plain HTTP requests to HTTPS from, such as :80, if TLS is enabled")
+	manageNetwork := flag.Bool("manage-network", false, "allow the network interface and hostname to be configured at /network, on Raspberry Pi OS")
+	networkInterface := flag.String("network-interface", "eth0", "network interface configured at /network")
+	flag.Parse()
+
+	capture, err := openSource(*camera, *video, *images, *fps)
+	if err != nil {
+		panic(err)
+	}
+
+	cameraDevices, err := parseCameras(*cameras)
+	if err != nil {
+		panic(err)
+	}
+
+	extraCameras := make(map[string]source.FrameSource, len(cameraDevices))
+	for id, device := range cameraDevices {
+		c, err := source.OpenCamera(device)
+		if err != nil {
+			panic(fmt.Errorf("unable to open camera %q: %w", id, err))
+		}
+
+		extraCameras[id] = c
+	}
+
+	var stereoCameras *server.StereoPair
+	if *stereo != "" {
+		ids := strings.Split(*stereo, ",")
+		if len(ids) != 2 {
+			panic(fmt.Errorf("stereo cameras %q must be a left,right pair", *stereo))
+		}
+
+		stereoCameras = &server.StereoPair{Left: ids[0], Right: ids[1]}
+	}
+
+	var db store.Store = store.NewMemory()
+	switch {
+	case *storeFile != "":
+		db, err = store.OpenFile(*storeFile, 0644)
+	case !*memoryStore:
+		db, err = store.OpenBBolt("store.db", 0666, nil)
+	}
 	if err != nil {
 		panic(err)
 	}
-	defer webcam.Close()
 
-	store, err := store.OpenBBolt("store.db", 0666, nil)
+	ui, err := web.UI()
 	if err != nil {
 		panic(err)
 	}
 
-	server := server.Server{Addr: ":8080", Store: store, Capture: webcam, Logger: logrus.New()}
+	auth := &server.Auth{Token: *token, Username: *username, Password: *password}
 
+	server := server.Server{
+		Addr:             ":8080",
+		Store:            db,
+		Capture:          capture,
+		Cameras:          extraCameras,
+		StereoCameras:    stereoCameras,
+		Logger:           logrus.New(),
+		SnapshotDir:      *snapshots,
+		MaxSnapshotSize:  *maxSnapshots << 20,
+		RecordingDir:     *recordings,
+		RecordMatches:    *recordMatches,
+		BackupDir:        *backups,
+		BackupInterval:   *backupInterval,
+		MaxBackups:       *maxBackups,
+		MaxStreamClients: *maxStreamClients,
+		ResultHistory:    *resultHistory,
+		Profiling:        *profiling,
+		DebugEndpoints:   *debugEndpoints,
+		Workers:          *workers,
+		ShowFPS:          *showFPS,
+		UI:               ui,
+		Auth:             auth,
+		NTLimelight:      *limelight,
+		NTPhotonCamera:   *photonCamera,
+		NTPipelineEntry:  *pipelineEntry,
+		UDPAddr:          *udp,
+		UDPFormat:        *udpFormat,
+		TLSCert:          *tlsCert,
+		TLSKey:           *tlsKey,
+		SelfSignedTLS:    *selfSigned,
+		HTTPRedirectAddr: *httpRedirect,
+	}
+	if *manageNetwork {
+		server.Network = &network.Manager{Interface: *networkInterface}
+	}
+	if *origins != "" {
+		server.AllowedOrigins = strings.Split(*origins, ",")
+	}
+	if *video == "" && *images == "" {
+		server.OpenCapture = func() (source.FrameSource, error) {
+			return source.OpenCamera(*camera)
+		}
+	}
+	server.OpenCamera = func(id string) (source.FrameSource, error) {
+		return source.OpenCamera(cameraDevices[id])
+	}
 	if err := server.Run(context.Background()); err != nil {
 		panic(err)
 	}
 }
+
+// parseCameras parses comma separated id=device ID pairs into device IDs by camera ID.
+func parseCameras(cameras string) (map[string]int, error) {
+	devices := make(map[string]int)
+	if cameras == "" {
+		return devices, nil
+	}
+
+	for _, pair := range strings.Split(cameras, ",") {
+		id, device := pair, ""
+		if i := strings.Index(pair, "="); i >= 0 {
+			id, device = pair[:i], pair[i+1:]
+		}
+
+		n, err := strconv.Atoi(device)
+		if id == "" || err != nil {
+			return nil, fmt.Errorf("camera %q must be an id=device ID pair", pair)
+		}
+
+		if _, ok := devices[id]; ok {
+			return nil, fmt.Errorf("camera %q must not be repeated", id)
+		}
+		devices[id] = n
+	}
+
+	return devices, nil
+}
+
+// openSource opens a video file or image directory if one is given, otherwise the camera.
+func openSource(camera int, video, images string, fps float64) (source.FrameSource, error) {
+	switch {
+	case video != "":
+		v, err := source.OpenVideo(video, true)
+		if err != nil {
+			return nil, err
+		}
+
+		return source.Throttle(v, fps), nil
+	case images != "":
+		i, err := source.OpenImages(images, true)
+		if err != nil {
+			return nil, err
+		}
+
+		return source.Throttle(i, fps), nil
+	default:
+		c, err := source.OpenCamera(camera)
+		if err != nil {
+			return nil, err
+		}
+
+		return c, nil
+	}
+}
@@ -35,12 +35,12 @@ const (
 )
 
 func (g *Gloworm) SetLights(on bool) error {
-	if err := g.gpio.Write(glowormLeftCluster, gpio.High); err != nil {
-		return fmt.Errorf("can't turn on left LED cluster: %w", err)
+	if err := g.gpio.Write(glowormLeftCluster, gpio.Level(on)); err != nil {
+		return fmt.Errorf("can't set left LED cluster: %w", err)
 	}
 
-	if err := g.gpio.Write(glowormRightCluster, gpio.High); err != nil {
-		return fmt.Errorf("can't turn on right LED cluster: %w", err)
+	if err := g.gpio.Write(glowormRightCluster, gpio.Level(on)); err != nil {
+		return fmt.Errorf("can't set right LED cluster: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,340 @@
+// Package network reads and configures the device's network interface and hostname, so the
+// static 10.TE.AM.x address required at competitions can be set from the UI. Addresses are
+// configured with dhcpcd, as on Raspberry Pi OS, and the hostname with hostnamectl.
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// commandTimeout is how long commands that apply the config have to run.
+const commandTimeout = 10 * time.Second
+
+const (
+	defaultDHCPCDPath = "/etc/dhcpcd.conf"
+	defaultInterface  = "eth0"
+)
+
+// Mode is how the interface gets its address.
+type Mode string
+
+const (
+	DHCP   Mode = "dhcp"
+	Static Mode = "static"
+)
+
+// hostnamePattern matches a single DNS label.
+var hostnamePattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+// ValidHostname returns whether the hostname is a single DNS label, which is what device
+// hostnames are.
+func ValidHostname(hostname string) bool {
+	return hostnamePattern.MatchString(hostname)
+}
+
+// Config is the configuration of the device's network interface.
+type Config struct {
+	Interface string `json:"interface"`
+	Mode      Mode   `json:"mode"`
+
+	// Address is the static address with its prefix length, such as 10.12.34.11/24, and
+	// Gateway and DNS are the static router and name servers. They're only used by the
+	// static mode.
+	Address string   `json:"address,omitempty"`
+	Gateway string   `json:"gateway,omitempty"`
+	DNS     []string `json:"dns,omitempty"`
+
+	Hostname string `json:"hostname"`
+}
+
+// Validate returns an error if the config can't be applied.
+func (c Config) Validate() error {
+	if c.Interface == "" || strings.ContainsAny(c.Interface, " \t\n/") {
+		return fmt.Errorf("invalid interface %q", c.Interface)
+	}
+
+	if !ValidHostname(c.Hostname) {
+		return fmt.Errorf("invalid hostname %q", c.Hostname)
+	}
+
+	switch c.Mode {
+	case DHCP:
+		return nil
+	case Static:
+	default:
+		return fmt.Errorf("unknown mode %q", c.Mode)
+	}
+
+	ip, _, err := net.ParseCIDR(c.Address)
+	if err != nil || ip.To4() == nil {
+		return fmt.Errorf("invalid address %q, expected an IPv4 address with a prefix length such as 10.12.34.11/24", c.Address)
+	}
+
+	if c.Gateway != "" && net.ParseIP(c.Gateway) == nil {
+		return fmt.Errorf("invalid gateway %q", c.Gateway)
+	}
+
+	for _, dns := range c.DNS {
+		if net.ParseIP(dns) == nil {
+			return fmt.Errorf("invalid dns server %q", dns)
+		}
+	}
+
+	return nil
+}
+
+// Status is the current state of the device's network interface, which may differ from its
+// config until it's applied (or while waiting for a DHCP lease).
+type Status struct {
+	Interface string   `json:"interface"`
+	Up        bool     `json:"up"`
+	Addresses []string `json:"addresses"`
+	Hostname  string   `json:"hostname"`
+}
+
+// Manager reads and applies the network config.
+type Manager struct {
+	// DHCPCDPath is the dhcpcd config file. It defaults to /etc/dhcpcd.conf.
+	DHCPCDPath string
+
+	// Interface is the interface configured when a config doesn't name one. It defaults
+	// to eth0.
+	Interface string
+}
+
+func (m *Manager) dhcpcdPath() string {
+	if m.DHCPCDPath == "" {
+		return defaultDHCPCDPath
+	}
+
+	return m.DHCPCDPath
+}
+
+func (m *Manager) defaultInterface() string {
+	if m.Interface == "" {
+		return defaultInterface
+	}
+
+	return m.Interface
+}
+
+// Config reads the config of the interface, or the default interface if it's empty.
+func (m *Manager) Config(iface string) (Config, error) {
+	if iface == "" {
+		iface = m.defaultInterface()
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return Config{}, fmt.Errorf("unable to get hostname: %w", err)
+	}
+
+	config := Config{Interface: iface, Mode: DHCP, Hostname: hostname}
+
+	data, err := ioutil.ReadFile(m.dhcpcdPath())
+	if err != nil {
+		return Config{}, fmt.Errorf("unable to read dhcpcd config: %w", err)
+	}
+
+	_, block, _ := splitInterfaceBlock(data, iface)
+	for _, line := range block {
+		key, value, ok := staticOption(line)
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "ip_address":
+			config.Mode, config.Address = Static, value
+		case "routers":
+			config.Gateway = value
+		case "domain_name_servers":
+			config.DNS = strings.Fields(value)
+		}
+	}
+
+	return config, nil
+}
+
+// Status reads the current state of the interface, or the default interface if it's empty.
+func (m *Manager) Status(iface string) (Status, error) {
+	if iface == "" {
+		iface = m.defaultInterface()
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return Status{}, fmt.Errorf("unable to get hostname: %w", err)
+	}
+
+	i, err := net.InterfaceByName(iface)
+	if err != nil {
+		return Status{}, fmt.Errorf("unable to get interface %q: %w", iface, err)
+	}
+
+	addrs, err := i.Addrs()
+	if err != nil {
+		return Status{}, fmt.Errorf("unable to get addresses of %q: %w", iface, err)
+	}
+
+	status := Status{Interface: iface, Up: i.Flags&net.FlagUp != 0, Addresses: []string{}, Hostname: hostname}
+	for _, addr := range addrs {
+		status.Addresses = append(status.Addresses, addr.String())
+	}
+
+	return status, nil
+}
+
+// Apply writes the config to dhcpcd's config, sets the hostname and restarts dhcpcd so the
+// config takes effect. Other interfaces' configs are left as they are.
+func (m *Manager) Apply(ctx context.Context, config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	path := m.dhcpcdPath()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read dhcpcd config: %w", err)
+	}
+
+	before, _, after := splitInterfaceBlock(data, config.Interface)
+
+	var buf bytes.Buffer
+	for _, line := range append(before, after...) {
+		buf.WriteString(line + "\n")
+	}
+	if config.Mode == Static {
+		if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n\n")) {
+			buf.WriteString("\n")
+		}
+		writeStaticBlock(&buf, config)
+	}
+
+	if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+		return fmt.Errorf("unable to write dhcpcd config: %w", err)
+	}
+
+	if err := m.SetHostname(ctx, config.Hostname); err != nil {
+		return err
+	}
+
+	if err := run(ctx, "systemctl", "restart", "dhcpcd"); err != nil {
+		return fmt.Errorf("unable to restart dhcpcd: %w", err)
+	}
+
+	return nil
+}
+
+// SetHostname sets the device's hostname.
+func (m *Manager) SetHostname(ctx context.Context, hostname string) error {
+	if !ValidHostname(hostname) {
+		return fmt.Errorf("invalid hostname %q", hostname)
+	}
+
+	if err := run(ctx, "hostnamectl", "set-hostname", hostname); err != nil {
+		return fmt.Errorf("unable to set hostname: %w", err)
+	}
+
+	return nil
+}
+
+// splitInterfaceBlock splits dhcpcd's config into the lines before the interface's block,
+// the lines of the block (without its interface line), and the lines after it. A block runs
+// until the next interface or profile block.
+func splitInterfaceBlock(data []byte, iface string) (before, block, after []string) {
+	const (
+		beforeBlock = iota
+		inBlock
+		afterBlock
+	)
+
+	state := beforeBlock
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		starts := len(fields) > 0 && (fields[0] == "interface" || fields[0] == "profile")
+
+		switch {
+		case state == beforeBlock && starts && len(fields) == 2 && fields[0] == "interface" && fields[1] == iface:
+			state = inBlock
+			continue
+		case state == inBlock && starts:
+			state = afterBlock
+		}
+
+		switch state {
+		case beforeBlock:
+			before = append(before, line)
+		case inBlock:
+			block = append(block, line)
+		case afterBlock:
+			after = append(after, line)
+		}
+	}
+
+	return before, block, after
+}
+
+// staticOption parses a "static key=value" line of dhcpcd's config.
+func staticOption(line string) (key, value string, ok bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "static ") {
+		return "", "", false
+	}
+
+	parts := strings.SplitN(strings.TrimSpace(strings.TrimPrefix(line, "static ")), "=", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+
+	return parts[0], strings.TrimSpace(parts[1]), true
+}
+
+func writeStaticBlock(buf *bytes.Buffer, config Config) {
+	fmt.Fprintf(buf, "interface %s\n", config.Interface)
+	fmt.Fprintf(buf, "static ip_address=%s\n", config.Address)
+	if config.Gateway != "" {
+		fmt.Fprintf(buf, "static routers=%s\n", config.Gateway)
+	}
+	if len(config.DNS) > 0 {
+		fmt.Fprintf(buf, "static domain_name_servers=%s\n", strings.Join(config.DNS, " "))
+	}
+}
+
+// writeFileAtomic writes the file through a temporary file, so it isn't left half written
+// if the device loses power.
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode()
+	}
+
+	if err := ioutil.WriteFile(path+".tmp", data, mode); err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+func run(ctx context.Context, name string, args ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	if out, err := exec.CommandContext(ctx, name, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w: %s", name, err, bytes.TrimSpace(out))
+	}
+
+	return nil
+}
@@ -393,7 +393,7 @@ func (c *Client) handleResponse() error {
 		}
 
 		if c.Logger != nil {
-			c.Logger.WithField("name", entry.Name).Info("created entry")
+			c.Logger.WithField("name", entry.Name).Debug("created entry")
 		}
 	case entryUpdateMessageType:
 		var entryUpdate ntEntryUpdate
@@ -407,7 +407,7 @@ func (c *Client) handleResponse() error {
 		}
 
 		if c.Logger != nil {
-			c.Logger.WithField("id", entryUpdate.ID).Info("updated entry")
+			c.Logger.WithField("id", entryUpdate.ID).Debug("updated entry")
 		}
 	case entryFlagsUpdateMessageType:
 		var flagsUpdate ntEntryFlagsUpdate
@@ -421,7 +421,7 @@ func (c *Client) handleResponse() error {
 		}
 
 		if c.Logger != nil {
-			c.Logger.WithField("id", flagsUpdate.ID).Info("updated entry flags")
+			c.Logger.WithField("id", flagsUpdate.ID).Debug("updated entry flags")
 		}
 	case entryDeleteMessageType:
 		var delete ntEntryDelete
@@ -434,7 +434,7 @@ func (c *Client) handleResponse() error {
 		}
 
 		if c.Logger != nil {
-			c.Logger.WithField("id", delete.ID).Info("deleted entry")
+			c.Logger.WithField("id", delete.ID).Debug("deleted entry")
 		}
 	case clearAllEntriesMessageType:
 		var clear ntClearAllEntries
@@ -0,0 +1,67 @@
+package pipeline
+
+import "gocv.io/x/gocv"
+
+const StageAdjust = "adjust"
+
+// Adjust configures software image adjustments applied before color conversion, for
+// cameras whose brightness, contrast and white balance can't be controlled by the driver.
+type Adjust struct {
+	// Brightness is added to every channel, from -255 to 255.
+	Brightness float64 `json:"brightness"`
+
+	// Contrast multiplies every channel. Zero or one leaves the contrast unchanged.
+	Contrast float64 `json:"contrast"`
+
+	// WhiteBalance scales the individual color channels, and is applied before brightness
+	// and contrast.
+	WhiteBalance *WhiteBalance `json:"whiteBalance,omitempty"`
+}
+
+// WhiteBalance is a gain for each color channel. Zero or one leaves a channel unchanged.
+type WhiteBalance struct {
+	R float64 `json:"r"`
+	G float64 `json:"g"`
+	B float64 `json:"b"`
+}
+
+type adjustStage struct {
+	adjust Adjust
+}
+
+func (adjustStage) Name() string { return StageAdjust }
+
+func (s adjustStage) Process(f *Frame) {
+	if wb := s.adjust.WhiteBalance; wb != nil {
+		channels := gocv.Split(f.Mat)
+		if len(channels) == 3 {
+			// frames are BGR
+			for i, gain := range []float64{wb.B, wb.G, wb.R} {
+				if gain > 0 && gain != 1 {
+					gocv.AddWeighted(channels[i], gain, channels[i], 0, 0, &channels[i])
+				}
+			}
+
+			balanced := f.NewMat()
+			gocv.Merge(channels, &balanced)
+			f.SetMat(balanced)
+		}
+
+		for _, c := range channels {
+			c.Close()
+		}
+	}
+
+	contrast := s.adjust.Contrast
+	if contrast <= 0 {
+		contrast = 1
+	}
+	if contrast == 1 && s.adjust.Brightness == 0 {
+		return
+	}
+
+	// unlike ConvertScaleAbs, AddWeighted saturates negative values to zero
+	adjusted := f.NewMat()
+	gocv.AddWeighted(f.Mat, contrast, f.Mat, 0, s.adjust.Brightness, &adjusted)
+	f.SetMat(adjusted)
+}
@@ -0,0 +1,35 @@
+package pipeline
+
+import (
+	"image"
+	"math"
+)
+
+// calculateAngles converts a point in the frame to yaw and pitch angles (in degrees) from the
+// center of the frame, using the horizontal and vertical field of view of the camera (also in
+// degrees). Yaw is positive to the right and pitch is positive upwards.
+func calculateAngles(point image.Point, size image.Point, horizontalFOV, verticalFOV float64) (yaw, pitch float64) {
+	if horizontalFOV > 0 {
+		focal := float64(size.X) / 2 / math.Tan(radians(horizontalFOV)/2)
+		yaw = degrees(math.Atan((float64(point.X) - float64(size.X)/2) / focal))
+	}
+
+	if verticalFOV > 0 {
+		focal := float64(size.Y) / 2 / math.Tan(radians(verticalFOV)/2)
+		pitch = degrees(math.Atan((float64(size.Y)/2 - float64(point.Y)) / focal))
+	}
+
+	return yaw, pitch
+}
+
+// calculateCalibratedAngles converts a point in the frame to yaw and pitch angles (in degrees)
+// using the camera's intrinsics, which accounts for lens distortion and is more accurate than
+// calculateAngles towards the edges of the frame.
+func calculateCalibratedAngles(point image.Point, intrinsics Intrinsics) (yaw, pitch float64) {
+	x, y := intrinsics.undistort(point)
+
+	return degrees(math.Atan(x)), degrees(math.Atan(-y))
+}
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
+func degrees(rad float64) float64 { return rad * 180 / math.Pi }
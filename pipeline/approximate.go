@@ -0,0 +1,58 @@
+package pipeline
+
+import (
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+const StageApproximate = "approximate"
+
+// Approximation configures the approximate stage, which simplifies contours before they're
+// filtered. This stabilizes measurements of partially occluded targets.
+type Approximation struct {
+	// ConvexHull replaces contours with their convex hull.
+	ConvexHull bool `json:"convexHull"`
+
+	// Epsilon, if non-zero, replaces contours with a polygon approximation whose maximum
+	// distance from the contour is epsilon times the contour perimeter. It's applied after
+	// the convex hull.
+	Epsilon float64 `json:"epsilon"`
+}
+
+type approximateStage struct {
+	approximation Approximation
+}
+
+func (approximateStage) Name() string { return StageApproximate }
+
+func (s approximateStage) Process(f *Frame) {
+	for i, c := range f.Contours {
+		points := c.Points
+
+		if s.approximation.ConvexHull {
+			points = convexHull(points)
+		}
+
+		if s.approximation.Epsilon > 0 {
+			points = gocv.ApproxPolyDP(points, s.approximation.Epsilon*gocv.ArcLength(points, true), true)
+		}
+
+		f.Contours[i].Points, f.Contours[i].Area = points, gocv.ContourArea(points)
+	}
+}
+
+// convexHull returns the points of the convex hull of a contour.
+func convexHull(contour []image.Point) []image.Point {
+	hull := gocv.NewMat()
+	defer hull.Close()
+	gocv.ConvexHull(contour, &hull, true, true)
+
+	// the hull is an Nx1 matrix of 2 channel integer points
+	points := make([]image.Point, 0, hull.Rows())
+	for i := 0; i < hull.Rows(); i++ {
+		points = append(points, image.Point{X: int(hull.GetIntAt(i, 0)), Y: int(hull.GetIntAt(i, 1))})
+	}
+
+	return points
+}
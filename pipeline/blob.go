@@ -0,0 +1,87 @@
+package pipeline
+
+import (
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+const StageBlobs = "blobs"
+
+// Blob configures detecting targets with a blob detector instead of finding contours, which
+// handles round game pieces better than filtering contours by area. Each shape filter is only
+// applied when its min or max is set. Circularity, inertia and convexity are all from 0 to 1.
+type Blob struct {
+	// MinCircularity and MaxCircularity bound how close blobs are to a circle.
+	MinCircularity float64 `json:"minCircularity"`
+	MaxCircularity float64 `json:"maxCircularity"`
+
+	// MinInertia and MaxInertia bound how elongated blobs are, where 1 is a circle and 0 is a line.
+	MinInertia float64 `json:"minInertia"`
+	MaxInertia float64 `json:"maxInertia"`
+
+	// MinConvexity and MaxConvexity bound the fraction of their convex hull blobs fill.
+	MinConvexity float64 `json:"minConvexity"`
+	MaxConvexity float64 `json:"maxConvexity"`
+
+	// MinDistance is the minimum distance (in pixels) between the centers of blobs.
+	MinDistance float64 `json:"minDistance"`
+}
+
+// params returns the blob detector parameters, scaled to the working image.
+func (b Blob) params(scale float64) gocv.SimpleBlobDetectorParams {
+	params := gocv.NewSimpleBlobDetectorParams()
+
+	// blobs are found in the thresholded image, and filtered by area by the filter stage
+	params.SetFilterByColor(true)
+	params.SetBlobColor(255)
+	params.SetMinThreshold(127)
+	params.SetMaxThreshold(255)
+	params.SetFilterByArea(false)
+	params.SetMinDistBetweenBlobs(b.MinDistance * scale)
+
+	params.SetFilterByCircularity(b.MinCircularity > 0 || b.MaxCircularity > 0)
+	params.SetMinCircularity(b.MinCircularity)
+	params.SetMaxCircularity(maxOrUnbounded(b.MaxCircularity))
+
+	params.SetFilterByInertia(b.MinInertia > 0 || b.MaxInertia > 0)
+	params.SetMinInertiaRatio(b.MinInertia)
+	params.SetMaxInertiaRatio(maxOrUnbounded(b.MaxInertia))
+
+	params.SetFilterByConvexity(b.MinConvexity > 0 || b.MaxConvexity > 0)
+	params.SetMinConvexity(b.MinConvexity)
+	params.SetMaxConvexity(maxOrUnbounded(b.MaxConvexity))
+
+	return params
+}
+
+// maxOrUnbounded treats a max of zero as no upper bound.
+func maxOrUnbounded(max float64) float64 {
+	if max <= 0 {
+		return math.MaxFloat32
+	}
+
+	return max
+}
+
+type blobsStage struct {
+	blob Blob
+}
+
+func (blobsStage) Name() string { return StageBlobs }
+
+func (s blobsStage) Process(f *Frame) {
+	scale := f.scale()
+
+	detector := gocv.NewSimpleBlobDetectorWithParams(s.blob.params(scale))
+	defer detector.Close()
+
+	f.Contours = f.Contours[:0]
+	for _, kp := range detector.Detect(f.Mat) {
+		// keypoint sizes are diameters
+		points := circleContour(kp.X/scale, kp.Y/scale, kp.Size/2/scale)
+		f.Contours = append(f.Contours, Contour{Points: points, Area: gocv.ContourArea(points)})
+	}
+
+	f.ContourCount = len(f.Contours)
+}
@@ -0,0 +1,490 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// MinCalibrationViews is the fewest views of the board Calibrate needs. More views, from
+	// different angles and covering the whole frame, give a more accurate calibration.
+	MinCalibrationViews = 3
+
+	// refineIterations is the most iterations refineCalibration takes to converge.
+	refineIterations = 100
+
+	// coverageGrid is the number of cells across and down the frame coverage is measured in.
+	coverageGrid = 3
+)
+
+// Board is a chessboard calibration target.
+type Board struct {
+	// Columns and Rows are the number of inner corners (where four squares meet) across and
+	// down the board, so a board of 10 by 7 squares has 9 by 6 inner corners.
+	Columns int `json:"columns"`
+	Rows    int `json:"rows"`
+
+	// SquareSize is the length of the side of a square in meters.
+	SquareSize float64 `json:"squareSize"`
+}
+
+// Validate returns an error if the board can't be detected or calibrated with.
+func (b Board) Validate() error {
+	if b.Columns < 3 || b.Rows < 3 {
+		return errors.New("board must have at least 3 by 3 inner corners")
+	}
+
+	if b.SquareSize <= 0 {
+		return errors.New("board square size must be positive")
+	}
+
+	return nil
+}
+
+// model returns the inner corners of the board on its plane, in the order FindBoard
+// returns them.
+func (b Board) model() []ModelPoint {
+	model := make([]ModelPoint, 0, b.Columns*b.Rows)
+	for row := 0; row < b.Rows; row++ {
+		for col := 0; col < b.Columns; col++ {
+			model = append(model, ModelPoint{X: float64(col) * b.SquareSize, Y: float64(row) * b.SquareSize})
+		}
+	}
+
+	return model
+}
+
+// ImagePoint is a point in a frame with sub-pixel precision, in pixels.
+type ImagePoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// FindBoard finds the inner corners of the board in a BGR frame, refined to sub-pixel
+// precision, row by row. It returns false unless every corner is found.
+func FindBoard(frame gocv.Mat, board Board) ([]ImagePoint, bool) {
+	gray := gocv.NewMat()
+	defer gray.Close()
+	gocv.CvtColor(frame, &gray, gocv.ColorBGRToGray)
+
+	corners := gocv.NewMat()
+	defer corners.Close()
+
+	size := image.Pt(board.Columns, board.Rows)
+	if !gocv.FindChessboardCorners(gray, size, &corners, gocv.CalibCBAdaptiveThresh|gocv.CalibCBNormalizeImage|gocv.CalibCBFastCheck) {
+		return nil, false
+	}
+
+	criteria := gocv.NewTermCriteria(gocv.Count|gocv.EPS, 30, 0.001)
+	gocv.CornerSubPix(gray, &corners, image.Pt(11, 11), image.Pt(-1, -1), criteria)
+
+	if corners.Rows() != board.Columns*board.Rows {
+		return nil, false
+	}
+
+	points := make([]ImagePoint, corners.Rows())
+	for i := range points {
+		v := corners.GetVecfAt(i, 0)
+		points[i] = ImagePoint{X: float64(v[0]), Y: float64(v[1])}
+	}
+
+	return points, true
+}
+
+// Coverage is how much of the frame views of the board cover. Calibrations are most
+// accurate where the board was seen, so the board should be seen in every part of the frame.
+type Coverage struct {
+	// Cells are the number of corners seen in each cell of a 3 by 3 grid over the frame,
+	// row by row.
+	Cells [coverageGrid][coverageGrid]int `json:"cells"`
+
+	// Fraction is the fraction of cells that corners were seen in.
+	Fraction float64 `json:"fraction"`
+}
+
+// MeasureCoverage measures how much of a frame of the size the views cover.
+func MeasureCoverage(size image.Point, views [][]ImagePoint) Coverage {
+	var coverage Coverage
+	if size.X <= 0 || size.Y <= 0 {
+		return coverage
+	}
+
+	for _, view := range views {
+		for _, pt := range view {
+			col := int(pt.X * coverageGrid / float64(size.X))
+			row := int(pt.Y * coverageGrid / float64(size.Y))
+			if col >= 0 && col < coverageGrid && row >= 0 && row < coverageGrid {
+				coverage.Cells[row][col]++
+			}
+		}
+	}
+
+	var covered int
+	for _, row := range coverage.Cells {
+		for _, n := range row {
+			if n > 0 {
+				covered++
+			}
+		}
+	}
+	coverage.Fraction = float64(covered) / (coverageGrid * coverageGrid)
+
+	return coverage
+}
+
+// Calibration is the result of calibrating a camera at a resolution.
+type Calibration struct {
+	Intrinsics Intrinsics `json:"intrinsics"`
+	Width      int        `json:"width"`
+	Height     int        `json:"height"`
+
+	// Error is the RMS reprojection error in pixels. Under a pixel is good.
+	Error float64 `json:"error"`
+}
+
+// Calibrate solves the intrinsics (with radial distortion) of a camera from views of the
+// board in frames of the given size, as returned by FindBoard, using Zhang's method: a closed
+// form solution refined by minimizing the reprojection error. Skew is assumed to be zero,
+// which it is for practically every camera.
+func Calibrate(board Board, size image.Point, views [][]ImagePoint) (Calibration, error) {
+	if err := board.Validate(); err != nil {
+		return Calibration{}, err
+	}
+
+	if len(views) < MinCalibrationViews {
+		return Calibration{}, fmt.Errorf("at least %d views of the board are required", MinCalibrationViews)
+	}
+
+	model := board.model()
+	for i, view := range views {
+		if len(view) != len(model) {
+			return Calibration{}, fmt.Errorf("view %d has %d corners, not %d", i, len(view), len(model))
+		}
+	}
+
+	// the pixels are normalized to the initial guess of the intrinsics, a centered principal
+	// point and a 90 degree field of view, which keeps the equations well conditioned
+	guess := Intrinsics{Fx: float64(size.X) / 2, Fy: float64(size.X) / 2, Cx: float64(size.X) / 2, Cy: float64(size.Y) / 2}
+
+	homographies := make([][]float64, len(views))
+	for v, view := range views {
+		points := make([][2]float64, len(view))
+		for p, pt := range view {
+			points[p][0], points[p][1] = guess.normalize(pt.X, pt.Y)
+		}
+
+		h, ok := fitHomography(model, points)
+		if !ok {
+			return Calibration{}, fmt.Errorf("unable to fit homography to view %d", v)
+		}
+		homographies[v] = h
+	}
+
+	normalized, ok := solveIntrinsics(homographies)
+	if !ok {
+		return Calibration{}, errors.New("unable to solve intrinsics, try views of the board from more angles")
+	}
+
+	poses := make([]Pose, len(homographies))
+	for v, h := range homographies {
+		poses[v] = decomposeHomography(normalized.unproject(h))
+	}
+
+	intrinsics := Intrinsics{
+		Fx: normalized.Fx * guess.Fx,
+		Fy: normalized.Fy * guess.Fy,
+		Cx: normalized.Cx*guess.Fx + guess.Cx,
+		Cy: normalized.Cy*guess.Fy + guess.Cy,
+	}
+	intrinsics.Distortion = solveDistortion(intrinsics, model, views, poses)
+
+	// the closed form solution ignores distortion, so it's only a starting point
+	intrinsics, poses = refineCalibration(intrinsics, poses, model, views)
+
+	var sum float64
+	for v, view := range views {
+		for p, pt := range project(intrinsics, poses[v], model) {
+			dx, dy := pt.X-view[p].X, pt.Y-view[p].Y
+			sum += dx*dx + dy*dy
+		}
+	}
+
+	return Calibration{
+		Intrinsics: intrinsics,
+		Width:      size.X,
+		Height:     size.Y,
+		Error:      math.Sqrt(sum / float64(len(views)*len(model))),
+	}, nil
+}
+
+// normalize converts a pixel to normalized image coordinates, ignoring distortion.
+func (in Intrinsics) normalize(u, v float64) (x, y float64) {
+	return (u - in.Cx) / in.Fx, (v - in.Cy) / in.Fy
+}
+
+// unproject converts a homography to image points into one to normalized image points,
+// ignoring distortion. Both are in the form fitHomography returns, and the last row is
+// unchanged, so the last entry is still 1.
+func (in Intrinsics) unproject(h []float64) []float64 {
+	var m [9]float64
+	for i := 0; i < 3; i++ {
+		m[i] = (h[i] - in.Cx*at(h, 6+i)) / in.Fx
+		m[3+i] = (h[3+i] - in.Cy*at(h, 6+i)) / in.Fy
+		m[6+i] = at(h, 6+i)
+	}
+
+	return m[:8]
+}
+
+// at returns the entry of a homography in the form fitHomography returns, which doesn't
+// include the last entry since it's 1.
+func at(h []float64, i int) float64 {
+	if i == 8 {
+		return 1
+	}
+
+	return h[i]
+}
+
+// solveIntrinsics solves the pinhole model (without skew) from the homographies between the
+// board and the image points of each view, using the constraints they place on the image of
+// the absolute conic, B. The scale of B is fixed with B11 = 1, and B12 = 0 without skew.
+func solveIntrinsics(homographies [][]float64) (Intrinsics, bool) {
+	// v returns the coefficients of B11, B12, B22, B13, B23 and B33 in hi^T B hj
+	v := func(h []float64, i, j int) [6]float64 {
+		return [6]float64{
+			at(h, i) * at(h, j),
+			at(h, i)*at(h, 3+j) + at(h, 3+i)*at(h, j),
+			at(h, 3+i) * at(h, 3+j),
+			at(h, 6+i)*at(h, j) + at(h, i)*at(h, 6+j),
+			at(h, 6+i)*at(h, 3+j) + at(h, 3+i)*at(h, 6+j),
+			at(h, 6+i) * at(h, 6+j),
+		}
+	}
+
+	var rows [][]float64
+	var rhs []float64
+	for _, h := range homographies {
+		// the columns of the rotation are orthogonal and the same length
+		v12, v11, v22 := v(h, 0, 1), v(h, 0, 0), v(h, 1, 1)
+
+		var diff [6]float64
+		for k := range diff {
+			diff[k] = v11[k] - v22[k]
+		}
+
+		for _, c := range [][6]float64{v12, diff} {
+			rows = append(rows, []float64{c[2], c[3], c[4], c[5]})
+			rhs = append(rhs, -c[0])
+		}
+	}
+
+	b, ok := leastSquares(rows, rhs)
+	if !ok {
+		return Intrinsics{}, false
+	}
+
+	b22, b13, b23, b33 := b[0], b[1], b[2], b[3]
+	cy := -b23 / b22
+	lambda := b33 - b13*b13 + cy*b23
+	if b22 <= 0 || lambda <= 0 {
+		return Intrinsics{}, false
+	}
+
+	return Intrinsics{Fx: math.Sqrt(lambda), Fy: math.Sqrt(lambda / b22), Cx: -b13, Cy: cy}, true
+}
+
+// solveDistortion solves the radial distortion coefficients k1 and k2 from the difference
+// between the observed corners and where the pinhole model projects them.
+func solveDistortion(intrinsics Intrinsics, model []ModelPoint, views [][]ImagePoint, poses []Pose) []float64 {
+	var rows [][]float64
+	var rhs []float64
+	for v, view := range views {
+		r := rotationMatrix(poses[v].Rotation)
+		for p, m := range model {
+			x, y := projectNormalized(r, poses[v].Translation, m)
+			r2 := x*x + y*y
+			u, w := x*intrinsics.Fx+intrinsics.Cx, y*intrinsics.Fy+intrinsics.Cy
+
+			rows = append(rows,
+				[]float64{(u - intrinsics.Cx) * r2, (u - intrinsics.Cx) * r2 * r2},
+				[]float64{(w - intrinsics.Cy) * r2, (w - intrinsics.Cy) * r2 * r2},
+			)
+			rhs = append(rhs, view[p].X-u, view[p].Y-w)
+		}
+	}
+
+	k, ok := leastSquares(rows, rhs)
+	if !ok {
+		return nil
+	}
+
+	return []float64{k[0], k[1], 0, 0}
+}
+
+// project projects the model points at the pose into the image, with distortion.
+func project(intrinsics Intrinsics, pose Pose, model []ModelPoint) []ImagePoint {
+	var k [5]float64
+	copy(k[:], intrinsics.Distortion)
+
+	r := rotationMatrix(pose.Rotation)
+	points := make([]ImagePoint, len(model))
+	for i, m := range model {
+		x, y := projectNormalized(r, pose.Translation, m)
+
+		r2 := x*x + y*y
+		radial := 1 + ((k[4]*r2+k[1])*r2+k[0])*r2
+		dx := x*radial + 2*k[2]*x*y + k[3]*(r2+2*x*x)
+		dy := y*radial + k[2]*(r2+2*y*y) + 2*k[3]*x*y
+
+		points[i] = ImagePoint{X: dx*intrinsics.Fx + intrinsics.Cx, Y: dy*intrinsics.Fy + intrinsics.Cy}
+	}
+
+	return points
+}
+
+// projectNormalized projects a model point at the rotation and translation to normalized
+// image coordinates.
+func projectNormalized(r [3][3]float64, t [3]float64, m ModelPoint) (x, y float64) {
+	var c [3]float64
+	for i := range c {
+		c[i] = r[i][0]*m.X + r[i][1]*m.Y + t[i]
+	}
+
+	return c[0] / c[2], c[1] / c[2]
+}
+
+// calibrationParams are the parameters refineCalibration optimizes for each view, in addition
+// to fx, fy, cx, cy, k1 and k2: the rotation and translation of the board.
+const calibrationParams = 6
+
+// refineCalibration minimizes the reprojection error of the intrinsics, radial distortion
+// and poses of the board with Levenberg-Marquardt, starting from an initial solution.
+func refineCalibration(intrinsics Intrinsics, poses []Pose, model []ModelPoint, views [][]ImagePoint) (Intrinsics, []Pose) {
+	var k [2]float64
+	copy(k[:], intrinsics.Distortion)
+
+	params := []float64{intrinsics.Fx, intrinsics.Fy, intrinsics.Cx, intrinsics.Cy, k[0], k[1]}
+	for _, pose := range poses {
+		params = append(params, pose.Rotation[:]...)
+		params = append(params, pose.Translation[:]...)
+	}
+
+	unpack := func(params []float64) (Intrinsics, []Pose) {
+		intrinsics := Intrinsics{Fx: params[0], Fy: params[1], Cx: params[2], Cy: params[3], Distortion: []float64{params[4], params[5], 0, 0}}
+
+		poses := make([]Pose, len(views))
+		for v := range poses {
+			view := params[calibrationParams*(v+1):]
+			copy(poses[v].Rotation[:], view[:3])
+			copy(poses[v].Translation[:], view[3:6])
+		}
+
+		return intrinsics, poses
+	}
+
+	// residuals returns the reprojection errors of a view
+	residuals := func(params []float64, v int) []float64 {
+		intrinsics, poses := unpack(params)
+
+		r := make([]float64, 0, 2*len(model))
+		for p, pt := range project(intrinsics, poses[v], model) {
+			r = append(r, pt.X-views[v][p].X, pt.Y-views[v][p].Y)
+		}
+
+		return r
+	}
+
+	cost := func(params []float64) float64 {
+		var sum float64
+		for v := range views {
+			for _, r := range residuals(params, v) {
+				sum += r * r
+			}
+		}
+
+		return sum
+	}
+
+	n := len(params)
+	lambda := 1e-3
+	current := cost(params)
+	for i := 0; i < refineIterations; i++ {
+		// build the normal equations from the numerical jacobian; each view's residuals only
+		// depend on the shared parameters and the view's own parameters
+		jtj := make([][]float64, n)
+		for j := range jtj {
+			jtj[j] = make([]float64, n)
+		}
+		jtr := make([]float64, n)
+
+		for v := range views {
+			cols := []int{0, 1, 2, 3, 4, 5}
+			for j := 0; j < calibrationParams; j++ {
+				cols = append(cols, calibrationParams*(v+1)+j)
+			}
+
+			r := residuals(params, v)
+			jacobian := make([][]float64, len(cols))
+			for c, col := range cols {
+				step := 1e-6 * math.Max(1, math.Abs(params[col]))
+				perturbed := append([]float64(nil), params...)
+				perturbed[col] += step
+
+				jacobian[c] = residuals(perturbed, v)
+				for e := range jacobian[c] {
+					jacobian[c][e] = (jacobian[c][e] - r[e]) / step
+				}
+			}
+
+			for a, colA := range cols {
+				for b, colB := range cols {
+					for e := range r {
+						jtj[colA][colB] += jacobian[a][e] * jacobian[b][e]
+					}
+				}
+				for e := range r {
+					jtr[colA] += jacobian[a][e] * r[e]
+				}
+			}
+		}
+
+		improved := false
+		for lambda < 1e10 {
+			a := make([][]float64, n)
+			b := make([]float64, n)
+			for j := range a {
+				a[j] = append([]float64(nil), jtj[j]...)
+				a[j][j] += lambda * jtj[j][j]
+				b[j] = -jtr[j]
+			}
+
+			step, ok := solve(a, b)
+			if ok {
+				next := make([]float64, n)
+				for j := range next {
+					next[j] = params[j] + step[j]
+				}
+
+				if nextCost := cost(next); nextCost < current {
+					improved = current-nextCost > 1e-12*current
+					params, current = next, nextCost
+					lambda /= 10
+					break
+				}
+			}
+
+			lambda *= 10
+		}
+
+		if !improved {
+			break
+		}
+	}
+
+	return unpack(params)
+}
@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// ColorSpace is the color space frames are converted to before thresholding. In color spaces
+// other than HSV, the H, S and V threshold components are used for the color space's channels
+// in order (for example, L, A and B for ColorSpaceLAB).
+type ColorSpace string
+
+const (
+	ColorSpaceHSV ColorSpace = "hsv"
+
+	// ColorSpaceGray thresholds on grayscale intensity only, which is useful with IR
+	// illumination and filters. Only the V values of the thresholds are used.
+	ColorSpaceGray ColorSpace = "gray"
+
+	// ColorSpaceHLS thresholds on hue, lightness and saturation, in that order. Like HSV,
+	// hue is from 0 to 180 and wraps around.
+	ColorSpaceHLS ColorSpace = "hls"
+
+	// ColorSpaceYUV thresholds on luma and the two chroma channels.
+	ColorSpaceYUV ColorSpace = "yuv"
+
+	// ColorSpaceLAB thresholds on lightness and the green-red and blue-yellow channels.
+	ColorSpaceLAB ColorSpace = "lab"
+)
+
+// ColorSpaces are the supported color spaces.
+var ColorSpaces = []ColorSpace{ColorSpaceHSV, ColorSpaceGray, ColorSpaceHLS, ColorSpaceYUV, ColorSpaceLAB}
+
+// conversion returns the conversion code from BGR to the color space. An empty color space is
+// treated as ColorSpaceHSV.
+func (c ColorSpace) conversion() gocv.ColorConversionCode {
+	switch c {
+	case ColorSpaceGray:
+		return gocv.ColorBGRToGray
+	case ColorSpaceHLS:
+		return gocv.ColorBGRToHLS
+	case ColorSpaceYUV:
+		return gocv.ColorBGRToYUV
+	case ColorSpaceLAB:
+		return gocv.ColorBGRToLab
+	default:
+		return gocv.ColorBGRToHSV
+	}
+}
+
+// hasHue returns whether the first channel of the color space is hue, which wraps around.
+func (c ColorSpace) hasHue() bool {
+	return c == "" || c == ColorSpaceHSV || c == ColorSpaceHLS
+}
+
+// clamp limits each threshold component to the valid range of its channel.
+func (c ColorSpace) clamp(h HSV) HSV {
+	if c.hasHue() {
+		return h.Clamp()
+	}
+
+	return HSV{
+		H: math.Max(0, math.Min(maxSaturationValue, h.H)),
+		S: math.Max(0, math.Min(maxSaturationValue, h.S)),
+		V: math.Max(0, math.Min(maxSaturationValue, h.V)),
+	}
+}
+
+// thresholds returns the lower and upper threshold scalars for the color space.
+func (c ColorSpace) thresholds(min, max HSV) (gocv.Scalar, gocv.Scalar) {
+	switch c {
+	case ColorSpaceGray:
+		return gocv.Scalar{Val1: min.V}, gocv.Scalar{Val1: max.V}
+	default:
+		return min.scalar(), max.scalar()
+	}
+}
+
+// brightnessChannel returns the index of the channel holding brightness in the color space.
+func (c ColorSpace) brightnessChannel() int {
+	switch c {
+	case ColorSpaceGray, ColorSpaceYUV, ColorSpaceLAB:
+		return 0
+	case ColorSpaceHLS:
+		return 1
+	default:
+		return 2
+	}
+}
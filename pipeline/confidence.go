@@ -0,0 +1,72 @@
+package pipeline
+
+import (
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// calculateConfidence scores how well a contour matched the filters, from 0 to 1. It's the
+// product of three scores, so a contour that only barely passed any one of them has a low
+// confidence:
+//
+//   - area: how far the contour area is from the nearest area bound, relative to the bound
+//   - solidity: the contour area as a fraction of its convex hull area
+//   - aspect: how close the aspect ratio is to the expected aspect ratio, if configured
+func calculateConfidence(c Contour, areaFraction float64, config Config) float64 {
+	return areaScore(areaFraction, config.MinContour, config.MaxContour) *
+		solidity(c) *
+		aspectScore(c, config.AspectRatio)
+}
+
+// areaScore is 1 when the area is at least half of the bound away from both bounds, falling
+// linearly to 0 at the bounds.
+func areaScore(area, min, max float64) float64 {
+	score := 1.0
+	if min > 0 {
+		score = math.Min(score, 2*(area-min)/min)
+	}
+	if max > 0 {
+		score = math.Min(score, 2*(max-area)/max)
+	}
+
+	return clampUnit(score)
+}
+
+// solidity is the fraction of its convex hull a contour fills.
+func solidity(c Contour) float64 {
+	if len(c.Points) < 3 {
+		return 0
+	}
+
+	hullArea := gocv.ContourArea(convexHull(c.Points))
+	if hullArea <= 0 {
+		return 0
+	}
+
+	return clampUnit(c.Area / hullArea)
+}
+
+// aspectScore compares the aspect ratio of the contour's minimum area rectangle to the
+// expected aspect ratio, ignoring orientation. It's 1 if no aspect ratio is expected.
+func aspectScore(c Contour, expected float64) float64 {
+	if expected <= 0 {
+		return 1
+	}
+	if c.Rect.Width <= 0 || c.Rect.Height <= 0 {
+		return 0
+	}
+
+	long, short := float64(c.Rect.Width), float64(c.Rect.Height)
+	if short > long {
+		long, short = short, long
+	}
+	if expected < 1 {
+		expected = 1 / expected
+	}
+
+	ratio := long / short
+	return math.Min(ratio, expected) / math.Max(ratio, expected)
+}
+
+func clampUnit(v float64) float64 { return math.Max(0, math.Min(1, v)) }
@@ -0,0 +1,53 @@
+package pipeline
+
+import "math"
+
+const StageDistance = "distance"
+
+// Mount describes how the camera is mounted on the robot, and the height of the target,
+// which is used to estimate the ground distance to targets.
+type Mount struct {
+	// Height is the height of the camera lens from the ground, in meters.
+	Height float64 `json:"height"`
+
+	// Pitch is the angle of the camera above horizontal, in degrees.
+	Pitch float64 `json:"pitch"`
+
+	// TargetHeight is the height of the target from the ground, in meters.
+	TargetHeight float64 `json:"targetHeight"`
+}
+
+// estimateDistance estimates the ground distance to a target at the given pitch (in degrees,
+// relative to the camera). It returns false if the distance can't be estimated, for example
+// when the target appears level with the camera.
+func estimateDistance(mount Mount, pitch float64) (float64, bool) {
+	angle := radians(mount.Pitch + pitch)
+	if math.Abs(math.Tan(angle)) < 1e-6 {
+		return 0, false
+	}
+
+	distance := (mount.TargetHeight - mount.Height) / math.Tan(angle)
+	if distance <= 0 {
+		return 0, false
+	}
+
+	return distance, true
+}
+
+type distanceStage struct {
+	mount *Mount
+}
+
+func (distanceStage) Name() string { return StageDistance }
+
+func (s distanceStage) Process(f *Frame) {
+	if s.mount == nil {
+		return
+	}
+
+	for i := range f.Targets {
+		if distance, ok := estimateDistance(*s.mount, f.Targets[i].Pitch); ok {
+			f.Targets[i].Distance = distance
+		}
+	}
+}
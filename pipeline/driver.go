@@ -0,0 +1,70 @@
+package pipeline
+
+import (
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+// Driver configures a driver mode pipeline, which passes frames through to the output
+// without looking for targets, so the camera can be used as a driver camera.
+type Driver struct {
+	// Brightness multiplies the brightness of the output frame, to compensate for the short
+	// exposures used by vision pipelines. Zero or one leaves the frame unchanged.
+	Brightness float64 `json:"brightness"`
+
+	// Crosshair draws a crosshair in the center of the frame.
+	Crosshair bool `json:"crosshair"`
+}
+
+const (
+	StageBrighten  = "brighten"
+	StageCrosshair = "crosshair"
+)
+
+// driverStageNames returns the default stages for a driver mode pipeline.
+func driverStageNames(driver Driver) []string {
+	names := []string{}
+	if driver.Brightness > 0 && driver.Brightness != 1 {
+		names = append(names, StageBrighten)
+	}
+	if driver.Crosshair {
+		names = append(names, StageCrosshair)
+	}
+
+	return names
+}
+
+type brightenStage struct {
+	brightness float64
+}
+
+func (brightenStage) Name() string { return StageBrighten }
+
+func (s brightenStage) Process(f *Frame) {
+	if s.brightness <= 0 {
+		return
+	}
+
+	gocv.ConvertScaleAbs(*f.Output, f.Output, s.brightness, 0)
+}
+
+type crosshairStage struct {
+	overlay Overlay
+}
+
+func (crosshairStage) Name() string { return StageCrosshair }
+
+func (s crosshairStage) Process(f *Frame) {
+	if s.overlay.Disabled || !f.Layers.Crosshair {
+		return
+	}
+
+	size := f.Size()
+	center := image.Point{X: size.X / 2, Y: size.Y / 2}
+	arm := size.Y / 20
+
+	c, thickness := s.overlay.CrosshairColor.rgba(), s.overlay.Thickness
+	gocv.Line(f.Output, image.Point{X: center.X - arm, Y: center.Y}, image.Point{X: center.X + arm, Y: center.Y}, c, thickness)
+	gocv.Line(f.Output, image.Point{X: center.X, Y: center.Y - arm}, image.Point{X: center.X, Y: center.Y + arm}, c, thickness)
+}
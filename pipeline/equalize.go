@@ -0,0 +1,58 @@
+package pipeline
+
+import (
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+const StageEqualize = "equalize"
+
+// Equalize configures contrast limited adaptive histogram equalization (CLAHE) of the
+// brightness channel, which evens out lighting that varies across the frame before thresholding.
+type Equalize struct {
+	// ClipLimit limits how much contrast is amplified, and defaults to 2.
+	ClipLimit float64 `json:"clipLimit"`
+
+	// TileSize is the number of tiles the frame is divided into in each direction, and
+	// defaults to 8.
+	TileSize int `json:"tileSize"`
+}
+
+type equalizeStage struct {
+	colorSpace ColorSpace
+	equalize   Equalize
+}
+
+func (equalizeStage) Name() string { return StageEqualize }
+
+func (s equalizeStage) Process(f *Frame) {
+	clipLimit, tileSize := s.equalize.ClipLimit, s.equalize.TileSize
+	if clipLimit <= 0 {
+		clipLimit = 2
+	}
+	if tileSize < 1 {
+		tileSize = 8
+	}
+
+	clahe := gocv.NewCLAHEWithParams(clipLimit, image.Point{X: tileSize, Y: tileSize})
+	defer clahe.Close()
+
+	channels := gocv.Split(f.Mat)
+	defer func() {
+		for _, c := range channels {
+			c.Close()
+		}
+	}()
+
+	i := s.colorSpace.brightnessChannel()
+	if i >= len(channels) {
+		return
+	}
+
+	clahe.Apply(channels[i], &channels[i])
+
+	equalized := f.NewMat()
+	gocv.Merge(channels, &equalized)
+	f.SetMat(equalized)
+}
@@ -0,0 +1,122 @@
+package pipeline
+
+import (
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+// Retrieval is the contour retrieval mode, which determines which contours are found.
+type Retrieval string
+
+const (
+	// RetrievalExternal only finds the outermost contours, so holes are ignored.
+	RetrievalExternal Retrieval = "external"
+
+	// RetrievalList finds every contour, including the boundaries of holes.
+	RetrievalList Retrieval = "list"
+)
+
+// mode returns the OpenCV retrieval mode. An empty retrieval mode is treated as RetrievalList.
+func (r Retrieval) mode() gocv.RetrievalMode {
+	switch r {
+	case RetrievalExternal:
+		return gocv.RetrievalExternal
+	default:
+		return gocv.RetrievalList
+	}
+}
+
+// HierarchyLevel selects contours by how deeply they're nested in other contours.
+type HierarchyLevel string
+
+const (
+	// HierarchyOuter only keeps contours that aren't inside another contour.
+	HierarchyOuter HierarchyLevel = "outer"
+
+	// HierarchyInner only keeps contours inside another contour, such as the boundary of
+	// the hole in a hollow target.
+	HierarchyInner HierarchyLevel = "inner"
+)
+
+// Hierarchy configures filtering contours by their position in the contour hierarchy. It
+// requires RetrievalList, since external retrieval doesn't find nested contours.
+type Hierarchy struct {
+	// Level is the level of contours to keep. Empty keeps contours at any level.
+	Level HierarchyLevel `json:"level,omitempty"`
+
+	// Holes, if set, is the exact number of contours directly inside kept contours.
+	Holes *int `json:"holes,omitempty"`
+}
+
+// matches returns whether the contour is kept by the hierarchy filter.
+func (h Hierarchy) matches(c Contour) bool {
+	switch h.Level {
+	case HierarchyOuter:
+		if c.Depth != 0 {
+			return false
+		}
+	case HierarchyInner:
+		if c.Depth == 0 {
+			return false
+		}
+	}
+
+	return h.Holes == nil || c.Holes == *h.Holes
+}
+
+// calculateHierarchy sets the depth and number of holes of each contour. gocv doesn't
+// return the hierarchy OpenCV finds, so it's rebuilt from which contours contain which:
+// the parent of a contour is the smallest contour containing it.
+func calculateHierarchy(contours []Contour) {
+	parents := make([]int, len(contours))
+	for i, c := range contours {
+		parents[i] = -1
+		if len(c.Points) == 0 {
+			continue
+		}
+
+		for j, candidate := range contours {
+			if i == j || candidate.Area <= c.Area || !pointInPolygon(c.Points[0], candidate.Points) {
+				continue
+			}
+
+			if parents[i] == -1 || candidate.Area < contours[parents[i]].Area {
+				parents[i] = j
+			}
+		}
+	}
+
+	for i := range contours {
+		contours[i].Depth, contours[i].Holes = 0, 0
+		for p := parents[i]; p != -1; p = parents[p] {
+			contours[i].Depth++
+		}
+	}
+	for _, p := range parents {
+		if p != -1 {
+			contours[p].Holes++
+		}
+	}
+}
+
+// pointInPolygon returns whether the point is inside (or on the boundary of) the polygon,
+// by counting how many edges a horizontal ray from the point crosses.
+func pointInPolygon(pt image.Point, polygon []image.Point) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+		if a == pt {
+			return true
+		}
+
+		if (a.Y > pt.Y) != (b.Y > pt.Y) {
+			x := float64(b.X-a.X)*float64(pt.Y-a.Y)/float64(b.Y-a.Y) + float64(a.X)
+			if float64(pt.X) < x {
+				inside = !inside
+			}
+		}
+	}
+
+	return inside
+}
@@ -0,0 +1,56 @@
+package pipeline
+
+import (
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// HSV is a color in OpenCV's 8-bit HSV units: hue from 0 to 180 (half of the hue in degrees),
+// and saturation and value from 0 to 255. Use HSVFromDegrees and Degrees to convert from and to
+// the 0-360 hue most color pickers use.
+type HSV struct {
+	H float64 `json:"h"`
+	S float64 `json:"s"`
+	V float64 `json:"v"`
+}
+
+const (
+	// maxHue is the largest hue OpenCV uses for 8-bit HSV images.
+	maxHue = 180
+
+	// maxSaturationValue is the largest saturation and value of 8-bit HSV images.
+	maxSaturationValue = 255
+)
+
+// HSVFromDegrees converts a color with hue in degrees (0-360) and saturation and value from 0
+// to 255 to OpenCV units. Hues outside 0-360 wrap around, but 360 itself is kept, since as a
+// threshold it means the top of the hue range rather than red.
+func HSVFromDegrees(h, s, v float64) HSV {
+	if h < 0 || h > 360 {
+		h = math.Mod(h, 360)
+		if h < 0 {
+			h += 360
+		}
+	}
+
+	return HSV{H: h / 2, S: s, V: v}.Clamp()
+}
+
+// Degrees returns the color with hue in degrees (0-360), and saturation and value from 0 to 255.
+func (h HSV) Degrees() (hue, saturation, value float64) {
+	return h.H * 2, h.S, h.V
+}
+
+// Clamp limits each component to its valid range.
+func (h HSV) Clamp() HSV {
+	return HSV{
+		H: math.Max(0, math.Min(maxHue, h.H)),
+		S: math.Max(0, math.Min(maxSaturationValue, h.S)),
+		V: math.Max(0, math.Min(maxSaturationValue, h.V)),
+	}
+}
+
+func (h HSV) scalar() gocv.Scalar {
+	return gocv.Scalar{Val1: h.H, Val2: h.S, Val3: h.V}
+}
@@ -0,0 +1,35 @@
+package pipeline
+
+const StageHysteresis = "hysteresis"
+
+// hysteresisStage keeps the last targets locked for a number of frames after they're lost,
+// flagged as stale, so momentary filtering failures don't cause the target to flicker.
+type hysteresisStage struct {
+	frames int
+
+	last   []Target
+	missed int
+}
+
+func (*hysteresisStage) Name() string { return StageHysteresis }
+
+func (s *hysteresisStage) Process(f *Frame) {
+	if len(f.Targets) > 0 {
+		s.last = append(s.last[:0], f.Targets...)
+		s.missed = 0
+		return
+	}
+
+	if len(s.last) == 0 || s.missed >= s.frames {
+		s.last = s.last[:0]
+		return
+	}
+
+	s.missed++
+
+	f.Targets = make([]Target, len(s.last))
+	for i, target := range s.last {
+		target.Stale = true
+		f.Targets[i] = target
+	}
+}
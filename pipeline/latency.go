@@ -0,0 +1,61 @@
+package pipeline
+
+import "time"
+
+// StageDuration is how long a single stage took to process a frame.
+type StageDuration struct {
+	Stage    string        `json:"stage"`
+	Duration time.Duration `json:"duration"`
+}
+
+// latencyWindow is the number of frames latency is averaged over.
+const latencyWindow = 30
+
+// rollingAverage is the average of the last latencyWindow durations. The zero value is
+// ready to use.
+type rollingAverage struct {
+	samples [latencyWindow]time.Duration
+	next    int
+	count   int
+	sum     time.Duration
+}
+
+// add adds a sample, evicting the oldest sample if the window is full, and returns the
+// new average.
+func (r *rollingAverage) add(d time.Duration) time.Duration {
+	if r.count == latencyWindow {
+		r.sum -= r.samples[r.next]
+	} else {
+		r.count++
+	}
+
+	r.samples[r.next] = d
+	r.sum += d
+	r.next = (r.next + 1) % latencyWindow
+
+	return r.sum / time.Duration(r.count)
+}
+
+// min returns the shortest duration in the window.
+func (r *rollingAverage) min() time.Duration {
+	var min time.Duration
+	for i := 0; i < r.count; i++ {
+		if i == 0 || r.samples[i] < min {
+			min = r.samples[i]
+		}
+	}
+
+	return min
+}
+
+// max returns the longest duration in the window.
+func (r *rollingAverage) max() time.Duration {
+	var max time.Duration
+	for i := 0; i < r.count; i++ {
+		if r.samples[i] > max {
+			max = r.samples[i]
+		}
+	}
+
+	return max
+}
@@ -0,0 +1,38 @@
+package pipeline
+
+// LEDMode is how the hardware lights are controlled while a pipeline is active.
+type LEDMode string
+
+const (
+	LEDOff LEDMode = "off"
+	LEDOn  LEDMode = "on"
+
+	// LEDBrightness dims the lights to LEDs.Brightness.
+	LEDBrightness LEDMode = "brightness"
+
+	// LEDBlink blinks the lights, every LEDs.BlinkPeriod.
+	LEDBlink LEDMode = "blink"
+)
+
+// defaultBlinkPeriod is the blink period used when LEDs.BlinkPeriod isn't set.
+const defaultBlinkPeriod = 0.5
+
+// LEDs configure the hardware lights while a pipeline is active.
+type LEDs struct {
+	Mode LEDMode `json:"mode"`
+
+	// Brightness is from off (0) to fully on (1), for LEDBrightness.
+	Brightness float64 `json:"brightness"`
+
+	// BlinkPeriod is the time (in seconds) the lights take to blink on and off, for LEDBlink.
+	BlinkPeriod float64 `json:"blinkPeriod"`
+}
+
+// BlinkPeriodOrDefault returns the blink period, or the default if it isn't set.
+func (l LEDs) BlinkPeriodOrDefault() float64 {
+	if l.BlinkPeriod <= 0 {
+		return defaultBlinkPeriod
+	}
+
+	return l.BlinkPeriod
+}
@@ -0,0 +1,89 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// CurrentSchemaVersion is the version of the Config JSON schema written by this version of
+// gloworm-app. Bump it and add a migration whenever a field is introduced whose zero value
+// isn't the right default for existing configs.
+const CurrentSchemaVersion = 2
+
+// migrations upgrade a config JSON object from version i to version i+1. Migrations must
+// only fill in missing fields, so they're safe to run on configs that were written without
+// a schema version.
+var migrations = []func(fields map[string]json.RawMessage){
+	migrateV0,
+	migrateV1,
+}
+
+// migrateV0 makes the defaults for fields added after the original schema explicit.
+func migrateV0(fields map[string]json.RawMessage) {
+	setDefault(fields, "colorSpace", ColorSpaceHSV)
+	setDefault(fields, "sortMode", SortLargest)
+	setDefault(fields, "maxTargets", 1)
+}
+
+// migrateV1 sets the pipeline type, which was previously implied by the driver and shape fields.
+func migrateV1(fields map[string]json.RawMessage) {
+	switch {
+	case isSet(fields, "driver"):
+		setDefault(fields, "type", TypeDriver)
+	case isSet(fields, "shape"):
+		setDefault(fields, "type", TypeColoredShape)
+	default:
+		setDefault(fields, "type", TypeReflective)
+	}
+}
+
+// isSet returns whether the field is present and not null.
+func isSet(fields map[string]json.RawMessage, key string) bool {
+	raw, ok := fields[key]
+	return ok && string(raw) != "null"
+}
+
+func setDefault(fields map[string]json.RawMessage, key string, value interface{}) {
+	if _, ok := fields[key]; ok {
+		return
+	}
+
+	// the defaults are all simple values, so marshalling can't fail
+	fields[key], _ = json.Marshal(value)
+}
+
+// UnmarshalJSON decodes a config, first migrating it from older schema versions.
+func (c *Config) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if fields == nil { // null
+		return nil
+	}
+
+	var version int
+	if raw, ok := fields["schemaVersion"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return fmt.Errorf("invalid schema version: %w", err)
+		}
+	}
+
+	if version > CurrentSchemaVersion {
+		return fmt.Errorf("config schema version %d is newer than the supported version %d", version, CurrentSchemaVersion)
+	}
+
+	for ; version < CurrentSchemaVersion; version++ {
+		migrations[version](fields)
+	}
+	fields["schemaVersion"], _ = json.Marshal(CurrentSchemaVersion)
+
+	migrated, err := json.Marshal(fields)
+	if err != nil {
+		return fmt.Errorf("unable to re-encode migrated config: %w", err)
+	}
+
+	// config has the same fields as Config without the UnmarshalJSON method
+	type config Config
+	return json.Unmarshal(migrated, (*config)(c))
+}
@@ -0,0 +1,159 @@
+package pipeline
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// Color is an RGB color used when drawing the overlay.
+type Color struct {
+	R uint8 `json:"r"`
+	G uint8 `json:"g"`
+	B uint8 `json:"b"`
+}
+
+func (c Color) rgba() color.RGBA {
+	return color.RGBA{R: c.R, G: c.G, B: c.B, A: 255}
+}
+
+// Overlay configures what's drawn onto the output frame. Unset fields use the defaults.
+type Overlay struct {
+	// Disabled turns off drawing entirely, which saves processing time when the stream
+	// isn't being watched.
+	Disabled bool `json:"disabled"`
+
+	// TargetsOnly draws only the selected targets, instead of every contour that passed filtering.
+	TargetsOnly bool `json:"targetsOnly"`
+
+	// RectangleColor and CrosshairColor default to white and green.
+	RectangleColor *Color `json:"rectangleColor,omitempty"`
+	CrosshairColor *Color `json:"crosshairColor,omitempty"`
+
+	// Thickness is the line thickness in pixels, and defaults to 2.
+	Thickness int `json:"thickness"`
+
+	// Fill fills contours (or target bounding boxes) instead of outlining them.
+	Fill bool `json:"fill"`
+}
+
+// Layers toggles parts of the overlay while the pipeline is running, without changing its
+// config, so drivers can watch a clean stream while tuners see everything.
+type Layers struct {
+	// Contours are the outlines of every contour that passed filtering, and Targets are the
+	// boxes around the selected targets, which are drawn instead of the contours if the
+	// overlay is configured to draw only targets.
+	Contours bool `json:"contours"`
+	Targets  bool `json:"targets"`
+
+	Crosshair bool `json:"crosshair"`
+
+	// Stamp is the frame's sequence number and capture time, and Latency is its latency.
+	Stamp   bool `json:"stamp"`
+	Latency bool `json:"latency"`
+}
+
+// AllLayers draws every layer of the overlay, and is used unless a pipeline's Layers are set.
+var AllLayers = Layers{Contours: true, Targets: true, Crosshair: true, Stamp: true, Latency: true}
+
+// layers returns the layers of the overlay to draw.
+func (p *Pipeline) layers() Layers {
+	if p.Layers == nil {
+		return AllLayers
+	}
+
+	return *p.Layers
+}
+
+var (
+	defaultRectangleColor = Color{R: 255, G: 255, B: 255}
+	defaultCrosshairColor = Color{R: 0, G: 255, B: 0}
+)
+
+// overlayOrDefault returns the overlay config with defaults filled in.
+func overlayOrDefault(overlay *Overlay) Overlay {
+	var o Overlay
+	if overlay != nil {
+		o = *overlay
+	}
+
+	if o.RectangleColor == nil {
+		o.RectangleColor = &defaultRectangleColor
+	}
+	if o.CrosshairColor == nil {
+		o.CrosshairColor = &defaultCrosshairColor
+	}
+	if o.Thickness < 1 {
+		o.Thickness = 2
+	}
+
+	return o
+}
+
+type outputStage struct {
+	overlay Overlay
+}
+
+func (outputStage) Name() string { return StageOutput }
+
+func (s outputStage) Process(f *Frame) {
+	if s.overlay.Disabled {
+		return
+	}
+
+	c := s.overlay.RectangleColor.rgba()
+
+	thickness := s.overlay.Thickness
+	if s.overlay.Fill {
+		// negative thicknesses fill the rectangle
+		thickness = -1
+	}
+
+	if s.overlay.TargetsOnly || !f.Layers.Contours {
+		if !f.Layers.Targets {
+			return
+		}
+
+		for _, target := range f.Targets {
+			gocv.Rectangle(f.Output, target.BoundingBox, c, thickness)
+		}
+		return
+	}
+
+	for _, contour := range f.Contours {
+		if s.overlay.Fill {
+			gocv.FillPoly(f.Output, [][]image.Point{contour.Points}, c)
+			continue
+		}
+
+		gocv.Rectangle(f.Output, contour.Rect.BoundingRect, c, thickness)
+	}
+}
+
+// DrawStamp draws the result's sequence number, capture time and latency onto the corner of
+// the output frame, so frames in the stream can be matched up with published results. Nothing
+// is drawn if the overlay is disabled, or the layers are hidden.
+func (p *Pipeline) DrawStamp(out *gocv.Mat, result Result) {
+	overlay, layers := overlayOrDefault(p.Config.Overlay), p.layers()
+	if overlay.Disabled {
+		return
+	}
+
+	var parts []string
+	if layers.Stamp {
+		parts = append(parts, fmt.Sprintf("#%d %.3fs", result.Sequence, result.CaptureTime.Seconds()))
+	}
+	if layers.Latency {
+		parts = append(parts, fmt.Sprintf("%.1fms", float64(result.Latency)/float64(time.Millisecond)))
+	}
+	if len(parts) == 0 {
+		return
+	}
+
+	text := strings.Join(parts, " ")
+	gocv.PutText(out, text, image.Pt(5, 15), gocv.FontHersheyPlain, 1, overlay.RectangleColor.rgba(), 1)
+}
@@ -1,94 +1,400 @@
 package pipeline
 
 import (
+	"fmt"
 	"image"
-	"image/color"
-	"sort"
+	"time"
 
+	"github.com/gloworm-vision/gloworm-app/source"
 	"gocv.io/x/gocv"
 )
 
-type HSV struct {
-	H float64 `json:"h"`
-	S float64 `json:"s"`
-	V float64 `json:"v"`
-}
+type Config struct {
+	// SchemaVersion is the version of the schema the config was written with. Configs
+	// with older versions are migrated when they're decoded.
+	SchemaVersion int `json:"schemaVersion"`
 
-func (h HSV) scalar() gocv.Scalar {
-	return gocv.Scalar{Val1: h.H, Val2: h.S, Val3: h.V}
-}
+	// Type is the kind of pipeline, which determines the stages it runs by default.
+	Type Type `json:"type"`
+
+	// Camera are the camera settings applied when the pipeline becomes active, since vision
+	// pipelines usually need short exposures while driver pipelines need auto exposure.
+	Camera *source.Settings `json:"camera,omitempty"`
+
+	// LEDs are how the hardware lights are controlled when the pipeline becomes active. When
+	// nil, the lights are left as they are, except that driver pipelines turn them off.
+	LEDs *LEDs `json:"leds,omitempty"`
+
+	ColorSpace ColorSpace `json:"colorSpace"`
+
+	// ProcessingScale is the fraction of the capture resolution frames are processed at, so
+	// 0.5 processes a 640x480 capture at 320x240. Results are still in capture coordinates.
+	// Zero or one processes frames at full resolution.
+	ProcessingScale float64 `json:"processingScale"`
+
+	// MinThresh and MaxThresh bound the thresholded color. When the min hue is greater
+	// than the max hue, the hue range wraps around (for example, 170-10 for red).
+	MinThresh HSV `json:"minThresh"`
+	MaxThresh HSV `json:"maxThresh"`
+
+	// SecondaryThresh, if set, is a second color range that's thresholded along with the
+	// first, for example to catch both the lit and shaded faces of a game piece.
+	SecondaryThresh *Range `json:"secondaryThresh,omitempty"`
 
-type Config struct {
-	MinThresh  HSV     `json:"minThresh"`
-	MaxThresh  HSV     `json:"maxThresh"`
 	MinContour float64 `json:"minContour"`
 	MaxContour float64 `json:"maxContour"`
+
+	// MinWidth and MinHeight are the minimum bounding box dimensions of contours in pixels,
+	// used to cheaply discard specks.
+	MinWidth  int `json:"minWidth"`
+	MinHeight int `json:"minHeight"`
+
+	// Retrieval is the contour retrieval mode, and Hierarchy (if set) filters contours by
+	// how they're nested, for example to keep only the inner contour of a hollow target.
+	Retrieval Retrieval  `json:"retrieval,omitempty"`
+	Hierarchy *Hierarchy `json:"hierarchy,omitempty"`
+
+	// AspectRatio is the expected width to height ratio of targets, used to score their
+	// confidence. Zero ignores the aspect ratio.
+	AspectRatio float64 `json:"aspectRatio"`
+
+	SortMode SortMode `json:"sortMode"`
+
+	// MaxTargets is the maximum number of targets returned per frame, best first. Values
+	// less than one are treated as one.
+	MaxTargets int `json:"maxTargets"`
+
+	// Tracking enables following targets between frames, giving each a stable ID.
+	Tracking *Tracking `json:"tracking,omitempty"`
+
+	// HorizontalFOV and VerticalFOV are the camera's field of view in degrees, used to
+	// convert pixel offsets into angles.
+	HorizontalFOV float64 `json:"horizontalFOV"`
+	VerticalFOV   float64 `json:"verticalFOV"`
+
+	// SmoothingTimeConstant is the time constant (in seconds) of the exponential moving
+	// average applied to the target centroid and angles. Zero disables smoothing.
+	SmoothingTimeConstant float64 `json:"smoothingTimeConstant"`
+
+	// LockFrames is the number of frames a lost target stays locked (flagged as stale, at
+	// its last known position) before it's dropped. Zero disables locking.
+	LockFrames int `json:"lockFrames"`
+
+	// Mount enables estimating the ground distance to targets from their pitch, which
+	// requires the vertical FOV or intrinsics.
+	Mount *Mount `json:"mount,omitempty"`
+
+	// Intrinsics and TargetModel enable pose estimation when both are set. The
+	// target model corners must be ordered the same way as Target.Corners. Intrinsics
+	// alone are used instead of the FOV to calculate more accurate target angles.
+	Intrinsics  *Intrinsics  `json:"intrinsics,omitempty"`
+	TargetModel []ModelPoint `json:"targetModel,omitempty"`
+
+	// Adjust enables adjusting the brightness, contrast and white balance of frames before
+	// they're converted to the pipeline's color space.
+	Adjust *Adjust `json:"adjust,omitempty"`
+
+	// Equalize enables equalizing the brightness of frames after they're converted, to
+	// stabilize thresholding under uneven lighting.
+	Equalize *Equalize `json:"equalize,omitempty"`
+
+	// Stereo is the calibration of a pair of cameras, used by stereo pipelines to triangulate
+	// the position of targets. See NewStereo.
+	Stereo *Stereo `json:"stereo,omitempty"`
+
+	// Morph enables the morph stage, which is applied after thresholding.
+	Morph *Morph `json:"morph,omitempty"`
+
+	// Shape enables detecting targets by shape in addition to color in coloredShape pipelines.
+	Shape *Shape `json:"shape,omitempty"`
+
+	// Blob enables detecting targets with a blob detector instead of finding contours.
+	Blob *Blob `json:"blob,omitempty"`
+
+	// Approximation enables simplifying contours before they're filtered.
+	Approximation *Approximation `json:"approximation,omitempty"`
+
+	// Driver configures driver pipelines, which pass frames through without looking for
+	// targets. Only stages that operate on the output frame are run by default.
+	Driver *Driver `json:"driver,omitempty"`
+
+	// Overlay configures what's drawn onto the output frame. When nil, the defaults are used.
+	Overlay *Overlay `json:"overlay,omitempty"`
+
+	// Stages lists the names of the stages to run, in order. When empty, the default
+	// stages (including any optional stages that are configured) are run.
+	Stages []string `json:"stages,omitempty"`
 }
 
 type Pipeline struct {
 	Config Config
+
+	// Stages are run in order on every frame. If nil, the default stages for the config
+	// are created on the first frame.
+	Stages []Stage
+
+	// Intermediate, if set, is called after every stage with the stage name and the working
+	// image, so intermediate images (such as the thresholded mask) can be inspected. The
+	// mat is only valid for the duration of the call.
+	Intermediate func(stage string, mat gocv.Mat)
+
+	// Profiling enables recording how long each stage takes over recent frames. See Profile.
+	Profiling bool
+
+	// Layers, if set, hides parts of the overlay. See AllLayers.
+	Layers *Layers
+
+	latency  rollingAverage
+	profiler profiler
+
+	// mats are reused between frames by the stages.
+	mats matPool
 }
 
-func New(config Config) Pipeline {
-	return Pipeline{
-		Config: config,
+// New creates a pipeline of the config's type, with the stages described by the config.
+func New(config Config) (*Pipeline, error) {
+	if err := config.Type.supported(); err != nil {
+		return nil, err
 	}
+
+	stages, err := newStages(config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create pipeline stages: %w", err)
+	}
+
+	return &Pipeline{
+		Config: config,
+		Stages: stages,
+	}, nil
 }
 
-type SortableContours [][]image.Point
+// calculateCentroid calculates the centroid of a contour from its moments, which are
+// computed directly from the polygon's vertices using Green's theorem, the same way
+// OpenCV calculates the moments of a contour. Degenerate contours with no area use the
+// mean of their points.
+func calculateCentroid(contour []image.Point) image.Point {
+	if len(contour) == 0 {
+		return image.Point{}
+	}
 
-func (s SortableContours) Len() int      { return len(s) }
-func (s SortableContours) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+	var m00, m10, m01 float64
+	for i, j := 0, len(contour)-1; i < len(contour); j, i = i, i+1 {
+		x0, y0 := float64(contour[j].X), float64(contour[j].Y)
+		x1, y1 := float64(contour[i].X), float64(contour[i].Y)
 
-func (s SortableContours) Less(i, j int) bool {
-	if gocv.ContourArea(s[i]) < gocv.ContourArea(s[j]) {
-		return true
+		cross := x0*y1 - x1*y0
+		m00 += cross
+		m10 += cross * (x0 + x1)
+		m01 += cross * (y0 + y1)
 	}
 
-	return false
+	if m00 == 0 {
+		var sumX, sumY int
+		for _, pt := range contour {
+			sumX += pt.X
+			sumY += pt.Y
+		}
+
+		return image.Point{X: sumX / len(contour), Y: sumY / len(contour)}
+	}
+
+	// m00 is twice the signed area, so the 1/6 of the centroid formula becomes 1/3
+	return image.Point{X: int(m10 / (3 * m00)), Y: int(m01 / (3 * m00))}
 }
 
-func calculateCentroid(img gocv.Mat, contour []image.Point) image.Point {
-	mat := gocv.NewMatWithSize(img.Rows(), img.Cols(), gocv.MatTypeCV8U)
-	gocv.FillPoly(&mat, [][]image.Point{contour}, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+// Result is the output of processing a single frame.
+type Result struct {
+	// Timestamp is when the frame was captured.
+	Timestamp time.Time `json:"timestamp"`
+
+	// CaptureTime is when the frame was captured, relative to when the process started. Unlike
+	// Timestamp, it's measured with the monotonic clock, so it doesn't jump when the system
+	// clock is set (such as by NTP once the robot radio is connected).
+	CaptureTime time.Duration `json:"captureTime"`
+
+	// Sequence numbers the frames read from a camera, starting at 1, so consumers can detect
+	// stale or dropped results. It's set by the vision loop, since pipelines are replaced
+	// whenever their config changes.
+	Sequence uint64 `json:"sequence"`
+
+	// Targets are the selected targets, best first. It is empty if no targets were found.
+	Targets []Target `json:"targets"`
+
+	// ContourCount is the number of contours found in the frame, before filtering.
+	ContourCount int `json:"contourCount"`
 
-	moments := gocv.Moments(mat, false)
+	// Latency is the time from when the frame was captured until processing finished, and
+	// AverageLatency is the rolling average of it over recent frames.
+	Latency        time.Duration `json:"latency"`
+	AverageLatency time.Duration `json:"averageLatency"`
 
-	x := int(moments["m10"] / moments["m00"])
-	y := int(moments["m01"] / moments["m00"])
+	// StageDurations is how long each stage took, in the order the stages ran.
+	StageDurations []StageDuration `json:"stageDurations"`
+}
+
+// RotatedRect is the minimum area rectangle around a contour.
+type RotatedRect struct {
+	Center image.Point `json:"center"`
+	Width  int         `json:"width"`
+	Height int         `json:"height"`
 
-	return image.Point{X: x, Y: y}
+	// Angle is the rotation of the rectangle in degrees.
+	Angle float64 `json:"angle"`
 }
 
-func (p Pipeline) ProcessFrame(frame gocv.Mat, outFrame *gocv.Mat) (image.Point, bool) {
-	frameHSV := gocv.NewMat()
-	defer frameHSV.Close()
-	gocv.CvtColor(frame, &frameHSV, gocv.ColorBGRToHSV)
+// Target describes a single contour selected by the pipeline.
+type Target struct {
+	// ID identifies the target across frames, and is only non-zero when tracking is enabled.
+	ID int `json:"id"`
+
+	Centroid image.Point `json:"centroid"`
+
+	// Area is the contour area as a fraction of the frame area.
+	Area float64 `json:"area"`
+
+	// Confidence is how well the target matched the filters, from 0 to 1, so marginal
+	// detections can be ignored.
+	Confidence float64 `json:"confidence"`
+
+	// Offset is the centroid's offset from the center of the frame, normalized to [-1, 1].
+	// Positive offsets are to the right and upwards.
+	OffsetX float64 `json:"offsetX"`
+	OffsetY float64 `json:"offsetY"`
 
-	frameThresh := gocv.NewMat()
-	defer frameThresh.Close()
-	gocv.InRangeWithScalar(frameHSV, p.Config.MinThresh.scalar(), p.Config.MaxThresh.scalar(), &frameThresh)
+	BoundingBox image.Rectangle `json:"boundingBox"`
+	RotatedRect RotatedRect     `json:"rotatedRect"`
 
-	filteredContours := make([][]image.Point, 0)
-	imageArea := float64(frameThresh.Rows() * frameThresh.Cols())
+	// Yaw and Pitch are the angles in degrees from the center of the frame to the centroid,
+	// and are only non-zero when the camera FOV or intrinsics are configured.
+	Yaw   float64 `json:"yaw"`
+	Pitch float64 `json:"pitch"`
 
-	for _, contour := range gocv.FindContours(frameThresh, gocv.RetrievalList, gocv.ChainApproxSimple) {
-		area := gocv.ContourArea(contour)
-		if area < p.Config.MinContour*imageArea || area > p.Config.MaxContour*imageArea {
-			continue
+	// Distance is the estimated ground distance to the target in meters, and is only
+	// non-zero when the camera mount is configured.
+	Distance float64 `json:"distance"`
+
+	// Stale is true when the target wasn't found in this frame, and is being held at its
+	// last known position.
+	Stale bool `json:"stale"`
+
+	// Raw is the unsmoothed centroid, offsets and angles, and is only set when smoothing is enabled.
+	Raw *Measurement `json:"raw,omitempty"`
+
+	// Corners are the four extreme points of the contour, ordered top-left,
+	// top-right, bottom-right, bottom-left.
+	Corners []image.Point `json:"corners"`
+
+	// Pose is the camera-to-target transform, and is only set when pose estimation
+	// is configured.
+	Pose *Pose `json:"pose,omitempty"`
+
+	// Position is the triangulated position of the target centroid in camera coordinates,
+	// in meters, and is only set by stereo pipelines.
+	Position *[3]float64 `json:"position,omitempty"`
+}
+
+// calculateCorners finds the four extreme corners of a contour by taking the
+// points that maximize/minimize the sum and difference of their coordinates.
+func calculateCorners(contour []image.Point) []image.Point {
+	if len(contour) == 0 {
+		return nil
+	}
+
+	topLeft, topRight, bottomRight, bottomLeft := contour[0], contour[0], contour[0], contour[0]
+	for _, pt := range contour[1:] {
+		if pt.X+pt.Y < topLeft.X+topLeft.Y {
+			topLeft = pt
+		}
+		if pt.X-pt.Y > topRight.X-topRight.Y {
+			topRight = pt
+		}
+		if pt.X+pt.Y > bottomRight.X+bottomRight.Y {
+			bottomRight = pt
 		}
+		if pt.X-pt.Y < bottomLeft.X-bottomLeft.Y {
+			bottomLeft = pt
+		}
+	}
+
+	return []image.Point{topLeft, topRight, bottomRight, bottomLeft}
+}
+
+// Close releases the images the pipeline reuses between frames. The pipeline may still be
+// used afterwards, but will have to reallocate them.
+func (p *Pipeline) Close() error {
+	p.mats.close()
+
+	return nil
+}
+
+// statefulStages carry state from one frame to the next, so they depend on seeing every frame
+// in order.
+var statefulStages = map[string]bool{StageTrack: true, StageSmooth: true, StageHysteresis: true}
+
+// Stateful returns whether any of the pipeline's stages carry state from one frame to the next,
+// in which case frames must be processed by this pipeline, in order, rather than split between
+// copies of it.
+func (p *Pipeline) Stateful() bool {
+	if p.Stages == nil {
+		// ProcessFrame creates the default stages
+		for _, name := range defaultStageNames(p.Config) {
+			if statefulStages[name] {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for _, stage := range p.Stages {
+		if statefulStages[stage.Name()] {
+			return true
+		}
+	}
 
-		rect := gocv.MinAreaRect(contour)
-		gocv.Rectangle(outFrame, image.Rectangle{Min: rect.BoundingRect.Min, Max: rect.BoundingRect.Max}, color.RGBA{255, 255, 255, 255}, 2)
+	return false
+}
 
-		filteredContours = append(filteredContours, contour)
+// processStart is when the process started, which capture times are measured from.
+var processStart = time.Now()
+
+// ProcessFrame runs the pipeline stages on the frame, drawing results onto outFrame. The
+// capture time is used to measure the latency of the result. Pipelines are stateful, so
+// ProcessFrame must not be called concurrently.
+func (p *Pipeline) ProcessFrame(frame gocv.Mat, outFrame *gocv.Mat, captured time.Time) Result {
+	if p.Stages == nil {
+		// the default stages can always be created
+		config := p.Config
+		config.Stages = nil
+		p.Stages, _ = newStages(config)
 	}
 
-	sort.Sort(SortableContours(filteredContours))
+	f := &Frame{Input: frame, Output: outFrame, Mat: p.mats.get(), Layers: p.layers(), pool: &p.mats}
+	frame.CopyTo(&f.Mat)
+	defer func() { p.mats.put(f.Mat) }()
+
+	durations := make([]StageDuration, 0, len(p.Stages))
+	for _, stage := range p.Stages {
+		start := time.Now()
+		stage.Process(f)
+		duration := time.Since(start)
+		durations = append(durations, StageDuration{Stage: stage.Name(), Duration: duration})
+		p.Record(stage.Name(), duration)
 
-	if len(filteredContours) > 0 {
-		return calculateCentroid(frameThresh, filteredContours[0]), true
+		if p.Intermediate != nil {
+			p.Intermediate(stage.Name(), f.Mat)
+		}
 	}
 
-	return image.Point{}, false
+	latency := time.Since(captured)
+
+	return Result{
+		Timestamp:      captured,
+		CaptureTime:    captured.Sub(processStart),
+		Targets:        f.Targets,
+		ContourCount:   f.ContourCount,
+		Latency:        latency,
+		AverageLatency: p.latency.add(latency),
+		StageDurations: durations,
+	}
 }
@@ -0,0 +1,65 @@
+package pipeline
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// BenchmarkProcessFrame measures processing a 640x480 frame with two lit targets, which is
+// what the pipeline's Mats are reused between frames for.
+func BenchmarkProcessFrame(b *testing.B) {
+	p, err := New(Config{
+		Type:       TypeReflective,
+		MinThresh:  HSV{H: 50, S: 100, V: 100},
+		MaxThresh:  HSV{H: 70, S: 255, V: 255},
+		MinContour: 0.001,
+		MaxContour: 1,
+		MaxTargets: 2,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer p.Close()
+
+	frame := gocv.NewMatWithSize(480, 640, gocv.MatTypeCV8UC3)
+	defer frame.Close()
+
+	green := color.RGBA{G: 255}
+	gocv.Rectangle(&frame, image.Rect(100, 100, 160, 140), green, -1)
+	gocv.Rectangle(&frame, image.Rect(400, 200, 440, 300), green, -1)
+
+	out := gocv.NewMat()
+	defer out.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.ProcessFrame(frame, &out, time.Now())
+	}
+}
+
+// BenchmarkCalculateCentroid measures finding the centroid of a contour with as many points
+// as a large, unapproximated target.
+func BenchmarkCalculateCentroid(b *testing.B) {
+	contour := make([]image.Point, 0, 400)
+	for x := 0; x < 100; x++ {
+		contour = append(contour, image.Point{X: x, Y: 0})
+	}
+	for y := 0; y < 100; y++ {
+		contour = append(contour, image.Point{X: 100, Y: y})
+	}
+	for x := 100; x > 0; x-- {
+		contour = append(contour, image.Point{X: x, Y: 100})
+	}
+	for y := 100; y > 0; y-- {
+		contour = append(contour, image.Point{X: 0, Y: y})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		calculateCentroid(contour)
+	}
+}
@@ -0,0 +1,56 @@
+package pipeline
+
+import "gocv.io/x/gocv"
+
+// matPool holds Mats between frames so stages can reuse them instead of allocating new
+// images every frame. Mats from the pool hold the contents of a previous frame, but since
+// gocv functions only reallocate their destination when its size or type changes, reusing
+// them avoids allocating once the pipeline has processed its first frame.
+type matPool struct {
+	mats []gocv.Mat
+}
+
+// get returns a Mat from the pool, or a new Mat if the pool is empty.
+func (p *matPool) get() gocv.Mat {
+	if len(p.mats) == 0 {
+		return gocv.NewMat()
+	}
+
+	m := p.mats[len(p.mats)-1]
+	p.mats = p.mats[:len(p.mats)-1]
+
+	return m
+}
+
+// put returns a Mat to the pool.
+func (p *matPool) put(m gocv.Mat) {
+	p.mats = append(p.mats, m)
+}
+
+// close closes every Mat in the pool.
+func (p *matPool) close() {
+	for _, m := range p.mats {
+		m.Close()
+	}
+	p.mats = nil
+}
+
+// NewMat returns a Mat for a stage to write into, reused from previous frames when possible.
+// Its contents are undefined. It must be passed to SetMat or Release once the stage is done with it.
+func (f *Frame) NewMat() gocv.Mat {
+	if f.pool == nil {
+		return gocv.NewMat()
+	}
+
+	return f.pool.get()
+}
+
+// Release returns a Mat from NewMat that's no longer needed.
+func (f *Frame) Release(m gocv.Mat) {
+	if f.pool == nil {
+		m.Close()
+		return
+	}
+
+	f.pool.put(m)
+}
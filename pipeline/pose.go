@@ -0,0 +1,247 @@
+package pipeline
+
+import (
+	"image"
+	"math"
+)
+
+const StagePose = "pose"
+
+// Intrinsics describes the pinhole camera model of a calibrated camera, in pixels.
+type Intrinsics struct {
+	Fx float64 `json:"fx"`
+	Fy float64 `json:"fy"`
+	Cx float64 `json:"cx"`
+	Cy float64 `json:"cy"`
+
+	// Distortion are the lens distortion coefficients k1, k2, p1, p2 and optionally k3, in
+	// the same order OpenCV uses. Missing coefficients are treated as zero.
+	Distortion []float64 `json:"distortion,omitempty"`
+}
+
+// undistortIterations is the number of iterations used to invert the distortion model,
+// which is the same as OpenCV's undistortPoints.
+const undistortIterations = 5
+
+// undistort converts a pixel to normalized image coordinates (x/z, y/z in camera coordinates),
+// removing lens distortion.
+func (in Intrinsics) undistort(pt image.Point) (x, y float64) {
+	return in.undistortPixel(float64(pt.X), float64(pt.Y))
+}
+
+// undistortPixel is undistort for a pixel with sub-pixel precision.
+func (in Intrinsics) undistortPixel(u, v float64) (x, y float64) {
+	x = (u - in.Cx) / in.Fx
+	y = (v - in.Cy) / in.Fy
+
+	var k [5]float64
+	copy(k[:], in.Distortion)
+	if k == [5]float64{} {
+		return x, y
+	}
+
+	k1, k2, p1, p2, k3 := k[0], k[1], k[2], k[3], k[4]
+
+	// the distortion model can't be inverted analytically, so iterate from the distorted point
+	x0, y0 := x, y
+	for i := 0; i < undistortIterations; i++ {
+		r2 := x*x + y*y
+		radial := 1 + ((k3*r2+k2)*r2+k1)*r2
+		dx := 2*p1*x*y + p2*(r2+2*x*x)
+		dy := p1*(r2+2*y*y) + 2*p2*x*y
+
+		x = (x0 - dx) / radial
+		y = (y0 - dy) / radial
+	}
+
+	return x, y
+}
+
+// ModelPoint is a point on the (planar) target, in meters.
+type ModelPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Pose is the camera-to-target transform, in the same form solvePnP produces.
+type Pose struct {
+	// Translation is the position of the target origin in camera coordinates, in meters.
+	Translation [3]float64 `json:"translation"`
+
+	// Rotation is the rotation of the target in camera coordinates as a Rodrigues vector.
+	Rotation [3]float64 `json:"rotation"`
+}
+
+// estimatePose solves the perspective-n-point problem for a planar target by
+// computing the homography between the model and the normalized image points
+// and decomposing it into a rotation and translation. The model and image points
+// must correspond by index, and at least four points are required.
+func estimatePose(intrinsics Intrinsics, model []ModelPoint, points []image.Point) (Pose, bool) {
+	if len(model) < 4 || len(model) != len(points) || intrinsics.Fx == 0 || intrinsics.Fy == 0 {
+		return Pose{}, false
+	}
+
+	normalized := make([][2]float64, len(points))
+	for i, pt := range points {
+		normalized[i][0], normalized[i][1] = intrinsics.undistort(pt)
+	}
+
+	h, ok := fitHomography(model, normalized)
+	if !ok {
+		return Pose{}, false
+	}
+
+	return decomposeHomography(h), true
+}
+
+type poseStage struct {
+	intrinsics Intrinsics
+	model      []ModelPoint
+}
+
+func (poseStage) Name() string { return StagePose }
+
+func (s poseStage) Process(f *Frame) {
+	for i := range f.Targets {
+		if pose, ok := estimatePose(s.intrinsics, s.model, f.Targets[i].Corners); ok {
+			f.Targets[i].Pose = &pose
+		}
+	}
+}
+
+// decomposeHomography returns the pose of a planar target from the homography between the
+// model and the normalized image points, as returned by fitHomography.
+func decomposeHomography(h []float64) Pose {
+	h1 := [3]float64{h[0], h[3], h[6]}
+	h2 := [3]float64{h[1], h[4], h[7]}
+	h3 := [3]float64{h[2], h[5], 1}
+
+	scale := 2 / (norm(h1) + norm(h2))
+	if h3[2]*scale < 0 { // the target must be in front of the camera
+		scale = -scale
+	}
+
+	r1 := normalize(mul(h1, scale))
+	r2 := mul(h2, scale)
+	r2 = normalize(sub(r2, mul(r1, dot(r1, r2))))
+	r3 := cross(r1, r2)
+
+	return Pose{
+		Translation: mul(h3, scale),
+		Rotation: rodrigues([3][3]float64{
+			{r1[0], r2[0], r3[0]},
+			{r1[1], r2[1], r3[1]},
+			{r1[2], r2[2], r3[2]},
+		}),
+	}
+}
+
+// rodrigues converts a rotation matrix into its axis-angle vector representation.
+func rodrigues(r [3][3]float64) [3]float64 {
+	cos := (r[0][0] + r[1][1] + r[2][2] - 1) / 2
+	theta := math.Acos(math.Max(-1, math.Min(1, cos)))
+
+	axis := [3]float64{r[2][1] - r[1][2], r[0][2] - r[2][0], r[1][0] - r[0][1]}
+	sin := math.Sin(theta)
+	if sin < 1e-9 {
+		return [3]float64{}
+	}
+
+	return mul(axis, theta/(2*sin))
+}
+
+// fitHomography finds the homography that maps the model points to the image points in the
+// least squares sense, as its first 8 entries in row-major order (the last entry is 1).
+func fitHomography(model []ModelPoint, points [][2]float64) ([]float64, bool) {
+	// build the equations for the 8 unknown homography entries (h33 = 1)
+	rows := make([][]float64, 0, 2*len(model))
+	rhs := make([]float64, 0, 2*len(model))
+	for i, m := range model {
+		x, y := points[i][0], points[i][1]
+
+		rows = append(rows,
+			[]float64{m.X, m.Y, 1, 0, 0, 0, -x * m.X, -x * m.Y},
+			[]float64{0, 0, 0, m.X, m.Y, 1, -y * m.X, -y * m.Y},
+		)
+		rhs = append(rhs, x, y)
+	}
+
+	return leastSquares(rows, rhs)
+}
+
+// leastSquares solves the overdetermined linear system ax = b in the least squares sense by
+// solving its normal equations.
+func leastSquares(a [][]float64, b []float64) ([]float64, bool) {
+	n := len(a[0])
+	ata := make([][]float64, n)
+	atb := make([]float64, n)
+	for j := range ata {
+		ata[j] = make([]float64, n)
+	}
+
+	for r, row := range a {
+		for j := 0; j < n; j++ {
+			for k := 0; k < n; k++ {
+				ata[j][k] += row[j] * row[k]
+			}
+			atb[j] += row[j] * b[r]
+		}
+	}
+
+	return solve(ata, atb)
+}
+
+// solve solves the square linear system ax = b using gaussian elimination with partial
+// pivoting. It modifies a and b.
+func solve(a [][]float64, b []float64) ([]float64, bool) {
+	n := len(b)
+	x := make([]float64, n)
+
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return x, false
+		}
+
+		a[col], a[pivot] = a[pivot], a[col]
+		b[col], b[pivot] = b[pivot], b[col]
+
+		for row := col + 1; row < n; row++ {
+			f := a[row][col] / a[col][col]
+			for k := col; k < n; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+			b[row] -= f * b[col]
+		}
+	}
+
+	for row := n - 1; row >= 0; row-- {
+		sum := b[row]
+		for k := row + 1; k < n; k++ {
+			sum -= a[row][k] * x[k]
+		}
+		x[row] = sum / a[row][row]
+	}
+
+	return x, true
+}
+
+func dot(a, b [3]float64) float64 { return a[0]*b[0] + a[1]*b[1] + a[2]*b[2] }
+func norm(a [3]float64) float64   { return math.Sqrt(dot(a, a)) }
+
+func mul(a [3]float64, s float64) [3]float64 { return [3]float64{a[0] * s, a[1] * s, a[2] * s} }
+func sub(a, b [3]float64) [3]float64         { return [3]float64{a[0] - b[0], a[1] - b[1], a[2] - b[2]} }
+func normalize(a [3]float64) [3]float64      { return mul(a, 1/norm(a)) }
+
+func cross(a, b [3]float64) [3]float64 {
+	return [3]float64{
+		a[1]*b[2] - a[2]*b[1],
+		a[2]*b[0] - a[0]*b[2],
+		a[0]*b[1] - a[1]*b[0],
+	}
+}
@@ -0,0 +1,78 @@
+package pipeline
+
+import (
+	"sync"
+	"time"
+)
+
+// StageProfile summarizes how long a stage took over recent frames.
+type StageProfile struct {
+	Stage   string        `json:"stage"`
+	Min     time.Duration `json:"min"`
+	Average time.Duration `json:"average"`
+	Max     time.Duration `json:"max"`
+
+	// Frames is the number of frames the profile covers.
+	Frames int `json:"frames"`
+}
+
+// profiler records stage durations over the last latencyWindow frames. The zero value is
+// ready to use.
+type profiler struct {
+	mu sync.Mutex
+
+	stages  []string
+	windows map[string]*rollingAverage
+}
+
+func (p *profiler) record(stage string, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	window, ok := p.windows[stage]
+	if !ok {
+		if p.windows == nil {
+			p.windows = make(map[string]*rollingAverage)
+		}
+
+		window = &rollingAverage{}
+		p.windows[stage] = window
+		p.stages = append(p.stages, stage)
+	}
+
+	window.add(d)
+}
+
+// profiles returns the profile of every recorded stage, in the order they were first recorded.
+func (p *profiler) profiles() []StageProfile {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	profiles := make([]StageProfile, 0, len(p.stages))
+	for _, stage := range p.stages {
+		window := p.windows[stage]
+		profiles = append(profiles, StageProfile{
+			Stage:   stage,
+			Min:     window.min(),
+			Average: window.sum / time.Duration(window.count),
+			Max:     window.max(),
+			Frames:  window.count,
+		})
+	}
+
+	return profiles
+}
+
+// Record records how long a step outside the pipeline (such as encoding the output frame)
+// took, so it's included in the profile. It does nothing unless profiling is enabled.
+func (p *Pipeline) Record(step string, d time.Duration) {
+	if p.Profiling {
+		p.profiler.record(step, d)
+	}
+}
+
+// Profile returns the min, average and max duration of each stage over recent frames. It's
+// empty unless profiling is enabled, and is safe to call while frames are being processed.
+func (p *Pipeline) Profile() []StageProfile {
+	return p.profiler.profiles()
+}
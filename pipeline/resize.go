@@ -0,0 +1,51 @@
+package pipeline
+
+import (
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+const StageResize = "resize"
+
+// resizeStage downscales the working image so the following stages have fewer pixels to
+// process. Contours found in the downscaled image are mapped back to input frame coordinates.
+type resizeStage struct {
+	scale float64
+}
+
+func (resizeStage) Name() string { return StageResize }
+
+func (s resizeStage) Process(f *Frame) {
+	if s.scale <= 0 || s.scale >= 1 {
+		return
+	}
+
+	resized := f.NewMat()
+	gocv.Resize(f.Mat, &resized, image.Point{}, s.scale, s.scale, gocv.InterpolationArea)
+	f.SetMat(resized)
+	f.Scale = s.scale
+}
+
+// scale returns the size of the working image relative to the input frame.
+func (f *Frame) scale() float64 {
+	if f.Scale <= 0 {
+		return 1
+	}
+
+	return f.Scale
+}
+
+// unscale maps points in the working image back to input frame coordinates.
+func (f *Frame) unscale(points []image.Point) []image.Point {
+	scale := f.scale()
+	if scale == 1 {
+		return points
+	}
+
+	for i, pt := range points {
+		points[i] = image.Point{X: int(float64(pt.X) / scale), Y: int(float64(pt.Y) / scale)}
+	}
+
+	return points
+}
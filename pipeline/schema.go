@@ -0,0 +1,167 @@
+package pipeline
+
+// FieldType is the type of a config field's value.
+type FieldType string
+
+const (
+	FieldNumber  FieldType = "number"
+	FieldInteger FieldType = "integer"
+	FieldBoolean FieldType = "boolean"
+	FieldEnum    FieldType = "enum"
+)
+
+// Field describes a tunable config field, so user interfaces can generate controls for it.
+type Field struct {
+	// Path is the dotted JSON path of the field, such as "minThresh.h". Fields inside optional
+	// objects (such as "morph.kernelSize") only apply when the object is set.
+	Path string    `json:"path"`
+	Type FieldType `json:"type"`
+
+	// Group is the name of the group of related fields the field belongs to.
+	Group string `json:"group"`
+	Unit  string `json:"unit,omitempty"`
+
+	// Min and Max are the valid range of number and integer fields, if they're bounded.
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+
+	// Options are the valid values of enum fields.
+	Options []string `json:"options,omitempty"`
+
+	Description string `json:"description"`
+}
+
+func bound(v float64) *float64 { return &v }
+
+func colorSpaceOptions() []string {
+	options := make([]string, len(ColorSpaces))
+	for i, c := range ColorSpaces {
+		options[i] = string(c)
+	}
+
+	return options
+}
+
+func morphOptions() []string {
+	options := make([]string, len(MorphOperations))
+	for i, o := range MorphOperations {
+		options[i] = string(o)
+	}
+
+	return options
+}
+
+func typeOptions() []string {
+	options := make([]string, len(Types))
+	for i, t := range Types {
+		options[i] = string(t)
+	}
+
+	return options
+}
+
+// schema describes the tunable config fields. It's the single source of the valid ranges of
+// config values.
+var schema = []Field{
+	{Path: "type", Type: FieldEnum, Group: "pipeline", Options: typeOptions(), Description: "Kind of pipeline"},
+
+	{Path: "colorSpace", Type: FieldEnum, Group: "threshold", Options: colorSpaceOptions(),
+		Description: "Color space frames are converted to before thresholding"},
+	{Path: "minThresh.h", Type: FieldNumber, Group: "threshold", Min: bound(0), Max: bound(maxSaturationValue), Description: "Minimum hue (up to 180 in color spaces with hue), or first channel"},
+	{Path: "minThresh.s", Type: FieldNumber, Group: "threshold", Min: bound(0), Max: bound(255), Description: "Minimum saturation, or second channel"},
+	{Path: "minThresh.v", Type: FieldNumber, Group: "threshold", Min: bound(0), Max: bound(255), Description: "Minimum value, or third channel"},
+	{Path: "maxThresh.h", Type: FieldNumber, Group: "threshold", Min: bound(0), Max: bound(maxSaturationValue), Description: "Maximum hue (up to 180 in color spaces with hue, wrapping around when less than the minimum hue), or first channel"},
+	{Path: "maxThresh.s", Type: FieldNumber, Group: "threshold", Min: bound(0), Max: bound(255), Description: "Maximum saturation, or second channel"},
+	{Path: "maxThresh.v", Type: FieldNumber, Group: "threshold", Min: bound(0), Max: bound(255), Description: "Maximum value, or third channel"},
+	{Path: "secondaryThresh.min.h", Type: FieldNumber, Group: "threshold", Min: bound(0), Max: bound(maxSaturationValue), Description: "Minimum hue (up to 180 in color spaces with hue), or first channel, of the secondary range"},
+	{Path: "secondaryThresh.min.s", Type: FieldNumber, Group: "threshold", Min: bound(0), Max: bound(255), Description: "Minimum saturation, or second channel, of the secondary range"},
+	{Path: "secondaryThresh.min.v", Type: FieldNumber, Group: "threshold", Min: bound(0), Max: bound(255), Description: "Minimum value, or third channel, of the secondary range"},
+	{Path: "secondaryThresh.max.h", Type: FieldNumber, Group: "threshold", Min: bound(0), Max: bound(maxSaturationValue), Description: "Maximum hue (up to 180 in color spaces with hue), or first channel, of the secondary range"},
+	{Path: "secondaryThresh.max.s", Type: FieldNumber, Group: "threshold", Min: bound(0), Max: bound(255), Description: "Maximum saturation, or second channel, of the secondary range"},
+	{Path: "secondaryThresh.max.v", Type: FieldNumber, Group: "threshold", Min: bound(0), Max: bound(255), Description: "Maximum value, or third channel, of the secondary range"},
+
+	{Path: "processingScale", Type: FieldNumber, Group: "preprocess", Min: bound(0), Max: bound(1), Description: "Fraction of the capture resolution frames are processed at"},
+	{Path: "adjust.brightness", Type: FieldNumber, Group: "preprocess", Min: bound(-255), Max: bound(255), Description: "Brightness added to every channel"},
+	{Path: "adjust.contrast", Type: FieldNumber, Group: "preprocess", Min: bound(0), Max: bound(10), Description: "Contrast multiplier"},
+	{Path: "adjust.whiteBalance.r", Type: FieldNumber, Group: "preprocess", Min: bound(0), Max: bound(10), Description: "Red gain"},
+	{Path: "adjust.whiteBalance.g", Type: FieldNumber, Group: "preprocess", Min: bound(0), Max: bound(10), Description: "Green gain"},
+	{Path: "adjust.whiteBalance.b", Type: FieldNumber, Group: "preprocess", Min: bound(0), Max: bound(10), Description: "Blue gain"},
+	{Path: "equalize.clipLimit", Type: FieldNumber, Group: "preprocess", Min: bound(0), Max: bound(40), Description: "Contrast limit of brightness equalization"},
+	{Path: "equalize.tileSize", Type: FieldInteger, Group: "preprocess", Min: bound(0), Max: bound(64), Description: "Number of equalization tiles in each direction"},
+
+	{Path: "morph.operation", Type: FieldEnum, Group: "morph", Options: morphOptions(),
+		Description: "Morphological operation applied after thresholding"},
+	{Path: "morph.kernelSize", Type: FieldInteger, Group: "morph", Unit: "px", Min: bound(0), Max: bound(31), Description: "Size of the morphological operation"},
+
+	{Path: "minContour", Type: FieldNumber, Group: "filter", Min: bound(0), Max: bound(1), Description: "Minimum contour area as a fraction of the frame"},
+	{Path: "maxContour", Type: FieldNumber, Group: "filter", Min: bound(0), Max: bound(1), Description: "Maximum contour area as a fraction of the frame"},
+	{Path: "minWidth", Type: FieldInteger, Group: "filter", Unit: "px", Min: bound(0), Description: "Minimum contour bounding box width"},
+	{Path: "minHeight", Type: FieldInteger, Group: "filter", Unit: "px", Min: bound(0), Description: "Minimum contour bounding box height"},
+	{Path: "retrieval", Type: FieldEnum, Group: "filter", Options: []string{string(RetrievalList), string(RetrievalExternal)}, Description: "Contour retrieval mode"},
+	{Path: "hierarchy.level", Type: FieldEnum, Group: "filter", Options: []string{"", string(HierarchyOuter), string(HierarchyInner)}, Description: "Level of nested contours to keep"},
+	{Path: "hierarchy.holes", Type: FieldInteger, Group: "filter", Min: bound(0), Description: "Exact number of holes in kept contours"},
+	{Path: "aspectRatio", Type: FieldNumber, Group: "filter", Min: bound(0), Description: "Expected target width to height ratio, used to score confidence"},
+
+	{Path: "shape.type", Type: FieldEnum, Group: "shape", Options: []string{string(ShapeCircle), string(ShapePolygon)}, Description: "Shape of targets"},
+	{Path: "shape.minRadius", Type: FieldInteger, Group: "shape", Unit: "px", Min: bound(0), Description: "Minimum circle radius"},
+	{Path: "shape.maxRadius", Type: FieldInteger, Group: "shape", Unit: "px", Min: bound(0), Description: "Maximum circle radius, or zero for no maximum"},
+	{Path: "shape.minDistance", Type: FieldNumber, Group: "shape", Unit: "px", Min: bound(0), Description: "Minimum distance between circle centers"},
+	{Path: "shape.edgeThreshold", Type: FieldNumber, Group: "shape", Min: bound(0), Max: bound(255), Description: "High threshold of circle edge detection"},
+	{Path: "shape.accumulatorThreshold", Type: FieldNumber, Group: "shape", Min: bound(0), Description: "Votes needed to detect a circle, where lower values detect more (possibly false) circles"},
+	{Path: "shape.sides", Type: FieldInteger, Group: "shape", Min: bound(3), Max: bound(32), Description: "Number of polygon sides"},
+	{Path: "shape.epsilon", Type: FieldNumber, Group: "shape", Min: bound(0), Max: bound(1), Description: "Polygon approximation accuracy as a fraction of the perimeter"},
+	{Path: "blob.minCircularity", Type: FieldNumber, Group: "shape", Min: bound(0), Max: bound(1), Description: "Minimum blob circularity"},
+	{Path: "blob.maxCircularity", Type: FieldNumber, Group: "shape", Min: bound(0), Max: bound(1), Description: "Maximum blob circularity"},
+	{Path: "blob.minInertia", Type: FieldNumber, Group: "shape", Min: bound(0), Max: bound(1), Description: "Minimum blob inertia ratio, where 1 is a circle and 0 is a line"},
+	{Path: "blob.maxInertia", Type: FieldNumber, Group: "shape", Min: bound(0), Max: bound(1), Description: "Maximum blob inertia ratio, where 1 is a circle and 0 is a line"},
+	{Path: "blob.minConvexity", Type: FieldNumber, Group: "shape", Min: bound(0), Max: bound(1), Description: "Minimum fraction of their convex hull blobs fill"},
+	{Path: "blob.maxConvexity", Type: FieldNumber, Group: "shape", Min: bound(0), Max: bound(1), Description: "Maximum fraction of their convex hull blobs fill"},
+	{Path: "blob.minDistance", Type: FieldNumber, Group: "shape", Unit: "px", Min: bound(0), Description: "Minimum distance between blob centers"},
+	{Path: "approximation.convexHull", Type: FieldBoolean, Group: "shape", Description: "Replace contours with their convex hull"},
+	{Path: "approximation.epsilon", Type: FieldNumber, Group: "shape", Min: bound(0), Max: bound(1), Description: "Contour approximation accuracy as a fraction of the perimeter"},
+
+	{Path: "sortMode", Type: FieldEnum, Group: "targets",
+		Options:     []string{string(SortLargest), string(SortSmallest), string(SortHighest), string(SortLowest), string(SortLeftmost), string(SortRightmost), string(SortClosest)},
+		Description: "Which targets are selected first"},
+	{Path: "maxTargets", Type: FieldInteger, Group: "targets", Min: bound(0), Max: bound(32), Description: "Maximum number of targets per frame, where zero is one"},
+	{Path: "smoothingTimeConstant", Type: FieldNumber, Group: "targets", Unit: "s", Min: bound(0), Max: bound(10), Description: "Time constant of target smoothing, or zero to disable it"},
+	{Path: "lockFrames", Type: FieldInteger, Group: "targets", Min: bound(0), Max: bound(300), Description: "Frames a lost target stays locked"},
+	{Path: "tracking.maxDistance", Type: FieldNumber, Group: "targets", Min: bound(0), Max: bound(1), Description: "Furthest a tracked target can move between frames, as a fraction of the frame width"},
+	{Path: "tracking.maxMissed", Type: FieldInteger, Group: "targets", Min: bound(0), Description: "Frames a tracked target can be unseen before its ID is retired"},
+
+	{Path: "camera.width", Type: FieldInteger, Group: "camera", Unit: "px", Min: bound(1), Description: "Capture width, or the closest the camera supports"},
+	{Path: "camera.height", Type: FieldInteger, Group: "camera", Unit: "px", Min: bound(1), Description: "Capture height, or the closest the camera supports"},
+	{Path: "camera.fps", Type: FieldNumber, Group: "camera", Unit: "fps", Min: bound(1), Max: bound(240), Description: "Capture frame rate, or the closest the camera supports"},
+	{Path: "camera.autoExposure", Type: FieldBoolean, Group: "camera", Description: "Automatic exposure"},
+	{Path: "camera.exposure", Type: FieldNumber, Group: "camera", Min: bound(0), Description: "Exposure, in camera driver units"},
+	{Path: "camera.gain", Type: FieldNumber, Group: "camera", Min: bound(0), Description: "Gain, in camera driver units"},
+	{Path: "camera.brightness", Type: FieldNumber, Group: "camera", Description: "Brightness, in camera driver units"},
+	{Path: "camera.whiteBalance", Type: FieldNumber, Group: "camera", Unit: "K", Min: bound(0), Description: "White balance color temperature"},
+	{Path: "leds.mode", Type: FieldEnum, Group: "camera", Options: []string{string(LEDOff), string(LEDOn), string(LEDBrightness), string(LEDBlink)},
+		Description: "How the lights are controlled while the pipeline is active"},
+	{Path: "leds.brightness", Type: FieldNumber, Group: "camera", Min: bound(0), Max: bound(1), Description: "Brightness of the lights"},
+	{Path: "leds.blinkPeriod", Type: FieldNumber, Group: "camera", Unit: "s", Min: bound(0), Max: bound(10), Description: "Time the lights take to blink on and off"},
+	{Path: "horizontalFOV", Type: FieldNumber, Group: "camera", Unit: "deg", Min: bound(0), Max: bound(180), Description: "Horizontal field of view"},
+	{Path: "verticalFOV", Type: FieldNumber, Group: "camera", Unit: "deg", Min: bound(0), Max: bound(180), Description: "Vertical field of view"},
+	{Path: "mount.height", Type: FieldNumber, Group: "camera", Unit: "m", Min: bound(0), Description: "Height of the camera from the ground"},
+	{Path: "mount.pitch", Type: FieldNumber, Group: "camera", Unit: "deg", Min: bound(-90), Max: bound(90), Description: "Angle of the camera above horizontal"},
+	{Path: "mount.targetHeight", Type: FieldNumber, Group: "camera", Unit: "m", Min: bound(0), Description: "Height of the target from the ground"},
+
+	{Path: "overlay.disabled", Type: FieldBoolean, Group: "output", Description: "Disable drawing onto the output frame"},
+	{Path: "overlay.targetsOnly", Type: FieldBoolean, Group: "output", Description: "Only draw selected targets"},
+	{Path: "overlay.thickness", Type: FieldInteger, Group: "output", Unit: "px", Min: bound(0), Max: bound(20), Description: "Line thickness"},
+	{Path: "overlay.rectangleColor.r", Type: FieldInteger, Group: "output", Min: bound(0), Max: bound(255), Description: "Red component of the target rectangle color"},
+	{Path: "overlay.rectangleColor.g", Type: FieldInteger, Group: "output", Min: bound(0), Max: bound(255), Description: "Green component of the target rectangle color"},
+	{Path: "overlay.rectangleColor.b", Type: FieldInteger, Group: "output", Min: bound(0), Max: bound(255), Description: "Blue component of the target rectangle color"},
+	{Path: "overlay.crosshairColor.r", Type: FieldInteger, Group: "output", Min: bound(0), Max: bound(255), Description: "Red component of the crosshair color"},
+	{Path: "overlay.crosshairColor.g", Type: FieldInteger, Group: "output", Min: bound(0), Max: bound(255), Description: "Green component of the crosshair color"},
+	{Path: "overlay.crosshairColor.b", Type: FieldInteger, Group: "output", Min: bound(0), Max: bound(255), Description: "Blue component of the crosshair color"},
+	{Path: "overlay.fill", Type: FieldBoolean, Group: "output", Description: "Fill contours instead of outlining them"},
+	{Path: "driver.brightness", Type: FieldNumber, Group: "output", Min: bound(0), Max: bound(10), Description: "Driver mode brightness multiplier"},
+	{Path: "driver.crosshair", Type: FieldBoolean, Group: "output", Description: "Draw a crosshair in driver mode"},
+}
+
+// Schema describes the tunable config fields.
+func Schema() []Field {
+	return append([]Field(nil), schema...)
+}
@@ -0,0 +1,143 @@
+package pipeline
+
+import (
+	"image"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// ShapeType is the kind of shape a pipeline detects, in addition to color.
+type ShapeType string
+
+const (
+	// ShapeCircle detects circles in the thresholded image using a hough transform,
+	// replacing contour detection.
+	ShapeCircle ShapeType = "circle"
+
+	// ShapePolygon only keeps contours that approximate to a polygon with the
+	// configured number of sides.
+	ShapePolygon ShapeType = "polygon"
+)
+
+// Shape configures shape detection.
+type Shape struct {
+	Type ShapeType `json:"type"`
+
+	// MinRadius and MaxRadius bound the radius (in pixels) of detected circles. A max
+	// radius of zero means no upper bound.
+	MinRadius int `json:"minRadius"`
+	MaxRadius int `json:"maxRadius"`
+
+	// MinDistance is the minimum distance (in pixels) between the centers of detected
+	// circles.
+	MinDistance float64 `json:"minDistance"`
+
+	// EdgeThreshold and AccumulatorThreshold are the canny edge detector high threshold and
+	// the accumulator threshold of the hough transform. Lower accumulator thresholds detect
+	// more (possibly false) circles.
+	EdgeThreshold        float64 `json:"edgeThreshold"`
+	AccumulatorThreshold float64 `json:"accumulatorThreshold"`
+
+	// Sides is the number of sides polygons must have.
+	Sides int `json:"sides"`
+
+	// Epsilon is the maximum distance between a contour and its approximated polygon, as a
+	// fraction of the contour perimeter.
+	Epsilon float64 `json:"epsilon"`
+}
+
+const (
+	StageCircles  = "circles"
+	StagePolygons = "polygons"
+)
+
+// circlePoints is the number of points used to approximate a detected circle as a contour.
+const circlePoints = 32
+
+type circlesStage struct {
+	shape Shape
+}
+
+func (circlesStage) Name() string { return StageCircles }
+
+func (s circlesStage) Process(f *Frame) {
+	blurred := f.NewMat()
+	defer f.Release(blurred)
+	gocv.GaussianBlur(f.Mat, &blurred, image.Point{X: 9, Y: 9}, 2, 2, gocv.BorderDefault)
+
+	// the shape is configured in input frame pixels, but circles are found in the working image
+	scale := f.scale()
+
+	minDistance := s.shape.MinDistance
+	if minDistance <= 0 {
+		minDistance = float64(f.Size().Y) / 8
+	}
+	minDistance *= scale
+
+	edgeThreshold, accumulatorThreshold := s.shape.EdgeThreshold, s.shape.AccumulatorThreshold
+	if edgeThreshold <= 0 {
+		edgeThreshold = 100
+	}
+	if accumulatorThreshold <= 0 {
+		accumulatorThreshold = 30
+	}
+
+	circles := f.NewMat()
+	defer f.Release(circles)
+	gocv.HoughCirclesWithParams(blurred, &circles, gocv.HoughGradient, 1, minDistance,
+		edgeThreshold, accumulatorThreshold, int(float64(s.shape.MinRadius)*scale), int(float64(s.shape.MaxRadius)*scale))
+
+	f.Contours = f.Contours[:0]
+	for i := 0; i < circles.Cols(); i++ {
+		x := float64(circles.GetFloatAt(0, i*3)) / scale
+		y := float64(circles.GetFloatAt(0, i*3+1)) / scale
+		r := float64(circles.GetFloatAt(0, i*3+2)) / scale
+
+		f.Contours = append(f.Contours, Contour{Points: circleContour(x, y, r), Area: math.Pi * r * r})
+	}
+
+	f.ContourCount = len(f.Contours)
+}
+
+// circleContour approximates a circle as a contour.
+func circleContour(x, y, r float64) []image.Point {
+	points := make([]image.Point, 0, circlePoints)
+	for j := 0; j < circlePoints; j++ {
+		theta := 2 * math.Pi * float64(j) / circlePoints
+		points = append(points, image.Point{
+			X: int(math.Round(x + r*math.Cos(theta))),
+			Y: int(math.Round(y + r*math.Sin(theta))),
+		})
+	}
+
+	return points
+}
+
+type polygonsStage struct {
+	shape Shape
+}
+
+func (polygonsStage) Name() string { return StagePolygons }
+
+func (s polygonsStage) Process(f *Frame) {
+	filtered := f.Contours[:0]
+	for _, c := range f.Contours {
+		approx := gocv.ApproxPolyDP(c.Points, s.shape.Epsilon*gocv.ArcLength(c.Points, true), true)
+		if len(approx) != s.shape.Sides {
+			continue
+		}
+
+		filtered = append(filtered, c)
+	}
+
+	f.Contours = filtered
+}
+
+func shapeOrZero(shape *Shape) Shape {
+	if shape == nil {
+		return Shape{}
+	}
+
+	return *shape
+}
@@ -0,0 +1,63 @@
+package pipeline
+
+import (
+	"image"
+	"math"
+	"time"
+)
+
+// Measurement is the raw (unsmoothed) position of a target.
+type Measurement struct {
+	Centroid image.Point `json:"centroid"`
+	OffsetX  float64     `json:"offsetX"`
+	OffsetY  float64     `json:"offsetY"`
+	Yaw      float64     `json:"yaw"`
+	Pitch    float64     `json:"pitch"`
+}
+
+// smoother is an exponential moving average filter over target measurements. The
+// zero value is ready to use.
+type smoother struct {
+	last time.Time
+
+	x, y, offsetX, offsetY, yaw, pitch float64
+}
+
+// apply smooths the target in place with the given time constant (in seconds), keeping
+// the raw measurement in target.Raw.
+func (s *smoother) apply(target *Target, timeConstant float64, now time.Time) {
+	raw := Measurement{
+		Centroid: target.Centroid,
+		OffsetX:  target.OffsetX,
+		OffsetY:  target.OffsetY,
+		Yaw:      target.Yaw,
+		Pitch:    target.Pitch,
+	}
+	target.Raw = &raw
+
+	if s.last.IsZero() {
+		s.x, s.y = float64(raw.Centroid.X), float64(raw.Centroid.Y)
+		s.offsetX, s.offsetY = raw.OffsetX, raw.OffsetY
+		s.yaw, s.pitch = raw.Yaw, raw.Pitch
+	} else {
+		alpha := 1 - math.Exp(-now.Sub(s.last).Seconds()/timeConstant)
+
+		s.x += alpha * (float64(raw.Centroid.X) - s.x)
+		s.y += alpha * (float64(raw.Centroid.Y) - s.y)
+		s.offsetX += alpha * (raw.OffsetX - s.offsetX)
+		s.offsetY += alpha * (raw.OffsetY - s.offsetY)
+		s.yaw += alpha * (raw.Yaw - s.yaw)
+		s.pitch += alpha * (raw.Pitch - s.pitch)
+	}
+	s.last = now
+
+	target.Centroid = image.Point{X: int(math.Round(s.x)), Y: int(math.Round(s.y))}
+	target.OffsetX, target.OffsetY = s.offsetX, s.offsetY
+	target.Yaw, target.Pitch = s.yaw, s.pitch
+}
+
+// reset discards the filter state, so the next measurement isn't smoothed against a
+// target that was lost.
+func (s *smoother) reset() {
+	*s = smoother{}
+}
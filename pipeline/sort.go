@@ -0,0 +1,67 @@
+package pipeline
+
+import (
+	"image"
+	"sort"
+
+	"gocv.io/x/gocv"
+)
+
+// SortMode determines which contour is selected when more than one passes filtering.
+type SortMode string
+
+const (
+	SortLargest   SortMode = "largest"
+	SortSmallest  SortMode = "smallest"
+	SortHighest   SortMode = "highest"
+	SortLowest    SortMode = "lowest"
+	SortLeftmost  SortMode = "leftmost"
+	SortRightmost SortMode = "rightmost"
+
+	// SortClosest selects the contour closest to the crosshair (the center of the frame).
+	SortClosest SortMode = "closest"
+)
+
+// Contour is a contour found in a frame, along with the measurements needed to filter and sort it.
+type Contour struct {
+	Points []image.Point
+	Area   float64
+	Rect   gocv.RotatedRect
+
+	// Depth is the number of contours this contour is inside of, and Holes is the number of
+	// contours directly inside it. They're only calculated when hierarchy filtering is configured.
+	Depth int
+	Holes int
+}
+
+// sortContours sorts contours so that the best contour for the sort mode comes first. An
+// empty sort mode is treated as SortLargest.
+func sortContours(contours []Contour, mode SortMode, crosshair image.Point) {
+	var less func(a, b Contour) bool
+
+	switch mode {
+	case SortSmallest:
+		less = func(a, b Contour) bool { return a.Area < b.Area }
+	case SortHighest:
+		less = func(a, b Contour) bool { return a.Rect.Center.Y < b.Rect.Center.Y }
+	case SortLowest:
+		less = func(a, b Contour) bool { return a.Rect.Center.Y > b.Rect.Center.Y }
+	case SortLeftmost:
+		less = func(a, b Contour) bool { return a.Rect.Center.X < b.Rect.Center.X }
+	case SortRightmost:
+		less = func(a, b Contour) bool { return a.Rect.Center.X > b.Rect.Center.X }
+	case SortClosest:
+		less = func(a, b Contour) bool {
+			return distanceSquared(a.Rect.Center, crosshair) < distanceSquared(b.Rect.Center, crosshair)
+		}
+	default:
+		less = func(a, b Contour) bool { return a.Area > b.Area }
+	}
+
+	sort.SliceStable(contours, func(i, j int) bool { return less(contours[i], contours[j]) })
+}
+
+func distanceSquared(a, b image.Point) int {
+	d := a.Sub(b)
+	return d.X*d.X + d.Y*d.Y
+}
@@ -0,0 +1,503 @@
+package pipeline
+
+import (
+	"fmt"
+	"image"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// Stage is a single step of a pipeline. Stages are run in order on every frame, and
+// communicate through the Frame passed between them.
+type Stage interface {
+	// Name identifies the stage in Config.Stages.
+	Name() string
+
+	// Process runs the stage on the frame.
+	Process(f *Frame)
+}
+
+// Frame is the state passed between stages while processing a single frame.
+type Frame struct {
+	// Input is the original BGR frame, and must not be modified by stages.
+	Input gocv.Mat
+
+	// Output is the frame results are drawn onto.
+	Output *gocv.Mat
+
+	// Mat is the working image. It starts as a copy of the input and is replaced by stages
+	// that transform it (for example, converting color or thresholding).
+	Mat gocv.Mat
+
+	// Scale is the size of the working image relative to the input frame, if it has been
+	// resized. Zero means it hasn't been.
+	Scale float64
+
+	// Contours are the contours found in the working image, and are narrowed down by
+	// filtering stages. ContourCount is the number of contours originally found.
+	Contours     []Contour
+	ContourCount int
+
+	// Targets are the selected targets, best first.
+	Targets []Target
+
+	// Layers are the parts of the overlay drawn onto the output frame.
+	Layers Layers
+
+	// pool holds Mats reused between frames. If nil, Mats are allocated and closed as needed.
+	pool *matPool
+}
+
+// SetMat replaces the working image, releasing the previous one.
+func (f *Frame) SetMat(m gocv.Mat) {
+	f.Release(f.Mat)
+	f.Mat = m
+}
+
+// Size returns the size of the input frame.
+func (f *Frame) Size() image.Point {
+	return image.Point{X: f.Input.Cols(), Y: f.Input.Rows()}
+}
+
+// Stage names, as used in Config.Stages.
+const (
+	StageConvert   = "convert"
+	StageThreshold = "threshold"
+	StageMorph     = "morph"
+	StageContours  = "contours"
+	StageFilter    = "filter"
+	StageSelect    = "select"
+	StageSmooth    = "smooth"
+	StageOutput    = "output"
+)
+
+// NewStage creates the named stage from the config.
+func NewStage(name string, config Config) (Stage, error) {
+	switch name {
+	case StageConvert:
+		return convertStage{colorSpace: config.ColorSpace}, nil
+	case StageThreshold:
+		stage := thresholdStage{
+			colorSpace: config.ColorSpace,
+			min:        config.ColorSpace.clamp(config.MinThresh),
+			max:        config.ColorSpace.clamp(config.MaxThresh),
+		}
+		if secondary := config.SecondaryThresh; secondary != nil {
+			stage.secondary = &Range{Min: config.ColorSpace.clamp(secondary.Min), Max: config.ColorSpace.clamp(secondary.Max)}
+		}
+		return stage, nil
+	case StageMorph:
+		return morphStage{morph: config.Morph}, nil
+	case StageContours:
+		return contoursStage{retrieval: config.Retrieval, hierarchy: config.Hierarchy != nil}, nil
+	case StageFilter:
+		return filterStage{
+			minContour: config.MinContour,
+			maxContour: config.MaxContour,
+			minWidth:   config.MinWidth,
+			minHeight:  config.MinHeight,
+			hierarchy:  config.Hierarchy,
+		}, nil
+	case StageSelect:
+		return selectStage{config: config}, nil
+	case StageSmooth:
+		return &smoothStage{timeConstant: config.SmoothingTimeConstant}, nil
+	case StageOutput:
+		return outputStage{overlay: overlayOrDefault(config.Overlay)}, nil
+	case StageApproximate:
+		var approximation Approximation
+		if config.Approximation != nil {
+			approximation = *config.Approximation
+		}
+		return approximateStage{approximation: approximation}, nil
+	case StageHysteresis:
+		return &hysteresisStage{frames: config.LockFrames}, nil
+	case StageBrighten:
+		var brightness float64
+		if config.Driver != nil {
+			brightness = config.Driver.Brightness
+		}
+		return brightenStage{brightness: brightness}, nil
+	case StageCrosshair:
+		return crosshairStage{overlay: overlayOrDefault(config.Overlay)}, nil
+	case StageAdjust:
+		var adjust Adjust
+		if config.Adjust != nil {
+			adjust = *config.Adjust
+		}
+		return adjustStage{adjust: adjust}, nil
+	case StageEqualize:
+		var equalize Equalize
+		if config.Equalize != nil {
+			equalize = *config.Equalize
+		}
+		return equalizeStage{colorSpace: config.ColorSpace, equalize: equalize}, nil
+	case StageTrack:
+		var tracking Tracking
+		if config.Tracking != nil {
+			tracking = *config.Tracking
+		}
+		return &trackStage{tracking: tracking}, nil
+	case StageResize:
+		return resizeStage{scale: config.ProcessingScale}, nil
+	case StageDistance:
+		return distanceStage{mount: config.Mount}, nil
+	case StagePose:
+		var intrinsics Intrinsics
+		if config.Intrinsics != nil {
+			intrinsics = *config.Intrinsics
+		}
+		return poseStage{intrinsics: intrinsics, model: config.TargetModel}, nil
+	case StageBlobs:
+		var blob Blob
+		if config.Blob != nil {
+			blob = *config.Blob
+		}
+		return blobsStage{blob: blob}, nil
+	case StageCircles:
+		return circlesStage{shape: shapeOrZero(config.Shape)}, nil
+	case StagePolygons:
+		return polygonsStage{shape: shapeOrZero(config.Shape)}, nil
+	}
+
+	return nil, fmt.Errorf("unknown stage %q", name)
+}
+
+// defaultStageNames returns the stages run when the config doesn't list them explicitly.
+// Optional stages are only included when they're configured.
+func defaultStageNames(config Config) []string {
+	if config.Type == TypeDriver {
+		var driver Driver
+		if config.Driver != nil {
+			driver = *config.Driver
+		}
+		return driverStageNames(driver)
+	}
+
+	names := []string{}
+	if config.ProcessingScale > 0 && config.ProcessingScale < 1 {
+		names = append(names, StageResize)
+	}
+
+	if config.Adjust != nil {
+		names = append(names, StageAdjust)
+	}
+
+	names = append(names, StageConvert)
+	if config.Equalize != nil {
+		names = append(names, StageEqualize)
+	}
+
+	names = append(names, StageThreshold)
+	if config.Morph != nil {
+		names = append(names, StageMorph)
+	}
+
+	if config.Blob != nil {
+		names = append(names, StageBlobs)
+	} else if config.Type == TypeColoredShape && config.Shape != nil && config.Shape.Type == ShapeCircle {
+		names = append(names, StageCircles)
+	} else {
+		names = append(names, StageContours)
+	}
+	if config.Approximation != nil {
+		names = append(names, StageApproximate)
+	}
+	if config.Type == TypeColoredShape && config.Shape != nil && config.Shape.Type == ShapePolygon {
+		names = append(names, StagePolygons)
+	}
+
+	names = append(names, StageFilter, StageSelect)
+	if config.Intrinsics != nil && len(config.TargetModel) > 0 {
+		names = append(names, StagePose)
+	}
+	if config.Tracking != nil {
+		names = append(names, StageTrack)
+	}
+	if config.SmoothingTimeConstant > 0 {
+		names = append(names, StageSmooth)
+	}
+	if config.LockFrames > 0 {
+		names = append(names, StageHysteresis)
+	}
+	if config.Mount != nil {
+		names = append(names, StageDistance)
+	}
+	if config.Overlay == nil || !config.Overlay.Disabled {
+		names = append(names, StageOutput)
+	}
+
+	return names
+}
+
+// newStages creates the stages listed in the config, or the default stages if none are listed.
+func newStages(config Config) ([]Stage, error) {
+	names := config.Stages
+	if len(names) == 0 {
+		names = defaultStageNames(config)
+	}
+
+	stages := make([]Stage, 0, len(names))
+	for _, name := range names {
+		stage, err := NewStage(name, config)
+		if err != nil {
+			return nil, err
+		}
+
+		stages = append(stages, stage)
+	}
+
+	return stages, nil
+}
+
+type convertStage struct {
+	colorSpace ColorSpace
+}
+
+func (convertStage) Name() string { return StageConvert }
+
+func (s convertStage) Process(f *Frame) {
+	converted := f.NewMat()
+	gocv.CvtColor(f.Mat, &converted, s.colorSpace.conversion())
+	f.SetMat(converted)
+}
+
+// Range is an additional color range to threshold.
+type Range struct {
+	Min HSV `json:"min"`
+	Max HSV `json:"max"`
+}
+
+type thresholdStage struct {
+	colorSpace ColorSpace
+	min, max   HSV
+	secondary  *Range
+}
+
+func (thresholdStage) Name() string { return StageThreshold }
+
+func (s thresholdStage) Process(f *Frame) {
+	thresh := s.mask(f, s.min, s.max)
+
+	if s.secondary != nil {
+		secondary := s.mask(f, s.secondary.Min, s.secondary.Max)
+		defer f.Release(secondary)
+
+		gocv.BitwiseOr(thresh, secondary, &thresh)
+	}
+
+	f.SetMat(thresh)
+}
+
+// mask thresholds the working image to a single color range.
+func (s thresholdStage) mask(f *Frame, min, max HSV) gocv.Mat {
+	// red spans both ends of the hue range, so a min hue above the max hue wraps around
+	// and is thresholded as the union of [min, maxHue] and [0, max].
+	if s.colorSpace.hasHue() && min.H > max.H {
+		upper, lower := max, min
+		upper.H, lower.H = maxHue, 0
+
+		thresh := f.NewMat()
+		gocv.InRangeWithScalar(f.Mat, min.scalar(), upper.scalar(), &thresh)
+
+		wrapped := f.NewMat()
+		defer f.Release(wrapped)
+		gocv.InRangeWithScalar(f.Mat, lower.scalar(), max.scalar(), &wrapped)
+
+		gocv.BitwiseOr(thresh, wrapped, &thresh)
+		return thresh
+	}
+
+	lower, upper := s.colorSpace.thresholds(min, max)
+
+	thresh := f.NewMat()
+	gocv.InRangeWithScalar(f.Mat, lower, upper, &thresh)
+	return thresh
+}
+
+// MorphOperation is a morphological operation applied to the thresholded image.
+type MorphOperation string
+
+const (
+	MorphErode  MorphOperation = "erode"
+	MorphDilate MorphOperation = "dilate"
+	MorphOpen   MorphOperation = "open"
+	MorphClose  MorphOperation = "close"
+)
+
+// MorphOperations are every morphological operation.
+var MorphOperations = []MorphOperation{MorphErode, MorphDilate, MorphOpen, MorphClose}
+
+// Morph configures the morph stage, used to remove noise from or fill gaps in the
+// thresholded image.
+type Morph struct {
+	Operation  MorphOperation `json:"operation"`
+	KernelSize int            `json:"kernelSize"`
+}
+
+type morphStage struct {
+	morph *Morph
+}
+
+func (morphStage) Name() string { return StageMorph }
+
+func (s morphStage) Process(f *Frame) {
+	if s.morph == nil || s.morph.KernelSize < 1 {
+		return
+	}
+
+	var op gocv.MorphType
+	switch s.morph.Operation {
+	case MorphErode:
+		op = gocv.MorphErode
+	case MorphDilate:
+		op = gocv.MorphDilate
+	case MorphOpen:
+		op = gocv.MorphOpen
+	case MorphClose:
+		op = gocv.MorphClose
+	default:
+		return
+	}
+
+	kernel := gocv.GetStructuringElement(gocv.MorphRect, image.Point{X: s.morph.KernelSize, Y: s.morph.KernelSize})
+	defer kernel.Close()
+
+	morphed := f.NewMat()
+	gocv.MorphologyEx(f.Mat, &morphed, op, kernel)
+	f.SetMat(morphed)
+}
+
+type contoursStage struct {
+	retrieval Retrieval
+	hierarchy bool
+}
+
+func (contoursStage) Name() string { return StageContours }
+
+func (s contoursStage) Process(f *Frame) {
+	f.Contours = f.Contours[:0]
+	for _, points := range gocv.FindContours(f.Mat, s.retrieval.mode(), gocv.ChainApproxSimple) {
+		points = f.unscale(points)
+		f.Contours = append(f.Contours, Contour{Points: points, Area: gocv.ContourArea(points)})
+	}
+
+	if s.hierarchy {
+		calculateHierarchy(f.Contours)
+	}
+
+	f.ContourCount = len(f.Contours)
+}
+
+type filterStage struct {
+	minContour, maxContour float64
+	minWidth, minHeight    int
+	hierarchy              *Hierarchy
+}
+
+func (filterStage) Name() string { return StageFilter }
+
+func (s filterStage) Process(f *Frame) {
+	size := f.Size()
+	imageArea := float64(size.X * size.Y)
+
+	filtered := f.Contours[:0]
+	for _, c := range f.Contours {
+		// rejecting specks by their bounding box is cheap, so do it first
+		if s.minWidth > 0 || s.minHeight > 0 {
+			bounds := gocv.BoundingRect(c.Points)
+			if bounds.Dx() < s.minWidth || bounds.Dy() < s.minHeight {
+				continue
+			}
+		}
+
+		if c.Area < s.minContour*imageArea || c.Area > s.maxContour*imageArea {
+			continue
+		}
+
+		if s.hierarchy != nil && !s.hierarchy.matches(c) {
+			continue
+		}
+
+		c.Rect = gocv.MinAreaRect(c.Points)
+		filtered = append(filtered, c)
+	}
+
+	f.Contours = filtered
+}
+
+type selectStage struct {
+	config Config
+}
+
+func (selectStage) Name() string { return StageSelect }
+
+func (s selectStage) Process(f *Frame) {
+	size := f.Size()
+	sortContours(f.Contours, s.config.SortMode, image.Point{X: size.X / 2, Y: size.Y / 2})
+
+	maxTargets := s.config.MaxTargets
+	if maxTargets < 1 {
+		maxTargets = 1
+	}
+
+	selected := f.Contours
+	if len(selected) > maxTargets {
+		selected = selected[:maxTargets]
+	}
+
+	f.Targets = make([]Target, 0, len(selected))
+	for _, c := range selected {
+		f.Targets = append(f.Targets, s.newTarget(f, c))
+	}
+}
+
+// newTarget measures a selected contour.
+func (s selectStage) newTarget(f *Frame, c Contour) Target {
+	size := f.Size()
+
+	area := c.Area / float64(size.X*size.Y)
+
+	target := Target{
+		Centroid:    calculateCentroid(c.Points),
+		Area:        area,
+		Confidence:  calculateConfidence(c, area, s.config),
+		BoundingBox: c.Rect.BoundingRect,
+		RotatedRect: RotatedRect{
+			Center: c.Rect.Center,
+			Width:  c.Rect.Width,
+			Height: c.Rect.Height,
+			Angle:  c.Rect.Angle,
+		},
+		Corners: calculateCorners(c.Points),
+	}
+
+	halfX, halfY := float64(size.X)/2, float64(size.Y)/2
+	target.OffsetX = (float64(target.Centroid.X) - halfX) / halfX
+	target.OffsetY = (halfY - float64(target.Centroid.Y)) / halfY
+
+	if s.config.Intrinsics != nil && s.config.Intrinsics.Fx != 0 && s.config.Intrinsics.Fy != 0 {
+		target.Yaw, target.Pitch = calculateCalibratedAngles(target.Centroid, *s.config.Intrinsics)
+	} else {
+		target.Yaw, target.Pitch = calculateAngles(target.Centroid, size, s.config.HorizontalFOV, s.config.VerticalFOV)
+	}
+
+	return target
+}
+
+type smoothStage struct {
+	timeConstant float64
+	smoother     smoother
+}
+
+func (*smoothStage) Name() string { return StageSmooth }
+
+func (s *smoothStage) Process(f *Frame) {
+	if len(f.Targets) == 0 || s.timeConstant <= 0 {
+		s.smoother.reset()
+		return
+	}
+
+	s.smoother.apply(&f.Targets[0], s.timeConstant, time.Now())
+}
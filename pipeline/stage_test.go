@@ -0,0 +1,42 @@
+package pipeline
+
+import "testing"
+
+// TestDefaultStageNamesPose checks that pose estimation only runs when it's configured.
+func TestDefaultStageNamesPose(t *testing.T) {
+	model := []ModelPoint{{X: 0, Y: 0}, {X: 0.5, Y: 0}, {X: 0.5, Y: 0.2}, {X: 0, Y: 0.2}}
+	intrinsics := &Intrinsics{Fx: 600, Fy: 600, Cx: 320, Cy: 240}
+
+	tests := []struct {
+		name   string
+		config Config
+		pose   bool
+	}{
+		{"unconfigured", Config{Type: TypeReflective}, false},
+		{"intrinsics only", Config{Type: TypeReflective, Intrinsics: intrinsics}, false},
+		{"target model only", Config{Type: TypeReflective, TargetModel: model}, false},
+		{"configured", Config{Type: TypeReflective, Intrinsics: intrinsics, TargetModel: model}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			names := defaultStageNames(test.config)
+
+			var pose bool
+			for i, name := range names {
+				if name != StagePose {
+					continue
+				}
+				pose = true
+
+				if i == 0 || names[i-1] != StageSelect {
+					t.Errorf("pose stage runs after %v, want right after %q", names[:i], StageSelect)
+				}
+			}
+
+			if pose != test.pose {
+				t.Errorf("stages are %v, want pose %t", names, test.pose)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// Stereo describes a calibrated pair of cameras, used to triangulate the position of targets
+// without knowing their dimensions.
+type Stereo struct {
+	Left  Intrinsics `json:"left"`
+	Right Intrinsics `json:"right"`
+
+	// Rotation (as a Rodrigues vector) and Translation (in meters) transform points from left
+	// camera coordinates to right camera coordinates, the same as stereoCalibrate's R and T.
+	Rotation    [3]float64 `json:"rotation"`
+	Translation [3]float64 `json:"translation"`
+}
+
+// StereoPipeline runs the same pipeline config on synchronized frames from a pair of cameras,
+// and triangulates the position of the targets found in both.
+type StereoPipeline struct {
+	Stereo Stereo
+
+	Left, Right *Pipeline
+}
+
+// NewStereo creates a stereo pipeline from a config with Stereo set.
+func NewStereo(config Config) (*StereoPipeline, error) {
+	if config.Stereo == nil {
+		return nil, fmt.Errorf("config has no stereo calibration")
+	}
+
+	left, err := New(config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create left pipeline: %w", err)
+	}
+
+	right, err := New(config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create right pipeline: %w", err)
+	}
+
+	return &StereoPipeline{Stereo: *config.Stereo, Left: left, Right: right}, nil
+}
+
+// Close releases the images the pipelines reuse between frames.
+func (p *StereoPipeline) Close() error {
+	p.Left.Close()
+	p.Right.Close()
+
+	return nil
+}
+
+// ProcessFrames processes a pair of frames captured at the same time, drawing results onto
+// the output frames. The result is the left camera's, with the triangulated position of each
+// target found by both cameras. Targets are matched between the cameras by their order, so the
+// sort mode should order them the same way from both viewpoints (for example, SortLeftmost).
+func (p *StereoPipeline) ProcessFrames(left, right gocv.Mat, leftOut, rightOut *gocv.Mat, captured time.Time) Result {
+	result := p.Left.ProcessFrame(left, leftOut, captured)
+	rightResult := p.Right.ProcessFrame(right, rightOut, captured)
+
+	for i := range result.Targets {
+		if i >= len(rightResult.Targets) {
+			break
+		}
+
+		if position, ok := p.Stereo.triangulate(result.Targets[i], rightResult.Targets[i]); ok {
+			result.Targets[i].Position = &position
+		}
+	}
+
+	return result
+}
+
+// triangulate finds the position of a target seen by both cameras, in left camera coordinates,
+// as the midpoint of the closest points of the rays from each camera through the target.
+func (s Stereo) triangulate(left, right Target) ([3]float64, bool) {
+	if s.Left.Fx == 0 || s.Left.Fy == 0 || s.Right.Fx == 0 || s.Right.Fy == 0 {
+		return [3]float64{}, false
+	}
+
+	lx, ly := s.Left.undistort(left.Centroid)
+	rx, ry := s.Right.undistort(right.Centroid)
+
+	// bring the right camera's ray into left camera coordinates: p_left = R^T (p_right - T)
+	r := rotationMatrix(s.Rotation)
+	origin := mulTransposed(r, mul(s.Translation, -1))
+	direction := mulTransposed(r, [3]float64{rx, ry, 1})
+	ray := [3]float64{lx, ly, 1}
+
+	// solve for the ray parameters minimizing the distance between the two rays
+	a, b, c := dot(ray, ray), dot(ray, direction), dot(direction, direction)
+	d, e := dot(ray, origin), dot(direction, origin)
+
+	denominator := a*c - b*b
+	if math.Abs(denominator) < 1e-12 {
+		// the rays are parallel, so the target is too far away to triangulate
+		return [3]float64{}, false
+	}
+
+	t := (d*c - b*e) / denominator
+	u := (d*b - a*e) / denominator
+	if t <= 0 || u <= 0 {
+		// the target must be in front of both cameras
+		return [3]float64{}, false
+	}
+
+	closestLeft := mul(ray, t)
+	closestRight := add(origin, mul(direction, u))
+
+	return mul(add(closestLeft, closestRight), 0.5), true
+}
+
+// rotationMatrix converts a Rodrigues vector into a rotation matrix.
+func rotationMatrix(r [3]float64) [3][3]float64 {
+	theta := norm(r)
+	if theta < 1e-12 {
+		return [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	}
+
+	k := mul(r, 1/theta)
+	cos, sin := math.Cos(theta), math.Sin(theta)
+
+	var m [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			m[i][j] = (1 - cos) * k[i] * k[j]
+			if i == j {
+				m[i][j] += cos
+			}
+		}
+	}
+
+	m[0][1] -= sin * k[2]
+	m[0][2] += sin * k[1]
+	m[1][0] += sin * k[2]
+	m[1][2] -= sin * k[0]
+	m[2][0] -= sin * k[1]
+	m[2][1] += sin * k[0]
+
+	return m
+}
+
+// mulTransposed multiplies a vector by the transpose of a matrix.
+func mulTransposed(m [3][3]float64, v [3]float64) [3]float64 {
+	return [3]float64{
+		m[0][0]*v[0] + m[1][0]*v[1] + m[2][0]*v[2],
+		m[0][1]*v[0] + m[1][1]*v[1] + m[2][1]*v[2],
+		m[0][2]*v[0] + m[1][2]*v[1] + m[2][2]*v[2],
+	}
+}
+
+func add(a, b [3]float64) [3]float64 { return [3]float64{a[0] + b[0], a[1] + b[1], a[2] + b[2]} }
@@ -0,0 +1,98 @@
+package pipeline
+
+import (
+	"math"
+	"sort"
+)
+
+const StageTrack = "track"
+
+// Tracking configures associating targets between consecutive frames, so each target keeps
+// the same ID while it's visible.
+type Tracking struct {
+	// MaxDistance is the furthest a target's centroid can move between frames and keep its
+	// ID, as a fraction of the frame width. Defaults to 0.1.
+	MaxDistance float64 `json:"maxDistance"`
+
+	// MaxMissed is the number of consecutive frames a target can go unseen before its ID is
+	// retired.
+	MaxMissed int `json:"maxMissed"`
+}
+
+// track is a target followed across frames.
+type track struct {
+	id     int
+	target Target
+	missed int
+}
+
+// trackStage assigns IDs to targets by matching them to the targets of previous frames,
+// nearest centroid first.
+type trackStage struct {
+	tracking Tracking
+
+	tracks []track
+	nextID int
+}
+
+func (*trackStage) Name() string { return StageTrack }
+
+func (s *trackStage) Process(f *Frame) {
+	maxDistance := s.tracking.MaxDistance
+	if maxDistance <= 0 {
+		maxDistance = 0.1
+	}
+	maxDistance *= float64(f.Size().X)
+
+	type pair struct {
+		track, target int
+		distance      float64
+	}
+
+	var pairs []pair
+	for i, t := range s.tracks {
+		for j, target := range f.Targets {
+			distance := math.Sqrt(float64(distanceSquared(t.target.Centroid, target.Centroid)))
+			if distance <= maxDistance {
+				pairs = append(pairs, pair{track: i, target: j, distance: distance})
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].distance < pairs[j].distance })
+
+	matchedTracks := make([]bool, len(s.tracks))
+	matchedTargets := make([]bool, len(f.Targets))
+	for _, p := range pairs {
+		if matchedTracks[p.track] || matchedTargets[p.target] {
+			continue
+		}
+		matchedTracks[p.track], matchedTargets[p.target] = true, true
+
+		f.Targets[p.target].ID = s.tracks[p.track].id
+		s.tracks[p.track].target = f.Targets[p.target]
+		s.tracks[p.track].missed = 0
+	}
+
+	tracks := s.tracks[:0]
+	for i, t := range s.tracks {
+		if !matchedTracks[i] {
+			t.missed++
+			if t.missed > s.tracking.MaxMissed {
+				continue
+			}
+		}
+
+		tracks = append(tracks, t)
+	}
+	s.tracks = tracks
+
+	for j := range f.Targets {
+		if matchedTargets[j] {
+			continue
+		}
+
+		s.nextID++
+		f.Targets[j].ID = s.nextID
+		s.tracks = append(s.tracks, track{id: s.nextID, target: f.Targets[j]})
+	}
+}
@@ -0,0 +1,108 @@
+package pipeline
+
+import (
+	"errors"
+	"image"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// tuneOutlierFraction is the fraction of pixels ignored at each end of every channel
+	// when suggesting thresholds.
+	tuneOutlierFraction = 0.05
+
+	// tuneHueMargin and tuneMargin widen suggested thresholds beyond the sampled pixels.
+	tuneHueMargin = 5
+	tuneMargin    = 15
+)
+
+// SuggestThresholds suggests HSV thresholds that capture most of the pixels in a region of
+// a BGR frame, ignoring outliers. If the sampled hues straddle the ends of the hue range
+// (as red does), the suggested min hue is greater than the max hue, so it wraps around.
+func SuggestThresholds(frame gocv.Mat, region image.Rectangle) (min HSV, max HSV, err error) {
+	region = region.Intersect(image.Rect(0, 0, frame.Cols(), frame.Rows()))
+	if region.Empty() {
+		return min, max, errors.New("region is outside of the frame")
+	}
+
+	roi := frame.Region(region)
+	defer roi.Close()
+
+	hsv := gocv.NewMat()
+	defer hsv.Close()
+	gocv.CvtColor(roi, &hsv, gocv.ColorBGRToHSV)
+
+	var hues, shiftedHues, sats, vals [256]int
+	data := hsv.ToBytes()
+	for i := 0; i+2 < len(data); i += 3 {
+		hues[data[i]]++
+		shiftedHues[(int(data[i])+maxHue/2)%maxHue]++
+		sats[data[i+1]]++
+		vals[data[i+2]]++
+	}
+
+	total := len(data) / 3
+
+	minH, maxH := percentiles(hues, total)
+	minShifted, maxShifted := percentiles(shiftedHues, total)
+	if maxShifted-minShifted < maxH-minH {
+		// the hues are closer together when shifted, so they wrap around
+		minH = minShifted + maxHue/2
+		maxH = maxShifted + maxHue/2
+	}
+
+	min.H, max.H = wrapHue(minH-tuneHueMargin), wrapHue(maxH+tuneHueMargin)
+	if maxH-minH+2*tuneHueMargin >= maxHue {
+		min.H, max.H = 0, maxHue
+	}
+
+	minS, maxS := percentiles(sats, total)
+	minV, maxV := percentiles(vals, total)
+
+	min.S, max.S = clamp(minS-tuneMargin, 0, 255), clamp(maxS+tuneMargin, 0, 255)
+	min.V, max.V = clamp(minV-tuneMargin, 0, 255), clamp(maxV+tuneMargin, 0, 255)
+
+	return min, max, nil
+}
+
+// percentiles returns the values at the outlier fraction at each end of a histogram.
+func percentiles(histogram [256]int, total int) (int, int) {
+	low := int(math.Floor(float64(total) * tuneOutlierFraction))
+	high := int(math.Ceil(float64(total) * (1 - tuneOutlierFraction)))
+
+	min, max := -1, 0
+	count := 0
+	for value, n := range histogram {
+		count += n
+		if min < 0 && count > low {
+			min = value
+		}
+		if count >= high {
+			max = value
+			break
+		}
+	}
+
+	if min < 0 {
+		min = 0
+	}
+
+	return min, max
+}
+
+func wrapHue(h int) float64 {
+	return float64((h%maxHue + maxHue) % maxHue)
+}
+
+func clamp(v, min, max int) float64 {
+	if v < min {
+		v = min
+	}
+	if v > max {
+		v = max
+	}
+
+	return float64(v)
+}
@@ -0,0 +1,39 @@
+package pipeline
+
+import "fmt"
+
+// Type is the kind of pipeline, which determines the stages it runs by default.
+type Type string
+
+const (
+	// TypeReflective finds retroreflective targets lit by the LEDs by color and contour area.
+	TypeReflective Type = "reflective"
+
+	// TypeColoredShape finds colored game pieces by color and shape.
+	TypeColoredShape Type = "coloredShape"
+
+	// TypeAprilTag finds AprilTag fiducials.
+	TypeAprilTag Type = "apriltag"
+
+	// TypeDriver passes frames through without looking for targets.
+	TypeDriver Type = "driver"
+
+	// TypeNN finds targets with a neural network.
+	TypeNN Type = "nn"
+)
+
+// Types are the pipeline types, in the order they're presented to users.
+var Types = []Type{TypeReflective, TypeColoredShape, TypeAprilTag, TypeDriver, TypeNN}
+
+// supported returns an error if pipelines of the type can't be created. An empty type is
+// treated as TypeReflective.
+func (t Type) supported() error {
+	switch t {
+	case "", TypeReflective, TypeColoredShape, TypeDriver:
+		return nil
+	case TypeAprilTag, TypeNN:
+		return fmt.Errorf("%s pipelines aren't supported by this build", t)
+	default:
+		return fmt.Errorf("unknown pipeline type %q", t)
+	}
+}
@@ -0,0 +1,150 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// FieldError is a config field with an invalid value.
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when a config has invalid fields.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Path + " " + field.Message
+	}
+
+	return "invalid config: " + strings.Join(messages, ", ")
+}
+
+// Validate checks the config's fields against the ranges and options in the schema,
+// returning a ValidationError describing every invalid field.
+func (c Config) Validate() error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("unable to marshal config: %w", err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("unable to unmarshal config: %w", err)
+	}
+
+	var invalid []FieldError
+	for _, field := range schema {
+		value, ok := lookup(fields, field.Path)
+		if !ok {
+			continue
+		}
+
+		// sides are always marshalled, but only polygons use them
+		if field.Path == "shape.sides" && (c.Shape == nil || c.Shape.Type != ShapePolygon) {
+			continue
+		}
+
+		if message := field.check(value); message != "" {
+			invalid = append(invalid, FieldError{Path: field.Path, Message: message})
+		}
+	}
+
+	// enums may be empty to use their default, but the morph stage has none and would do
+	// nothing
+	if c.Morph != nil && c.Morph.Operation == "" {
+		invalid = append(invalid, FieldError{Path: "morph.operation", Message: "must be one of " + strings.Join(morphOptions(), ", ")})
+	}
+
+	// the schema allows any 8-bit first channel, but hues only go up to maxHue
+	if c.ColorSpace.hasHue() {
+		hues := map[string]float64{"minThresh.h": c.MinThresh.H, "maxThresh.h": c.MaxThresh.H}
+		if c.SecondaryThresh != nil {
+			hues["secondaryThresh.min.h"], hues["secondaryThresh.max.h"] = c.SecondaryThresh.Min.H, c.SecondaryThresh.Max.H
+		}
+
+		for _, field := range schema {
+			// hues out of the schema's range are already invalid
+			if hue, ok := hues[field.Path]; ok && hue > maxHue && hue <= maxSaturationValue {
+				invalid = append(invalid, FieldError{Path: field.Path, Message: fmt.Sprintf("must be at most %d in color spaces with hue", maxHue)})
+			}
+		}
+	}
+
+	if len(invalid) > 0 {
+		return ValidationError{Fields: invalid}
+	}
+
+	return nil
+}
+
+// lookup returns the value at the dotted path in a JSON object, and false if the path
+// isn't set.
+func lookup(fields map[string]interface{}, path string) (interface{}, bool) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := fields[key].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		fields = child
+	}
+
+	value, ok := fields[keys[len(keys)-1]]
+	return value, ok && value != nil
+}
+
+// check returns why the value isn't valid for the field, or an empty string if it is.
+func (f Field) check(value interface{}) string {
+	switch f.Type {
+	case FieldBoolean:
+		if _, ok := value.(bool); !ok {
+			return "must be a boolean"
+		}
+	case FieldEnum:
+		s, ok := value.(string)
+		if !ok {
+			return "must be a string"
+		}
+
+		// empty enums use the default
+		if s == "" {
+			return ""
+		}
+
+		for _, option := range f.Options {
+			if s == option {
+				return ""
+			}
+		}
+
+		return fmt.Sprintf("must be one of %s", strings.Join(f.Options, ", "))
+	case FieldNumber, FieldInteger:
+		n, ok := value.(float64)
+		if !ok {
+			return "must be a number"
+		}
+
+		if f.Type == FieldInteger && n != math.Trunc(n) {
+			return "must be an integer"
+		}
+
+		if f.Min != nil && n < *f.Min {
+			return fmt.Sprintf("must be at least %g", *f.Min)
+		}
+
+		if f.Max != nil && n > *f.Max {
+			return fmt.Sprintf("must be at most %g", *f.Max)
+		}
+	}
+
+	return ""
+}
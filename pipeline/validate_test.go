@@ -0,0 +1,75 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+
+		// invalid are the paths of the invalid fields, if any
+		invalid []string
+	}{
+		{
+			name:   "reflective",
+			config: `{"schemaVersion": 2, "type": "reflective", "maxTargets": 1, "minContour": 0.001, "maxContour": 1}`,
+		},
+		{
+			name:   "circle shape without sides",
+			config: `{"schemaVersion": 2, "type": "coloredShape", "maxTargets": 1, "shape": {"type": "circle", "minRadius": 5}}`,
+		},
+		{
+			name:    "polygon shape without sides",
+			config:  `{"schemaVersion": 2, "type": "coloredShape", "maxTargets": 1, "shape": {"type": "polygon", "epsilon": 0.02}}`,
+			invalid: []string{"shape.sides"},
+		},
+		{
+			name:   "polygon shape",
+			config: `{"schemaVersion": 2, "type": "coloredShape", "maxTargets": 1, "shape": {"type": "polygon", "sides": 4, "epsilon": 0.02}}`,
+		},
+		{
+			name:   "without max targets",
+			config: `{"schemaVersion": 2, "type": "reflective"}`,
+		},
+		{
+			name:    "too many max targets",
+			config:  `{"schemaVersion": 2, "type": "reflective", "maxTargets": 33}`,
+			invalid: []string{"maxTargets"},
+		},
+		{
+			name:    "hue above 180",
+			config:  `{"schemaVersion": 2, "type": "reflective", "colorSpace": "hsv", "maxThresh": {"h": 200, "s": 255, "v": 255}}`,
+			invalid: []string{"maxThresh.h"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var config Config
+			if err := json.Unmarshal([]byte(test.config), &config); err != nil {
+				t.Fatal(err)
+			}
+
+			var invalid []string
+
+			err := config.Validate()
+			var validation ValidationError
+			if errors.As(err, &validation) {
+				for _, field := range validation.Fields {
+					invalid = append(invalid, field.Path)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(invalid, test.invalid) {
+				t.Errorf("invalid fields are %v, want %v (%v)", invalid, test.invalid, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// requestIDHeader carries the request's ID, so a response can be matched up with its log
+// lines. An ID set on the request by a proxy is kept.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the longest request ID kept from a request, so clients can't flood
+// the logs.
+const maxRequestIDLength = 64
+
+// requestIDKey is the context key for the request's ID.
+type requestIDKey struct{}
+
+// errPanic is responded with when a handler panics.
+var errPanic = errors.New("internal server error")
+
+// accessRecorder records the status and size of a response. It passes through flushing and
+// hijacking, which streams and websockets need.
+type accessRecorder struct {
+	http.ResponseWriter
+
+	status int
+	size   int
+}
+
+func (r *accessRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *accessRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+
+	n, err := r.ResponseWriter.Write(data)
+	r.size += n
+
+	return n, err
+}
+
+func (r *accessRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *accessRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking isn't supported")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		r.status = http.StatusSwitchingProtocols
+	}
+
+	return conn, rw, err
+}
+
+// accessLog wraps the handler to give each request an ID and log it once it's handled.
+// Successful requests are logged at debug level, so polling clients don't flood the logs,
+// client errors at info and server errors at warn. A panicking handler is recovered and
+// responded to with a 500, rather than the connection being dropped.
+func (s *Server) accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+
+		id := req.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		res.Header().Set(requestIDHeader, id)
+		req = req.WithContext(context.WithValue(req.Context(), requestIDKey{}, id))
+
+		recorder := &accessRecorder{ResponseWriter: res}
+
+		defer func() {
+			if v := recover(); v != nil {
+				if v == http.ErrAbortHandler {
+					// the handler aborted the response on purpose
+					panic(v)
+				}
+
+				s.requestLogger(req).WithField("stack", string(debug.Stack())).Errorf("handler panicked: %v", v)
+
+				if recorder.status == 0 {
+					respond(recorder, errPanic, http.StatusInternalServerError)
+				}
+			}
+
+			status := recorder.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			entry := s.requestLogger(req).WithFields(logrus.Fields{
+				"method":   req.Method,
+				"path":     req.URL.Path,
+				"status":   status,
+				"duration": time.Since(start).String(),
+				"size":     recorder.size,
+				"client":   clientIP(req),
+			})
+
+			msg := fmt.Sprintf("%s %s", req.Method, req.URL.Path)
+			switch {
+			case status >= http.StatusInternalServerError:
+				entry.Warn(msg)
+			case status >= http.StatusBadRequest:
+				entry.Info(msg)
+			default:
+				entry.Debug(msg)
+			}
+		}()
+
+		next.ServeHTTP(recorder, req)
+	})
+}
+
+// requestLogger returns a logger that tags its lines with the request's ID.
+func (s *Server) requestLogger(req *http.Request) *logrus.Entry {
+	entry := logrus.NewEntry(s.Logger)
+	if id, ok := req.Context().Value(requestIDKey{}).(string); ok {
+		entry = entry.WithField("request", id)
+	}
+
+	return entry
+}
+
+// newRequestID returns a random request ID.
+func newRequestID() string {
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		// IDs are only for matching up log lines, so a fixed one is better than none
+		return "unknown"
+	}
+
+	return hex.EncodeToString(id[:])
+}
+
+// validRequestID returns whether a request ID set by a client is safe to log and echo.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// clientIP returns the IP address the request came from.
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Auth is the credentials required to make requests that change the server's state. Either
+// the token (as a bearer token) or the username and password (with basic auth) are
+// accepted, if set.
+type Auth struct {
+	Token string
+
+	Username string
+	Password string
+}
+
+// errUnauthorized is responded with when a request doesn't have valid credentials.
+var errUnauthorized = errors.New("unauthorized")
+
+// enabled returns whether any credentials are set.
+func (a *Auth) enabled() bool {
+	return a != nil && (a.Token != "" || a.Username != "" || a.Password != "")
+}
+
+// authorized returns whether the request has valid credentials.
+func (a *Auth) authorized(req *http.Request) bool {
+	if a.Token != "" {
+		header := req.Header.Get("Authorization")
+		if token := strings.TrimPrefix(header, "Bearer "); token != header && equal(token, a.Token) {
+			return true
+		}
+	}
+
+	if a.Username != "" || a.Password != "" {
+		username, password, ok := req.BasicAuth()
+		// both are compared regardless, so the time taken doesn't reveal which was wrong
+		usernameOK, passwordOK := equal(username, a.Username), equal(password, a.Password)
+		if ok && usernameOK && passwordOK {
+			return true
+		}
+	}
+
+	return false
+}
+
+// authorizedUpgrade returns whether a websocket upgrade request has valid credentials, if any
+// are set. Browsers can't set headers on websocket requests, so the token is also accepted in
+// the token query parameter.
+func (a *Auth) authorizedUpgrade(req *http.Request) bool {
+	if !a.enabled() || a.authorized(req) {
+		return true
+	}
+
+	return a.Token != "" && equal(req.URL.Query().Get("token"), a.Token)
+}
+
+// unauthorized responds that the request needs credentials, challenging for basic auth if
+// it's set.
+func (a *Auth) unauthorized(res http.ResponseWriter) {
+	if a.Username != "" || a.Password != "" {
+		res.Header().Set("WWW-Authenticate", `Basic realm="gloworm"`)
+	}
+
+	respond(res, errUnauthorized, http.StatusUnauthorized)
+}
+
+// equal compares the strings in constant time.
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// mutating returns whether the request may change the server's state.
+func mutating(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	default:
+		return true
+	}
+}
+
+// requireAuth wraps the handler so requests that may change the server's state need valid
+// credentials, if any are set.
+func (s *Server) requireAuth(next http.Handler) http.Handler {
+	if !s.Auth.enabled() {
+		return next
+	}
+
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if mutating(req) && !s.Auth.authorized(req) {
+			s.Auth.unauthorized(res)
+			return
+		}
+
+		next.ServeHTTP(res, req)
+	})
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultBackupInterval is how often the store is backed up if BackupInterval isn't set.
+	defaultBackupInterval = time.Hour
+
+	// defaultMaxBackups is the number of store backups kept if MaxBackups isn't set.
+	defaultMaxBackups = 24
+)
+
+// Store backups are named with the time they were written, so they sort oldest first.
+const (
+	backupPrefix     = "store-"
+	backupSuffix     = ".bak"
+	backupTimeFormat = "20060102-150405"
+)
+
+// backupStore writes a copy of the store to BackupDir every BackupInterval, when it's
+// changed since the last copy, and once when it starts, until ctx is done. Only the newest
+// MaxBackups copies are kept. Copies are in the store's own format, so one can replace a
+// store that was corrupted by a hard power off.
+func (s *Server) backupStore(ctx context.Context) {
+	store, ok := s.Store.(io.WriterTo)
+	if !ok {
+		s.Logger.Warn("store can't be backed up, not backing it up")
+		return
+	}
+
+	interval := s.BackupInterval
+	if interval <= 0 {
+		interval = defaultBackupInterval
+	}
+
+	events, unwatch := s.Store.Watch()
+	defer unwatch()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	changed := true
+	for {
+		if changed {
+			if err := s.backup(store); err != nil {
+				s.Logger.Warnf("unable to back up store: %s", err)
+			} else {
+				changed = false
+			}
+		}
+
+		// changes are noted as they're made, but only backed up on the next tick
+		for tick := false; !tick; {
+			select {
+			case <-ctx.Done():
+				return
+			case <-events:
+				changed = true
+			case <-ticker.C:
+				tick = true
+			}
+		}
+	}
+}
+
+// backup writes a copy of the store to BackupDir, and deletes the oldest copies beyond
+// MaxBackups. The copy is written to a temporary file first, so a power cut can't leave a
+// partial copy that looks complete.
+func (s *Server) backup(store io.WriterTo) error {
+	if err := os.MkdirAll(s.BackupDir, 0755); err != nil {
+		return fmt.Errorf("unable to create backup directory: %w", err)
+	}
+
+	tmp, err := ioutil.TempFile(s.BackupDir, "."+backupPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary backup: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = store.WriteTo(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write backup: %w", err)
+	}
+
+	name := filepath.Join(s.BackupDir, backupPrefix+time.Now().Format(backupTimeFormat)+backupSuffix)
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return fmt.Errorf("unable to save backup: %w", err)
+	}
+
+	s.Logger.WithField("backup", name).Debug("backed up store")
+
+	return s.pruneBackups()
+}
+
+// pruneBackups deletes the oldest store backups beyond MaxBackups.
+func (s *Server) pruneBackups() error {
+	max := s.MaxBackups
+	if max <= 0 {
+		max = defaultMaxBackups
+	}
+
+	entries, err := os.ReadDir(s.BackupDir)
+	if err != nil {
+		return fmt.Errorf("unable to list backups: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupSuffix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for len(names) > max {
+		if err := os.Remove(filepath.Join(s.BackupDir, names[0])); err != nil {
+			return fmt.Errorf("unable to delete old backup: %w", err)
+		}
+		names = names[1:]
+	}
+
+	return nil
+}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"sync"
+
+	"github.com/gloworm-vision/gloworm-app/pipeline"
+)
+
+var (
+	// errNoCalibration is returned when using a calibration session before starting one.
+	errNoCalibration = errors.New("no calibration in progress")
+
+	// errNotCalibrated is returned when saving a calibration before solving it.
+	errNotCalibrated = errors.New("calibration hasn't been solved")
+)
+
+// CalibrationStatus is the progress of a calibration session.
+type CalibrationStatus struct {
+	Active bool           `json:"active"`
+	Board  pipeline.Board `json:"board"`
+
+	// Views are the number of views of the board captured, of at least MinViews.
+	Views    int               `json:"views"`
+	MinViews int               `json:"minViews"`
+	Coverage pipeline.Coverage `json:"coverage"`
+
+	// Result is set once the calibration is solved, and cleared when more views are captured.
+	Result *pipeline.Calibration `json:"result,omitempty"`
+}
+
+// calibrator is a calibration session, which captures views of a board from the live camera
+// and solves its intrinsics from them.
+type calibrator struct {
+	mu sync.Mutex
+
+	active bool
+	board  pipeline.Board
+	size   image.Point
+	views  [][]pipeline.ImagePoint
+	result *pipeline.Calibration
+}
+
+// Start starts a new session with the board, discarding any previous session.
+func (c *calibrator) Start(board pipeline.Board) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.active = true
+	c.board = board
+	c.size = image.Point{}
+	c.views = nil
+	c.result = nil
+}
+
+// Stop ends the session.
+func (c *calibrator) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.active = false
+	c.views = nil
+	c.result = nil
+}
+
+// Capture looks for the board in the frame, adding it as a view if the whole board is found.
+func (c *calibrator) Capture(frame image.Point, find func(board pipeline.Board) ([]pipeline.ImagePoint, bool)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.active {
+		return errNoCalibration
+	}
+
+	if c.size != (image.Point{}) && c.size != frame {
+		return fmt.Errorf("frame is %dx%d, but the calibration is at %dx%d", frame.X, frame.Y, c.size.X, c.size.Y)
+	}
+
+	corners, ok := find(c.board)
+	if !ok {
+		return errors.New("board not found, make sure every corner is in the frame")
+	}
+
+	c.size = frame
+	c.views = append(c.views, corners)
+	c.result = nil
+
+	return nil
+}
+
+// Solve calibrates from the views captured so far.
+func (c *calibrator) Solve() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.active {
+		return errNoCalibration
+	}
+
+	result, err := pipeline.Calibrate(c.board, c.size, c.views)
+	if err != nil {
+		return fmt.Errorf("unable to calibrate: %w", err)
+	}
+	c.result = &result
+
+	return nil
+}
+
+// Result returns the solved calibration.
+func (c *calibrator) Result() (pipeline.Calibration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.active {
+		return pipeline.Calibration{}, errNoCalibration
+	}
+
+	if c.result == nil {
+		return pipeline.Calibration{}, errNotCalibrated
+	}
+
+	return *c.result, nil
+}
+
+func (c *calibrator) Status() CalibrationStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CalibrationStatus{
+		Active:   c.active,
+		Board:    c.board,
+		Views:    len(c.views),
+		MinViews: pipeline.MinCalibrationViews,
+		Coverage: pipeline.MeasureCoverage(c.size, c.views),
+		Result:   c.result,
+	}
+}
+
+func (s *Server) getCalibration(res http.ResponseWriter, req *http.Request) {
+	respond(res, s.calibrator.Status(), http.StatusOK)
+}
+
+func (s *Server) startCalibration(res http.ResponseWriter, req *http.Request) {
+	var board pipeline.Board
+	if err := json.NewDecoder(req.Body).Decode(&board); err != nil {
+		respond(res, err, http.StatusBadRequest)
+		return
+	}
+
+	if err := board.Validate(); err != nil {
+		respond(res, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	s.calibrator.Start(board)
+
+	respond(res, s.calibrator.Status(), http.StatusOK)
+}
+
+func (s *Server) stopCalibration(res http.ResponseWriter, req *http.Request) {
+	s.calibrator.Stop()
+
+	respond(res, nil, http.StatusNoContent)
+}
+
+// captureCalibration captures a view of the board from the next frame of the live camera.
+func (s *Server) captureCalibration(res http.ResponseWriter, req *http.Request) {
+	frame, err := s.grabFrame(req.Context())
+	if err != nil {
+		respond(res, err, http.StatusServiceUnavailable)
+		return
+	}
+	defer frame.Close()
+
+	err = s.calibrator.Capture(image.Pt(frame.Cols(), frame.Rows()), func(board pipeline.Board) ([]pipeline.ImagePoint, bool) {
+		return pipeline.FindBoard(frame, board)
+	})
+	if err != nil {
+		respond(res, err, calibrationStatusCode(err))
+		return
+	}
+
+	respond(res, s.calibrator.Status(), http.StatusOK)
+}
+
+func (s *Server) solveCalibration(res http.ResponseWriter, req *http.Request) {
+	if err := s.calibrator.Solve(); err != nil {
+		respond(res, err, calibrationStatusCode(err))
+		return
+	}
+
+	respond(res, s.calibrator.Status(), http.StatusOK)
+}
+
+// saveCalibration saves the solved intrinsics to the pipeline config in the pipeline query
+// parameter, or every pipeline config if it isn't set, since they're all used with the same
+// camera. If the active pipeline is updated, it's reloaded.
+func (s *Server) saveCalibration(res http.ResponseWriter, req *http.Request) {
+	result, err := s.calibrator.Result()
+	if err != nil {
+		respond(res, err, calibrationStatusCode(err))
+		return
+	}
+
+	names := []string{req.URL.Query().Get("pipeline")}
+	if names[0] == "" {
+		names, err = s.pipelineNames()
+		if err != nil {
+			respond(res, err, statusCode(err))
+			return
+		}
+	}
+
+	active := s.pipelineManager.Name()
+	for _, name := range names {
+		config, err := s.Store.PipelineConfig(name)
+		if err != nil {
+			respond(res, err, statusCode(err))
+			return
+		}
+
+		intrinsics := result.Intrinsics
+		config.Intrinsics = &intrinsics
+
+		if err := s.Store.PutPipelineConfig(name, config); err != nil {
+			respond(res, err, statusCode(err))
+			return
+		}
+
+		if name == active {
+			if err := s.pipelineManager.SetConfig(name, config); err != nil {
+				respond(res, err, http.StatusUnprocessableEntity)
+				return
+			}
+		}
+	}
+
+	respond(res, names, http.StatusOK)
+}
+
+// calibrationStatusCode returns the status code to respond to a calibration request that
+// failed with the error.
+func calibrationStatusCode(err error) int {
+	if errors.Is(err, errNoCalibration) || errors.Is(err, errNotCalibrated) {
+		return http.StatusConflict
+	}
+
+	return http.StatusUnprocessableEntity
+}
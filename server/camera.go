@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gloworm-vision/gloworm-app/source"
+)
+
+// errCaptureNotSettable is returned when the capture doesn't have settings, such as when
+// reading from a video file.
+var errCaptureNotSettable = errors.New("capture doesn't support camera settings")
+
+// withCapture runs fn on the vision loop between frames, since the capture can't be used
+// concurrently with reading frames.
+func (s *Server) withCapture(ctx context.Context, fn func(capture source.FrameSource)) error {
+	done := make(chan struct{})
+	request := func(capture source.FrameSource) {
+		defer close(done)
+		fn(capture)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, frameTimeout)
+	defer cancel()
+
+	select {
+	case s.captureRequests <- request:
+	case <-ctx.Done():
+		return fmt.Errorf("vision loop isn't reading frames: %w", ctx.Err())
+	}
+
+	// once accepted, the request runs on the next frame, so wait for it regardless of ctx
+	<-done
+
+	return nil
+}
+
+// serveCaptureRequests runs any pending withCapture calls.
+func (s *Server) serveCaptureRequests() {
+	for {
+		select {
+		case request := <-s.captureRequests:
+			request(s.Capture)
+		default:
+			return
+		}
+	}
+}
+
+func (s *Server) getCamera(res http.ResponseWriter, req *http.Request) {
+	var settings source.Settings
+	var ok bool
+	err := s.withCapture(req.Context(), func(capture source.FrameSource) {
+		settings, ok = source.ReadSettings(capture)
+	})
+	if err != nil {
+		respond(res, err, http.StatusServiceUnavailable)
+		return
+	}
+	if !ok {
+		respond(res, errCaptureNotSettable, http.StatusNotImplemented)
+		return
+	}
+
+	respond(res, settings, http.StatusOK)
+}
+
+func (s *Server) putCamera(res http.ResponseWriter, req *http.Request) {
+	var settings source.Settings
+	if err := json.NewDecoder(req.Body).Decode(&settings); err != nil {
+		respond(res, err, http.StatusBadRequest)
+		return
+	}
+
+	var ok bool
+	err := s.withCapture(req.Context(), func(capture source.FrameSource) {
+		ok = settings.Apply(capture)
+	})
+	if err != nil {
+		respond(res, err, http.StatusServiceUnavailable)
+		return
+	}
+	if !ok {
+		respond(res, errCaptureNotSettable, http.StatusNotImplemented)
+		return
+	}
+
+	respond(res, nil, http.StatusNoContent)
+}
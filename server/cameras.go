@@ -0,0 +1,279 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gloworm-vision/gloworm-app/pipeline"
+	"github.com/gloworm-vision/gloworm-app/source"
+	"github.com/hybridgroup/mjpeg"
+	"github.com/julienschmidt/httprouter"
+	"gocv.io/x/gocv"
+)
+
+// camera is an additional capture device, with its own pipeline, stream and results.
+type camera struct {
+	id      string
+	capture source.FrameSource
+
+	stream          *mjpeg.Stream
+	pipelineManager *pipelineManager
+	results         *resultBroadcaster
+}
+
+// cameraTable returns the networktables table an additional camera publishes results to.
+func cameraTable(id string) string {
+	return ntTable + "/cameras/" + id
+}
+
+// initCameras creates the additional cameras, each starting with the default pipeline.
+func (s *Server) initCameras() error {
+	s.cameras = make(map[string]*camera, len(s.Cameras))
+
+	for id, capture := range s.Cameras {
+		if err := s.createEntries(cameraTable(id)); err != nil {
+			return fmt.Errorf("unable to create networktables entries for camera %q: %w", id, err)
+		}
+
+		if s.NTPhotonCamera != "" {
+			if err := s.createPhotonEntries(id); err != nil {
+				return fmt.Errorf("unable to create photonvision networktables entries for camera %q: %w", id, err)
+			}
+		}
+
+		cam := &camera{
+			id:              id,
+			capture:         capture,
+			stream:          mjpeg.NewStream(),
+			pipelineManager: &pipelineManager{mu: new(sync.RWMutex)},
+			results:         newResultBroadcaster(s.ResultHistory),
+		}
+
+		if name, err := s.Store.DefaultPipelineConfig(); err == nil {
+			config, err := s.Store.PipelineConfig(name)
+			if err == nil {
+				err = cam.pipelineManager.SetConfig(name, config)
+			}
+			if err != nil {
+				s.Logger.WithField("camera", id).Warnf("unable to setup default pipeline config: %s", err)
+			}
+		}
+
+		s.cameras[id] = cam
+	}
+
+	if s.StereoCameras != nil {
+		if _, _, err := s.stereoCameras(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runCamera runs the vision loop of an additional camera.
+func (s *Server) runCamera(ctx context.Context, cam *camera) error {
+	frameBuffer := gocv.NewMat()
+	defer frameBuffer.Close()
+
+	var rate frameRate
+	var sequence uint64
+
+	var lastPipeline *pipeline.Pipeline
+	defer func() { closeReplaced(lastPipeline, nil) }()
+
+	pipelineIndex := -1
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+			if !cam.capture.Read(&frameBuffer) {
+				if err := s.reconnectCamera(ctx, cam); err != nil {
+					// the camera is given up on rather than stopping the other cameras
+					<-ctx.Done()
+					return nil
+				}
+				continue
+			}
+			captured := time.Now()
+			fps := rate.tick(captured)
+			sequence++
+
+			pipeline := cam.pipelineManager.Pipeline()
+			if pipeline != lastPipeline && pipeline != nil {
+				if s.NTPhotonCamera != "" {
+					pipelineIndex = s.pipelineIndex(cam.pipelineManager.Name())
+				}
+
+				if pipeline.Config.Camera != nil && !pipeline.Config.Camera.Apply(cam.capture) {
+					s.Logger.WithField("camera", cam.id).Warn(errCaptureNotSettable)
+				}
+			}
+			lastPipeline = closeReplaced(lastPipeline, pipeline)
+
+			if pipeline != nil {
+				result := pipeline.ProcessFrame(frameBuffer, &frameBuffer, captured)
+				result.Sequence = sequence
+				pipeline.DrawStamp(&frameBuffer, result)
+
+				s.publishResult(cameraTable(cam.id), result)
+				if s.NTPhotonCamera != "" {
+					s.publishPhoton(cam.id, pipeline.Config, result, pipelineIndex)
+				}
+				cam.results.publish(resultMessage{
+					Result:      result,
+					Pipeline:    cam.pipelineManager.Name(),
+					TargetFound: len(result.Targets) > 0,
+					FPS:         fps,
+				})
+			}
+
+			buf, err := gocv.IMEncode(".jpg", frameBuffer)
+			if err != nil {
+				return fmt.Errorf("encode camera %q frame buffer: %w", cam.id, err)
+			}
+
+			cam.stream.UpdateJPEG(buf)
+		}
+	}
+}
+
+// reconnectCamera closes the camera's capture and reopens it with OpenCamera, backing off
+// between failed attempts as reconnect does, returning an error if it can't be reopened
+// because OpenCamera isn't set or ctx is done.
+func (s *Server) reconnectCamera(ctx context.Context, cam *camera) error {
+	logger := s.Logger.WithField("camera", cam.id)
+	if s.OpenCamera == nil {
+		logger.Error("couldn't read from camera, stopping its vision loop")
+		return errors.New("camera can't be reopened")
+	}
+
+	logger.Warn("couldn't read from camera, reconnecting")
+
+	if err := cam.capture.Close(); err != nil {
+		logger.Warnf("unable to close camera: %s", err)
+	}
+
+	capture, err := reopen(ctx, func() (source.FrameSource, error) {
+		return s.OpenCamera(cam.id)
+	}, func(err error) {
+		logger.Debugf("unable to reopen camera: %s", err)
+	})
+	if err != nil {
+		return err
+	}
+	cam.capture = capture
+
+	if pipeline := cam.pipelineManager.Pipeline(); pipeline != nil && pipeline.Config.Camera != nil {
+		if !pipeline.Config.Camera.Apply(cam.capture) {
+			logger.Warn(errCaptureNotSettable)
+		}
+	}
+
+	logger.Info("reconnected camera")
+
+	return nil
+}
+
+// camera returns the camera identified by the request's id parameter, responding with an
+// error if it doesn't exist.
+func (s *Server) camera(res http.ResponseWriter, req *http.Request) (*camera, bool) {
+	params := httprouter.ParamsFromContext(req.Context())
+	id := params.ByName("id")
+
+	cam, ok := s.cameras[id]
+	if !ok {
+		respond(res, fmt.Errorf("no camera %q", id), http.StatusNotFound)
+		return nil, false
+	}
+
+	return cam, true
+}
+
+func (s *Server) listCameras(res http.ResponseWriter, req *http.Request) {
+	ids := make([]string, 0, len(s.cameras))
+	for id := range s.cameras {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	respond(res, ids, http.StatusOK)
+}
+
+func (s *Server) getCameraStream(res http.ResponseWriter, req *http.Request) {
+	cam, ok := s.camera(res, req)
+	if !ok {
+		return
+	}
+
+	s.serveStream(res, req, cam.stream)
+}
+
+func (s *Server) getCameraResult(res http.ResponseWriter, req *http.Request) {
+	cam, ok := s.camera(res, req)
+	if !ok {
+		return
+	}
+
+	result, ok := cam.results.last()
+	if !ok {
+		respond(res, errors.New("no results yet"), http.StatusNotFound)
+		return
+	}
+
+	respond(res, result, http.StatusOK)
+}
+
+// getCameraResultHistory responds with the camera's recent results, as resultHistory does.
+func (s *Server) getCameraResultHistory(res http.ResponseWriter, req *http.Request) {
+	cam, ok := s.camera(res, req)
+	if !ok {
+		return
+	}
+
+	serveResultHistory(res, req, cam.results)
+}
+
+func (s *Server) getCameraPipeline(res http.ResponseWriter, req *http.Request) {
+	cam, ok := s.camera(res, req)
+	if !ok {
+		return
+	}
+
+	respond(res, cam.pipelineManager.Name(), http.StatusOK)
+}
+
+// putCameraPipeline sets the active pipeline of the camera to the named pipeline config.
+func (s *Server) putCameraPipeline(res http.ResponseWriter, req *http.Request) {
+	cam, ok := s.camera(res, req)
+	if !ok {
+		return
+	}
+
+	var name string
+	if err := json.NewDecoder(req.Body).Decode(&name); err != nil {
+		respond(res, err, http.StatusBadRequest)
+		return
+	}
+
+	config, err := s.Store.PipelineConfig(name)
+	if err != nil {
+		respond(res, err, statusCode(err))
+		return
+	}
+
+	if err := cam.pipelineManager.SetConfig(name, config); err != nil {
+		respond(res, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	respond(res, nil, http.StatusNoContent)
+}
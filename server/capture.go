@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/gloworm-vision/gloworm-app/pipeline"
+	"gocv.io/x/gocv"
+)
+
+// frameQueueSize is the number of captured frames waiting to be processed. When processing
+// falls behind, the oldest waiting frame is dropped, so the latest frame is always processed
+// next and the camera is never left waiting.
+const frameQueueSize = 1
+
+// capturedFrame is a frame read by the capture loop, waiting to be processed.
+type capturedFrame struct {
+	mat      gocv.Mat
+	captured time.Time
+	sequence uint64
+
+	// fps is the rate frames are being captured at.
+	fps float64
+}
+
+// frameQueue passes captured frames from the capture loop to the processing loop. The mats of
+// processed and dropped frames are reused to read later frames into.
+type frameQueue struct {
+	frames chan capturedFrame
+	free   chan gocv.Mat
+
+	// captured and dropped count the frames captured and dropped, and are updated atomically.
+	captured uint64
+	dropped  uint64
+}
+
+func newFrameQueue() *frameQueue {
+	return &frameQueue{
+		frames: make(chan capturedFrame, frameQueueSize),
+		free:   make(chan gocv.Mat, frameQueueSize+1),
+	}
+}
+
+// get returns a mat to read the next frame into, reusing a processed frame's if possible.
+func (q *frameQueue) get() gocv.Mat {
+	select {
+	case mat := <-q.free:
+		return mat
+	default:
+		return gocv.NewMat()
+	}
+}
+
+// put returns a processed frame's mat to be reused, closing it if enough are already waiting.
+func (q *frameQueue) put(mat gocv.Mat) {
+	select {
+	case q.free <- mat:
+	default:
+		mat.Close()
+	}
+}
+
+// push queues the frame, dropping the oldest waiting frame if the queue is full. It must only
+// be called by the capture loop.
+func (q *frameQueue) push(frame capturedFrame) {
+	atomic.AddUint64(&q.captured, 1)
+
+	for {
+		select {
+		case q.frames <- frame:
+			return
+		default:
+		}
+
+		select {
+		case old := <-q.frames:
+			q.put(old.mat)
+			atomic.AddUint64(&q.dropped, 1)
+		default:
+		}
+	}
+}
+
+// counts returns the number of frames captured and dropped so far.
+func (q *frameQueue) counts() (captured, dropped uint64) {
+	return atomic.LoadUint64(&q.captured), atomic.LoadUint64(&q.dropped)
+}
+
+// close closes the mats waiting to be reused, once both loops have stopped.
+func (q *frameQueue) close() {
+	for {
+		select {
+		case mat := <-q.free:
+			mat.Close()
+		default:
+			return
+		}
+	}
+}
+
+// captureFrames reads frames from the capture into the queue until ctx is done or the capture
+// can't be read. The capture isn't safe to use concurrently, so requests to use it and the
+// active pipeline's camera settings are handled here too.
+func (s *Server) captureFrames(ctx context.Context, queue *frameQueue) error {
+	var sequence uint64
+
+	// the pipeline whose camera settings were last applied, and its name
+	var lastPipeline *pipeline.Pipeline
+	var lastName string
+
+	for ctx.Err() == nil {
+		mat := queue.get()
+		if err := s.readFrame(ctx, &mat); err != nil {
+			mat.Close()
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return err
+		}
+		captured := time.Now()
+		sequence++
+
+		s.serveCaptureRequests()
+
+		pipeline, name := s.pipelineManager.Pipeline(), s.pipelineManager.Name()
+		if pipeline != lastPipeline && pipeline != nil {
+			// settings are only applied when they change, since changing some resets others
+			changed := lastPipeline == nil || name != lastName || !reflect.DeepEqual(lastPipeline.Config.Camera, pipeline.Config.Camera)
+			if changed && pipeline.Config.Camera != nil && !pipeline.Config.Camera.Apply(s.Capture) {
+				s.Logger.Warn(errCaptureNotSettable)
+			}
+			lastPipeline, lastName = pipeline, name
+		}
+
+		queue.push(capturedFrame{mat: mat, captured: captured, sequence: sequence, fps: s.frameRates.captured(captured)})
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gloworm-vision/gloworm-app/pipeline"
+	"gocv.io/x/gocv"
+)
+
+// comparisonStream is the name of the stream the candidate pipeline's output is served on.
+const comparisonStream = "compare"
+
+// comparisonManager synchronizes access to a candidate pipeline that's run alongside the
+// active pipeline on the same frames, and the latest results of both, so configs can be
+// compared side by side.
+type comparisonManager struct {
+	pipeline *pipeline.Pipeline
+	mu       *sync.RWMutex
+
+	current, candidate pipeline.Result
+}
+
+func (c *comparisonManager) SetConfig(config pipeline.Config) error {
+	candidate, err := pipeline.New(config)
+	if err != nil {
+		return fmt.Errorf("unable to create new pipeline from config: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pipeline = candidate
+	c.current, c.candidate = pipeline.Result{}, pipeline.Result{}
+
+	return nil
+}
+
+// Stop stops comparing.
+func (c *comparisonManager) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pipeline = nil
+}
+
+func (c *comparisonManager) Pipeline() *pipeline.Pipeline {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.pipeline
+}
+
+// SetResults records the latest results of the active and candidate pipelines.
+func (c *comparisonManager) SetResults(current, candidate pipeline.Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.current, c.candidate = current, candidate
+}
+
+// Results returns the latest results of the active and candidate pipelines, and false if
+// no comparison is running.
+func (c *comparisonManager) Results() (current, candidate pipeline.Result, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.current, c.candidate, c.pipeline != nil
+}
+
+// processComparison runs the candidate pipeline (if any) on a copy of the frame, streaming
+// its output. It must be called before the active pipeline draws onto the frame.
+func (s *Server) processComparison(candidate *pipeline.Pipeline, frame gocv.Mat, captured time.Time) (pipeline.Result, bool) {
+	if candidate == nil {
+		return pipeline.Result{}, false
+	}
+
+	out := frame.Clone()
+	defer out.Close()
+
+	result := candidate.ProcessFrame(frame, &out, captured)
+
+	buf, err := gocv.IMEncode(".jpg", out)
+	if err != nil {
+		s.Logger.Warnf("unable to encode comparison frame: %s", err)
+	} else {
+		s.streams[comparisonStream].UpdateJPEG(buf)
+	}
+
+	return result, true
+}
+
+type comparisonResponse struct {
+	Current   pipeline.Result `json:"current"`
+	Candidate pipeline.Result `json:"candidate"`
+}
+
+func (s *Server) getComparison(res http.ResponseWriter, req *http.Request) {
+	current, candidate, ok := s.comparisonManager.Results()
+	if !ok {
+		respond(res, errors.New("no comparison running"), http.StatusNotFound)
+		return
+	}
+
+	respond(res, comparisonResponse{Current: current, Candidate: candidate}, http.StatusOK)
+}
+
+func (s *Server) putComparison(res http.ResponseWriter, req *http.Request) {
+	var config pipeline.Config
+	if err := json.NewDecoder(req.Body).Decode(&config); err != nil {
+		respond(res, err, http.StatusBadRequest)
+		return
+	}
+
+	if err := config.Validate(); err != nil {
+		respond(res, err, statusCode(err))
+		return
+	}
+
+	if err := s.comparisonManager.SetConfig(config); err != nil {
+		respond(res, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	respond(res, nil, http.StatusNoContent)
+}
+
+func (s *Server) deleteComparison(res http.ResponseWriter, req *http.Request) {
+	s.comparisonManager.Stop()
+
+	respond(res, nil, http.StatusNoContent)
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	corsMethods = "GET, HEAD, POST, PUT, DELETE"
+	corsHeaders = "Authorization, Content-Type, X-Request-ID"
+)
+
+// allowedOrigin returns whether cross-origin requests from the origin are allowed, and whether
+// it's allowed by name rather than only by the * wildcard.
+func (s *Server) allowedOrigin(origin string) (allowed, named bool) {
+	for _, o := range s.AllowedOrigins {
+		if strings.EqualFold(o, origin) {
+			return true, true
+		}
+
+		if o == "*" {
+			allowed = true
+		}
+	}
+
+	return allowed, false
+}
+
+// allowedUpgrade returns whether a websocket upgrade request comes from the same origin or an
+// allowed origin. Browsers don't apply the same-origin policy to websockets, so without this
+// any site could use a websocket that changes the server's state.
+func (s *Server) allowedUpgrade(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, req.Host) {
+		return true
+	}
+
+	allowed, _ := s.allowedOrigin(origin)
+	return allowed
+}
+
+// cors wraps the handler to allow cross-origin requests from the allowed origins, and
+// responds to their preflight requests. Without any allowed origins, only same-origin
+// requests are allowed, as browsers do by default.
+func (s *Server) cors(next http.Handler) http.Handler {
+	if len(s.AllowedOrigins) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		origin := req.Header.Get("Origin")
+		allowed, named := s.allowedOrigin(origin)
+		if origin == "" || !allowed {
+			next.ServeHTTP(res, req)
+			return
+		}
+
+		res.Header().Set("Access-Control-Allow-Origin", origin)
+
+		// any site could otherwise make requests with the browser's saved credentials
+		if named {
+			res.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+		res.Header().Add("Vary", "Origin")
+
+		if req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != "" {
+			res.Header().Set("Access-Control-Allow-Methods", corsMethods)
+			res.Header().Set("Access-Control-Allow-Headers", corsHeaders)
+			res.Header().Set("Access-Control-Max-Age", "600")
+			res.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(res, req)
+	})
+}
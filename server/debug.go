@@ -0,0 +1,77 @@
+package server
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// debugVarsName is the name the server's runtime vars are published under at /debug/vars.
+const debugVarsName = "gloworm"
+
+// debugVars are the server's runtime vars, published alongside the standard memstats and
+// cmdline at /debug/vars.
+type debugVars struct {
+	Goroutines int `json:"goroutines"`
+
+	// Captured and Dropped are the frames read from the capture, and those skipped because
+	// processing fell behind.
+	Captured uint64 `json:"captured"`
+	Dropped  uint64 `json:"dropped"`
+
+	FrameRates FrameRates `json:"frameRates"`
+	Pipeline   string     `json:"pipeline"`
+}
+
+// publishDebugVars publishes the server's runtime vars. Vars can only be published once per
+// process, so they aren't republished if they already are.
+func (s *Server) publishDebugVars() {
+	if expvar.Get(debugVarsName) != nil {
+		return
+	}
+
+	expvar.Publish(debugVarsName, expvar.Func(func() interface{} {
+		capture := s.captureMonitor.Status()
+
+		return debugVars{
+			Goroutines: runtime.NumGoroutine(),
+			Captured:   capture.Captured,
+			Dropped:    capture.Dropped,
+			FrameRates: s.frameRates.Rates(),
+			Pipeline:   s.pipelineManager.Name(),
+		}
+	}))
+}
+
+// requireDebugAuth wraps the handler so every request needs valid credentials, if any are
+// set, since profiles reveal the server's internals and some take a while to collect.
+func (s *Server) requireDebugAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if s.Auth.enabled() && !s.Auth.authorized(req) {
+			s.Auth.unauthorized(res)
+			return
+		}
+
+		next(res, req)
+	}
+}
+
+// pprof serves the runtime profiles under /debug/pprof, as net/http/pprof does on the default
+// mux. The router can't have other routes alongside a catch-all, so they're dispatched here.
+func (s *Server) pprof(res http.ResponseWriter, req *http.Request) {
+	switch httprouter.ParamsFromContext(req.Context()).ByName("profile") {
+	case "/cmdline":
+		pprof.Cmdline(res, req)
+	case "/profile":
+		pprof.Profile(res, req)
+	case "/symbol":
+		pprof.Symbol(res, req)
+	case "/trace":
+		pprof.Trace(res, req)
+	default:
+		pprof.Index(res, req)
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// savedFile is a file saved by the server, such as a snapshot or recording.
+type savedFile struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// hasExt returns whether the name has one of the extensions (without the dot).
+func hasExt(name string, exts map[string]bool) bool {
+	return exts[strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))]
+}
+
+// listFiles returns the files in the directory with one of the extensions, sorted by name.
+// A missing directory has no files.
+func listFiles(dir string, exts map[string]bool) ([]savedFile, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("unable to read directory: %w", err)
+	}
+
+	files := make([]savedFile, 0, len(infos))
+	for _, info := range infos {
+		if info.IsDir() || !hasExt(info.Name(), exts) {
+			continue
+		}
+
+		files = append(files, savedFile{Name: info.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	return files, nil
+}
+
+// serveSavedFile serves the named file in the directory, if it has one of the extensions.
+func serveSavedFile(res http.ResponseWriter, req *http.Request, dir, name string, exts map[string]bool) {
+	path := filepath.Join(dir, name)
+	if name != filepath.Base(name) || !hasExt(name, exts) {
+		respond(res, fmt.Errorf("no file %q", name), http.StatusNotFound)
+		return
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		respond(res, fmt.Errorf("no file %q", name), http.StatusNotFound)
+		return
+	}
+
+	http.ServeFile(res, req, path)
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// frameTimeout is how long grabFrame waits for the vision loop to provide a frame.
+const frameTimeout = time.Second * 5
+
+// grabFrame returns a copy of the next raw (unprocessed) frame read by the vision loop.
+// The caller must close the returned mat.
+func (s *Server) grabFrame(ctx context.Context) (gocv.Mat, error) {
+	reply := make(chan gocv.Mat, 1)
+
+	ctx, cancel := context.WithTimeout(ctx, frameTimeout)
+	defer cancel()
+
+	select {
+	case s.frameRequests <- reply:
+	case <-ctx.Done():
+		return gocv.Mat{}, fmt.Errorf("vision loop isn't reading frames: %w", ctx.Err())
+	}
+
+	select {
+	case frame := <-reply:
+		if frame.Empty() {
+			frame.Close()
+			return gocv.Mat{}, errors.New("captured frame is empty")
+		}
+
+		return frame, nil
+	case <-ctx.Done():
+		// the vision loop accepted the request, so it will reply on the buffered channel;
+		// make sure that frame gets closed
+		go func() {
+			frame := <-reply
+			frame.Close()
+		}()
+
+		return gocv.Mat{}, fmt.Errorf("timed out waiting for frame: %w", ctx.Err())
+	}
+}
+
+// serveFrameRequests replies to any pending grabFrame calls with a copy of the frame.
+func (s *Server) serveFrameRequests(frame gocv.Mat) {
+	for {
+		select {
+		case reply := <-s.frameRequests:
+			reply <- frame.Clone()
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// snapshotTagsFile is the file in the snapshot directory the snapshots' tags are saved in.
+	snapshotTagsFile = "tags.json"
+
+	// defaultMaxSnapshotSize is the total size snapshots are kept under if
+	// Server.MaxSnapshotSize isn't set.
+	defaultMaxSnapshotSize = 1 << 30
+)
+
+// errNoSnapshot is returned when a snapshot doesn't exist.
+var errNoSnapshot = errors.New("no such snapshot")
+
+// snapshotFile is a saved snapshot image and its tags.
+type snapshotFile struct {
+	savedFile
+	Tags []string `json:"tags"`
+}
+
+// snapshotGallery manages the saved snapshots, which are tagged so they can be found after
+// an event, and are evicted oldest first to keep their total size under maxSize so they
+// don't fill the SD card.
+type snapshotGallery struct {
+	mu sync.Mutex
+
+	dir     string
+	maxSize int64
+}
+
+// List returns the snapshots with the tag, or every snapshot if the tag is empty, sorted by
+// name.
+func (g *snapshotGallery) List(tag string) ([]snapshotFile, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	files, err := listFiles(g.dir, snapshotFormats)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := g.readTags()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]snapshotFile, 0, len(files))
+	for _, file := range files {
+		snapshot := snapshotFile{savedFile: file, Tags: tags[file.Name]}
+		if snapshot.Tags == nil {
+			snapshot.Tags = []string{}
+		}
+
+		if tag == "" || containsTag(snapshot.Tags, tag) {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+
+	return snapshots, nil
+}
+
+// SetTags replaces the snapshot's tags. Tags are trimmed, and empty and duplicate tags are
+// removed.
+func (g *snapshotGallery) SetTags(name string, tags []string) ([]string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := g.exists(name); err != nil {
+		return nil, err
+	}
+
+	cleaned := []string{}
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" && !containsTag(cleaned, tag) {
+			cleaned = append(cleaned, tag)
+		}
+	}
+
+	all, err := g.readTags()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cleaned) == 0 {
+		delete(all, name)
+	} else {
+		all[name] = cleaned
+	}
+
+	if err := g.writeTags(all); err != nil {
+		return nil, err
+	}
+
+	return cleaned, nil
+}
+
+// Delete deletes the snapshot and its tags.
+func (g *snapshotGallery) Delete(name string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := g.exists(name); err != nil {
+		return err
+	}
+
+	return g.remove(name)
+}
+
+// Evict deletes the oldest snapshots until their total size is under maxSize, and returns the
+// names of the deleted snapshots. The snapshots named by keep (such as the ones just saved)
+// are never deleted.
+func (g *snapshotGallery) Evict(keep ...string) ([]string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	files, err := listFiles(g.dir, snapshotFormats)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for _, file := range files {
+		total += file.Size
+	}
+
+	// snapshot names start with the time they were taken, so ties are broken by name
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].ModTime.Equal(files[j].ModTime) {
+			return files[i].ModTime.Before(files[j].ModTime)
+		}
+
+		return files[i].Name < files[j].Name
+	})
+
+	var evicted []string
+	for _, file := range files {
+		if total <= g.maxSize {
+			break
+		}
+		if containsTag(keep, file.Name) {
+			continue
+		}
+
+		if err := g.remove(file.Name); err != nil {
+			return evicted, err
+		}
+
+		total -= file.Size
+		evicted = append(evicted, file.Name)
+	}
+
+	return evicted, nil
+}
+
+// exists returns errNoSnapshot if the name isn't a snapshot in the directory.
+func (g *snapshotGallery) exists(name string) error {
+	if name != filepath.Base(name) || !hasExt(name, snapshotFormats) {
+		return fmt.Errorf("%w %q", errNoSnapshot, name)
+	}
+
+	if _, err := os.Stat(filepath.Join(g.dir, name)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w %q", errNoSnapshot, name)
+		}
+
+		return fmt.Errorf("unable to stat snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// remove deletes the snapshot and its tags, if it has any.
+func (g *snapshotGallery) remove(name string) error {
+	if err := os.Remove(filepath.Join(g.dir, name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to delete snapshot: %w", err)
+	}
+
+	tags, err := g.readTags()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := tags[name]; !ok {
+		return nil
+	}
+	delete(tags, name)
+
+	return g.writeTags(tags)
+}
+
+// readTags reads the tags of every snapshot, by name.
+func (g *snapshotGallery) readTags() (map[string][]string, error) {
+	tags := make(map[string][]string)
+
+	data, err := ioutil.ReadFile(filepath.Join(g.dir, snapshotTagsFile))
+	if os.IsNotExist(err) {
+		return tags, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read snapshot tags: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return nil, fmt.Errorf("unable to decode snapshot tags: %w", err)
+	}
+
+	return tags, nil
+}
+
+// writeTags replaces the tags of every snapshot. The tags are written to a temporary file
+// first, so they aren't lost if the device loses power partway through.
+func (g *snapshotGallery) writeTags(tags map[string][]string) error {
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return fmt.Errorf("unable to encode snapshot tags: %w", err)
+	}
+
+	path := filepath.Join(g.dir, snapshotTagsFile)
+	if err := ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("unable to write snapshot tags: %w", err)
+	}
+
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("unable to write snapshot tags: %w", err)
+	}
+
+	return nil
+}
+
+// containsTag returns whether the tag is in the tags.
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
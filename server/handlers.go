@@ -2,17 +2,28 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gloworm-vision/gloworm-app/hardware"
 	"github.com/gloworm-vision/gloworm-app/pipeline"
+	"github.com/gloworm-vision/gloworm-app/source"
+	"github.com/gloworm-vision/gloworm-app/store"
+	"github.com/gloworm-vision/gloworm-app/system"
 	"github.com/julienschmidt/httprouter"
 )
 
+// errEmptyName is returned when copying or renaming a pipeline config to an empty name.
+var errEmptyName = errors.New("pipeline config name must not be empty")
+
 func (s *Server) getDefaultPipeline(res http.ResponseWriter, req *http.Request) {
 	name, err := s.Store.DefaultPipelineConfig()
 	if err != nil {
-		respond(res, err, http.StatusInternalServerError)
+		respond(res, err, statusCode(err))
 		return
 	}
 
@@ -22,64 +33,311 @@ func (s *Server) getDefaultPipeline(res http.ResponseWriter, req *http.Request)
 func (s *Server) putDefaultPipeline(res http.ResponseWriter, req *http.Request) {
 	var name string
 	if err := json.NewDecoder(req.Body).Decode(&name); err != nil {
-		respond(res, err, http.StatusUnprocessableEntity)
+		respond(res, err, http.StatusBadRequest)
 		return
 	}
 
 	if err := s.Store.PutDefaultPipelineConfig(name); err != nil {
-		respond(res, err, http.StatusInternalServerError)
+		respond(res, err, statusCode(err))
 		return
 	}
 
 	respond(res, nil, http.StatusNoContent)
 }
 
+// pipelines lists the name and metadata of every pipeline config, or only those with the tag
+// query parameter if it's set.
 func (s *Server) pipelines(res http.ResponseWriter, req *http.Request) {
 	pipelines, err := s.Store.ListPipelineConfigs()
 	if err != nil {
-		respond(res, err, http.StatusInternalServerError)
+		respond(res, err, statusCode(err))
 		return
 	}
 
+	if tag := req.URL.Query().Get("tag"); tag != "" {
+		tagged := make([]store.PipelineConfigEntry, 0, len(pipelines))
+		for _, entry := range pipelines {
+			if entry.HasTag(tag) {
+				tagged = append(tagged, entry)
+			}
+		}
+		pipelines = tagged
+	}
+
 	respond(res, pipelines, http.StatusOK)
 }
 
+// pipelineNames returns the names of every pipeline config, sorted.
+func (s *Server) pipelineNames() ([]string, error) {
+	entries, err := s.Store.ListPipelineConfigs()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name
+	}
+
+	return names, nil
+}
+
+func (s *Server) getPipelineMetadata(res http.ResponseWriter, req *http.Request) {
+	params := httprouter.ParamsFromContext(req.Context())
+	name := params.ByName("name")
+
+	metadata, err := s.Store.PipelineConfigMetadata(name)
+	if err != nil {
+		respond(res, err, statusCode(err))
+		return
+	}
+
+	respond(res, metadata, http.StatusOK)
+}
+
+// putPipelineMetadata replaces the named pipeline config's description, author and tags. Its
+// created and modified times are kept by the store.
+func (s *Server) putPipelineMetadata(res http.ResponseWriter, req *http.Request) {
+	params := httprouter.ParamsFromContext(req.Context())
+	name := params.ByName("name")
+
+	var metadata store.PipelineConfigMetadata
+	if err := json.NewDecoder(req.Body).Decode(&metadata); err != nil {
+		respond(res, err, http.StatusBadRequest)
+		return
+	}
+
+	if err := metadata.Validate(); err != nil {
+		respond(res, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err := s.Store.PutPipelineConfigMetadata(name, metadata); err != nil {
+		respond(res, err, statusCode(err))
+		return
+	}
+
+	respond(res, nil, http.StatusNoContent)
+}
+
 func (s *Server) getPipeline(res http.ResponseWriter, req *http.Request) {
 	params := httprouter.ParamsFromContext(req.Context())
 	name := params.ByName("name")
 
 	config, err := s.Store.PipelineConfig(name)
 	if err != nil {
-		respond(res, err, http.StatusInternalServerError)
+		respond(res, err, statusCode(err))
 		return
 	}
 
 	respond(res, config, http.StatusOK)
 }
 
+// putPipeline puts the named pipeline config. If it's the active pipeline's config, the
+// active pipeline is updated too, unless the apply query parameter is false.
 func (s *Server) putPipeline(res http.ResponseWriter, req *http.Request) {
 	params := httprouter.ParamsFromContext(req.Context())
 	name := params.ByName("name")
 
+	apply, err := applyQuery(req)
+	if err != nil {
+		respond(res, err, http.StatusBadRequest)
+		return
+	}
+
 	var config pipeline.Config
 	if err := json.NewDecoder(req.Body).Decode(&config); err != nil {
-		respond(res, err, http.StatusUnprocessableEntity)
+		respond(res, err, http.StatusBadRequest)
+		return
+	}
+
+	if err := config.Validate(); err != nil {
+		respond(res, err, statusCode(err))
+		return
+	}
+
+	err = s.Store.PutPipelineConfig(name, config)
+	if err != nil {
+		respond(res, err, statusCode(err))
+		return
+	}
+
+	if apply && name == s.pipelineManager.Name() {
+		if err := s.pipelineManager.SetConfig(name, config); err != nil {
+			respond(res, err, http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	respond(res, nil, http.StatusNoContent)
+}
+
+// applyQuery returns whether a changed pipeline config should be applied to the active
+// pipeline, which it is unless the apply query parameter is false.
+func applyQuery(req *http.Request) (bool, error) {
+	raw := req.URL.Query().Get("apply")
+	if raw == "" {
+		return true, nil
+	}
+
+	apply, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("invalid apply %q", raw)
+	}
+
+	return apply, nil
+}
+
+// deletePipeline deletes the named pipeline config, unless it's the default.
+func (s *Server) deletePipeline(res http.ResponseWriter, req *http.Request) {
+	params := httprouter.ParamsFromContext(req.Context())
+	name := params.ByName("name")
+
+	if err := s.Store.DeletePipelineConfig(name); err != nil {
+		respond(res, err, statusCode(err))
+		return
+	}
+
+	respond(res, nil, http.StatusNoContent)
+}
+
+// duplicatePipeline copies the named pipeline config to the name in the body.
+func (s *Server) duplicatePipeline(res http.ResponseWriter, req *http.Request) {
+	params := httprouter.ParamsFromContext(req.Context())
+	name := params.ByName("name")
+
+	var to string
+	if err := json.NewDecoder(req.Body).Decode(&to); err != nil {
+		respond(res, err, http.StatusBadRequest)
+		return
+	}
+
+	if to == "" {
+		respond(res, errEmptyName, http.StatusBadRequest)
+		return
+	}
+
+	if err := s.Store.CopyPipelineConfig(name, to); err != nil {
+		respond(res, err, statusCode(err))
+		return
+	}
+
+	respond(res, nil, http.StatusNoContent)
+}
+
+// renamePipeline renames the named pipeline config to the name in the body.
+func (s *Server) renamePipeline(res http.ResponseWriter, req *http.Request) {
+	params := httprouter.ParamsFromContext(req.Context())
+	name := params.ByName("name")
+
+	var to string
+	if err := json.NewDecoder(req.Body).Decode(&to); err != nil {
+		respond(res, err, http.StatusBadRequest)
+		return
+	}
+
+	if to == "" {
+		respond(res, errEmptyName, http.StatusBadRequest)
+		return
+	}
+
+	if err := s.Store.RenamePipelineConfig(name, to); err != nil {
+		respond(res, err, statusCode(err))
+		return
+	}
+
+	s.pipelineManager.Rename(name, to)
+	for _, cam := range s.cameras {
+		cam.pipelineManager.Rename(name, to)
+	}
+
+	respond(res, nil, http.StatusNoContent)
+}
+
+// pipelineRevisions lists the previous revisions of the named pipeline config, newest first.
+func (s *Server) pipelineRevisions(res http.ResponseWriter, req *http.Request) {
+	params := httprouter.ParamsFromContext(req.Context())
+	name := params.ByName("name")
+
+	revisions, err := s.Store.PipelineConfigRevisions(name)
+	if err != nil {
+		respond(res, err, statusCode(err))
 		return
 	}
 
-	err := s.Store.PutPipelineConfig(name, config)
+	respond(res, revisions, http.StatusOK)
+}
+
+// restorePipeline restores the named pipeline config to the revision number in the body. Like
+// putting it, the active pipeline is updated too, unless the apply query parameter is false.
+func (s *Server) restorePipeline(res http.ResponseWriter, req *http.Request) {
+	params := httprouter.ParamsFromContext(req.Context())
+	name := params.ByName("name")
+
+	apply, err := applyQuery(req)
 	if err != nil {
-		respond(res, err, http.StatusInternalServerError)
+		respond(res, err, http.StatusBadRequest)
+		return
+	}
+
+	var number int
+	if err := json.NewDecoder(req.Body).Decode(&number); err != nil {
+		respond(res, err, http.StatusBadRequest)
 		return
 	}
 
+	if err := s.Store.RestorePipelineConfig(name, number); err != nil {
+		respond(res, err, statusCode(err))
+		return
+	}
+
+	if apply && name == s.pipelineManager.Name() {
+		config, err := s.Store.PipelineConfig(name)
+		if err == nil {
+			err = s.pipelineManager.SetConfig(name, config)
+		}
+		if err != nil {
+			respond(res, err, http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
 	respond(res, nil, http.StatusNoContent)
 }
 
+func (s *Server) pipelineSchema(res http.ResponseWriter, req *http.Request) {
+	respond(res, pipeline.Schema(), http.StatusOK)
+}
+
+func (s *Server) profile(res http.ResponseWriter, req *http.Request) {
+	if !s.Profiling {
+		respond(res, errors.New("profiling is disabled"), http.StatusNotFound)
+		return
+	}
+
+	active := s.pipelineManager.Pipeline()
+	if active == nil {
+		respond(res, errors.New("no active pipeline"), http.StatusNotFound)
+		return
+	}
+
+	respond(res, active.Profile(), http.StatusOK)
+}
+
+// systemResponse is the state of the device, and the rates the vision loop is running at.
+type systemResponse struct {
+	system.Stats
+	FrameRates FrameRates `json:"frameRates"`
+}
+
+func (s *Server) getSystem(res http.ResponseWriter, req *http.Request) {
+	respond(res, systemResponse{Stats: s.system.Stats(req.Context()), FrameRates: s.frameRates.Rates()}, http.StatusOK)
+}
+
 func (s *Server) getHardware(res http.ResponseWriter, req *http.Request) {
 	config, err := s.Store.HardwareConfig()
 	if err != nil {
-		respond(res, err, http.StatusInternalServerError)
+		respond(res, err, statusCode(err))
 		return
 	}
 
@@ -89,43 +347,176 @@ func (s *Server) getHardware(res http.ResponseWriter, req *http.Request) {
 func (s *Server) putHardware(res http.ResponseWriter, req *http.Request) {
 	var hardware hardware.Config
 	if err := json.NewDecoder(req.Body).Decode(&hardware); err != nil {
-		respond(res, err, http.StatusUnprocessableEntity)
+		respond(res, err, http.StatusBadRequest)
 		return
 	}
 
 	if err := s.Store.PutHardwareConfig(hardware); err != nil {
-		respond(res, err, http.StatusInternalServerError)
+		respond(res, err, statusCode(err))
+		return
+	}
+
+	respond(res, nil, http.StatusNoContent)
+}
+
+func (s *Server) exportConfig(res http.ResponseWriter, req *http.Request) {
+	backup, err := s.Store.Export()
+	if err != nil {
+		respond(res, err, statusCode(err))
+		return
+	}
+
+	res.Header().Set("Content-Disposition", `attachment; filename="gloworm-config.json"`)
+	respond(res, backup, http.StatusOK)
+}
+
+// importConfig replaces every stored config with those exported by exportConfig. Like
+// putting configs, the imported configs aren't applied until they're updated.
+func (s *Server) importConfig(res http.ResponseWriter, req *http.Request) {
+	var backup store.Backup
+	if err := json.NewDecoder(req.Body).Decode(&backup); err != nil {
+		respond(res, err, http.StatusBadRequest)
+		return
+	}
+
+	if err := backup.Validate(); err != nil {
+		respond(res, err, statusCode(err))
+		return
+	}
+
+	if err := s.Store.Import(backup); err != nil {
+		respond(res, err, statusCode(err))
 		return
 	}
 
 	respond(res, nil, http.StatusNoContent)
 }
 
+// updatePipelineResponse is what took effect when a pipeline config was reloaded from the
+// store.
+type updatePipelineResponse struct {
+	Name   string          `json:"name"`
+	Config pipeline.Config `json:"config"`
+
+	// Warnings are parts of the config that the hardware or capture can't apply.
+	Warnings []string `json:"warnings"`
+
+	// Duration is how long the pipeline took to create.
+	Duration time.Duration `json:"duration"`
+}
+
+// updateHardwareResponse is what took effect when the hardware config was reloaded from the
+// store.
+type updateHardwareResponse struct {
+	Config hardware.Config `json:"config"`
+	Status HardwareStatus  `json:"status"`
+
+	// Warnings are parts of the active pipeline's config that the hardware can't apply.
+	Warnings []string `json:"warnings"`
+
+	// Duration is how long the hardware took to close and reopen.
+	Duration time.Duration `json:"duration"`
+}
+
 func (s *Server) updatePipeline(res http.ResponseWriter, req *http.Request) {
 	name := req.URL.Query().Get("name")
 
 	config, err := s.Store.PipelineConfig(name)
 	if err != nil {
-		respond(res, err, http.StatusInternalServerError)
+		respond(res, err, statusCode(err))
 		return
 	}
 
-	s.pipelineManager.SetConfig(config)
+	start := time.Now()
+	if err := s.pipelineManager.SetConfig(name, config); err != nil {
+		respond(res, err, http.StatusUnprocessableEntity)
+		return
+	}
+	took := time.Since(start)
 
-	respond(res, nil, http.StatusOK)
+	warnings := ledWarnings(config, s.hardwareManager.Status())
+	if config.Camera != nil {
+		var settable bool
+		err := s.withCapture(req.Context(), func(capture source.FrameSource) {
+			_, settable = capture.(source.Settable)
+		})
+		switch {
+		case err != nil:
+			warnings = append(warnings, fmt.Sprintf("unable to check camera settings: %s", err))
+		case !settable:
+			warnings = append(warnings, "camera settings weren't applied, since the capture doesn't support them")
+		}
+	}
+
+	respond(res, updatePipelineResponse{
+		Name:     name,
+		Config:   config,
+		Warnings: warnings,
+		Duration: took,
+	}, http.StatusOK)
 }
 
 func (s *Server) updateHardware(res http.ResponseWriter, req *http.Request) {
 	config, err := s.Store.HardwareConfig()
 	if err != nil {
-		respond(res, err, http.StatusInternalServerError)
+		respond(res, err, statusCode(err))
 		return
 	}
 
+	start := time.Now()
 	if err := s.hardwareManager.Update(config); err != nil {
-		respond(res, err, http.StatusInternalServerError)
+		respond(res, err, statusCode(err))
+		return
+	}
+	took := time.Since(start)
+
+	status := s.hardwareManager.Status()
+
+	var warnings []string
+	if active := s.pipelineManager.Pipeline(); active != nil {
+		warnings = ledWarnings(active.Config, status)
+	}
+
+	respond(res, updateHardwareResponse{
+		Config:   config,
+		Status:   status,
+		Warnings: warnings,
+		Duration: took,
+	}, http.StatusOK)
+}
+
+type suggestThresholdsRequest struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type suggestThresholdsResponse struct {
+	MinThresh pipeline.HSV `json:"minThresh"`
+	MaxThresh pipeline.HSV `json:"maxThresh"`
+}
+
+func (s *Server) suggestThresholds(res http.ResponseWriter, req *http.Request) {
+	var region suggestThresholdsRequest
+	if err := json.NewDecoder(req.Body).Decode(&region); err != nil {
+		respond(res, err, http.StatusBadRequest)
+		return
+	}
+
+	frame, err := s.grabFrame(req.Context())
+	if err != nil {
+		respond(res, err, http.StatusServiceUnavailable)
+		return
+	}
+	defer frame.Close()
+
+	rect := image.Rect(region.X, region.Y, region.X+region.Width, region.Y+region.Height)
+	min, max, err := pipeline.SuggestThresholds(frame, rect)
+	if err != nil {
+		respond(res, err, http.StatusUnprocessableEntity)
 		return
 	}
 
-	respond(res, nil, http.StatusOK)
+	respond(res, suggestThresholdsResponse{MinThresh: min, MaxThresh: max}, http.StatusOK)
 }
@@ -2,11 +2,38 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+
+	"github.com/gloworm-vision/gloworm-app/pipeline"
+	"github.com/gloworm-vision/gloworm-app/store"
 )
 
+// errorResponse is the body of every error response.
 type errorResponse struct {
 	Error string `json:"error"`
+
+	// Fields are the invalid fields, if the request was a config that failed validation.
+	Fields []pipeline.FieldError `json:"fields,omitempty"`
+}
+
+// statusCode returns the status code to respond to a request that failed with the error.
+func statusCode(err error) int {
+	var validation pipeline.ValidationError
+
+	switch {
+	case errors.As(err, &validation), errors.Is(err, store.ErrBackupVersion):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, store.ErrPipelineConfigNotExist), errors.Is(err, store.ErrHardwareConfigNotExist),
+		errors.Is(err, store.ErrSettingNotExist), errors.Is(err, store.ErrRevisionNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, store.ErrDefaultPipelineConfig), errors.Is(err, store.ErrPipelineConfigExists):
+		return http.StatusConflict
+	case errors.Is(err, errHardwareUnsupported):
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
 }
 
 // respond encodes the data and ResponseError to JSON and responds with it and
@@ -14,7 +41,12 @@ type errorResponse struct {
 func respond(w http.ResponseWriter, data interface{}, httpCode int) {
 	var resp interface{}
 	if v, ok := data.(error); ok {
-		resp = errorResponse{Error: v.Error()}
+		var validation pipeline.ValidationError
+		if errors.As(v, &validation) {
+			resp = errorResponse{Error: v.Error(), Fields: validation.Fields}
+		} else {
+			resp = errorResponse{Error: v.Error()}
+		}
 	} else {
 		resp = data
 	}
@@ -0,0 +1,255 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/gloworm-vision/gloworm-app/hardware"
+	"github.com/gloworm-vision/gloworm-app/pipeline"
+)
+
+// errHardwareUnsupported is returned when the hardware doesn't support an operation, such as
+// dimming lights that can only be turned on or off.
+var errHardwareUnsupported = errors.New("hardware doesn't support this")
+
+// Lights is the state of the hardware's LED cluster.
+type Lights struct {
+	On bool `json:"on"`
+
+	// Brightness, if set, dims the lights from off (0) to fully on (1). It requires hardware
+	// with dimmable lights.
+	Brightness *float64 `json:"brightness,omitempty"`
+}
+
+// HardwareCapabilities are what the connected hardware supports.
+type HardwareCapabilities struct {
+	BinaryLight      bool `json:"binaryLight"`
+	DimmableLight    bool `json:"dimmableLight"`
+	StatusIndicators bool `json:"statusIndicators"`
+}
+
+// HardwareStatus is the state of the connected hardware, served at /hardware/status.
+type HardwareStatus struct {
+	// Connected is false if no hardware is configured.
+	Connected    bool                 `json:"connected"`
+	Capabilities HardwareCapabilities `json:"capabilities"`
+
+	// Lights is the state the lights were last set to, if they've been set.
+	Lights *Lights `json:"lights,omitempty"`
+}
+
+// SetLights sets the hardware's LED cluster, stopping it blinking. Lights that can't be
+// dimmed can only be turned fully on or off.
+func (h *hardwareManager) SetLights(lights Lights) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.stopBlinking()
+
+	return h.setLights(lights)
+}
+
+// Blink blinks the hardware's LED cluster between off and the given lights, every period.
+// It blinks until the lights are set, the hardware is updated, or it's closed.
+func (h *hardwareManager) Blink(lights Lights, period time.Duration) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.stopBlinking()
+
+	lights.On = true
+	if err := h.setLights(lights); err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	h.stopBlink = func() { close(stop) }
+
+	go func() {
+		ticker := time.NewTicker(period / 2)
+		defer ticker.Stop()
+
+		on := true
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			h.mu.Lock()
+			select {
+			case <-stop:
+				// stopped while waiting for the lock
+				h.mu.Unlock()
+				return
+			default:
+			}
+
+			on = !on
+			next := lights
+			next.On = on
+			_ = h.setLights(next)
+			h.mu.Unlock()
+		}
+	}()
+
+	return nil
+}
+
+// stopBlinking stops the lights blinking, if they are. h.mu must be held.
+func (h *hardwareManager) stopBlinking() {
+	if h.stopBlink != nil {
+		h.stopBlink()
+		h.stopBlink = nil
+	}
+}
+
+// setLights sets the hardware's LED cluster. h.mu must be held.
+func (h *hardwareManager) setLights(lights Lights) error {
+	if h.hardware == nil {
+		return fmt.Errorf("no hardware: %w", errHardwareUnsupported)
+	}
+
+	dimmable, canDim := h.hardware.(hardware.DimmableLight)
+	binary, canToggle := h.hardware.(hardware.BinaryLight)
+
+	var err error
+	switch {
+	case lights.Brightness != nil || (canDim && !canToggle):
+		if !canDim {
+			return fmt.Errorf("lights can't be dimmed: %w", errHardwareUnsupported)
+		}
+
+		brightness := 1.0
+		if lights.Brightness != nil {
+			brightness = *lights.Brightness
+		}
+		if !lights.On {
+			brightness = 0
+		}
+
+		err = dimmable.SetLightBrightness(brightness)
+	case canToggle:
+		err = binary.SetLights(lights.On)
+	default:
+		return fmt.Errorf("no lights: %w", errHardwareUnsupported)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to set lights: %w", err)
+	}
+
+	h.lights = &lights
+
+	return nil
+}
+
+// applyLEDs sets the lights as configured by a pipeline that's becoming active.
+func (s *Server) applyLEDs(config pipeline.Config) {
+	var err error
+	switch {
+	case config.Type == pipeline.TypeDriver:
+		// driver pipelines are for humans, who don't want to be blinded
+		err = s.hardwareManager.SetLights(Lights{On: false})
+	case config.LEDs == nil:
+		return
+	case config.LEDs.Mode == pipeline.LEDOff:
+		err = s.hardwareManager.SetLights(Lights{On: false})
+	case config.LEDs.Mode == pipeline.LEDOn:
+		err = s.hardwareManager.SetLights(Lights{On: true})
+	case config.LEDs.Mode == pipeline.LEDBrightness:
+		brightness := config.LEDs.Brightness
+		err = s.hardwareManager.SetLights(Lights{On: true, Brightness: &brightness})
+	case config.LEDs.Mode == pipeline.LEDBlink:
+		period := time.Duration(config.LEDs.BlinkPeriodOrDefault() * float64(time.Second))
+		err = s.hardwareManager.Blink(Lights{On: true}, period)
+	}
+
+	switch {
+	case errors.Is(err, errHardwareUnsupported):
+		// most pipelines don't need lights, so missing lights aren't worth a warning
+		s.Logger.Debugf("unable to set pipeline lights: %s", err)
+	case err != nil:
+		s.Logger.Warnf("unable to set pipeline lights: %s", err)
+	}
+}
+
+// ledsChanged returns whether applyLEDs sets the lights differently for the next pipeline
+// config than the last, so tuning other fields of the active pipeline doesn't restart blinking.
+func ledsChanged(last, next pipeline.Config) bool {
+	return (last.Type == pipeline.TypeDriver) != (next.Type == pipeline.TypeDriver) || !reflect.DeepEqual(last.LEDs, next.LEDs)
+}
+
+// ledWarnings returns warnings for the lights the pipeline config sets that the hardware
+// can't show.
+func ledWarnings(config pipeline.Config, status HardwareStatus) []string {
+	if config.Type == pipeline.TypeDriver || config.LEDs == nil || config.LEDs.Mode == pipeline.LEDOff {
+		return nil
+	}
+
+	caps := status.Capabilities
+	switch {
+	case !status.Connected:
+		return []string{"lights weren't set, since no hardware is connected"}
+	case !caps.BinaryLight && !caps.DimmableLight:
+		return []string{"lights weren't set, since the hardware doesn't have any"}
+	case config.LEDs.Mode == pipeline.LEDBrightness && !caps.DimmableLight:
+		return []string{"lights weren't set, since the hardware lacks DimmableLight"}
+	}
+
+	return nil
+}
+
+// Status returns the state of the hardware.
+func (h *hardwareManager) Status() HardwareStatus {
+	var status HardwareStatus
+	h.View(func(hw hardware.Hardware) {
+		if hw == nil {
+			return
+		}
+
+		_, binary := hw.(hardware.BinaryLight)
+		_, dimmable := hw.(hardware.DimmableLight)
+		_, indicators := hw.(hardware.StatusIndicators)
+
+		status = HardwareStatus{
+			Connected: true,
+			Capabilities: HardwareCapabilities{
+				BinaryLight:      binary,
+				DimmableLight:    dimmable,
+				StatusIndicators: indicators,
+			},
+			Lights: h.lights,
+		}
+	})
+
+	return status
+}
+
+func (s *Server) getHardwareStatus(res http.ResponseWriter, req *http.Request) {
+	respond(res, s.hardwareManager.Status(), http.StatusOK)
+}
+
+func (s *Server) putLights(res http.ResponseWriter, req *http.Request) {
+	var lights Lights
+	if err := json.NewDecoder(req.Body).Decode(&lights); err != nil {
+		respond(res, err, http.StatusBadRequest)
+		return
+	}
+
+	if b := lights.Brightness; b != nil && (*b < 0 || *b > 1) {
+		respond(res, errors.New("brightness must be from 0 to 1"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err := s.hardwareManager.SetLights(lights); err != nil {
+		respond(res, err, statusCode(err))
+		return
+	}
+
+	respond(res, nil, http.StatusNoContent)
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/gloworm-vision/gloworm-app/networktables"
+	"github.com/gloworm-vision/gloworm-app/pipeline"
+)
+
+// limelightTable is the networktables table Limelight cameras publish results to.
+const limelightTable = "/limelight"
+
+// limelightEntries are the Limelight networktables entries the server publishes to when
+// NTLimelight is enabled, relative to limelightTable. Limelight publishes booleans and
+// indexes as doubles, so these do too.
+var limelightEntries = []networktables.Entry{
+	{Name: "/tv", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/tx", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/ty", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/ta", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/ts", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/tl", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/tshort", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/tlong", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/thor", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/tvert", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/getpipe", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/tcornxy", Value: networktables.EntryValue{EntryType: networktables.DoubleArray, DoubleArray: []float64{}}},
+}
+
+// createLimelightEntries creates the Limelight networktables entries.
+func (s *Server) createLimelightEntries() error {
+	for _, entry := range limelightEntries {
+		entry.Name = limelightTable + entry.Name
+		if err := s.NT.Create(entry); err != nil {
+			return fmt.Errorf("unable to create networktables entry %q: %w", entry.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// publishLimelight updates the Limelight networktables entries with the best target of the
+// result, and the index of the active pipeline. Area is published as a percentage of the
+// frame, and latency in milliseconds.
+func (s *Server) publishLimelight(result pipeline.Result, pipelineIndex int) {
+	var target pipeline.Target
+	var tv float64
+	if len(result.Targets) > 0 {
+		target, tv = result.Targets[0], 1
+	}
+
+	rect := target.RotatedRect
+	short, long := math.Min(float64(rect.Width), float64(rect.Height)), math.Max(float64(rect.Width), float64(rect.Height))
+
+	var corners []float64
+	for _, corner := range target.Corners {
+		corners = append(corners, float64(corner.X), float64(corner.Y))
+	}
+
+	values := map[string]networktables.EntryValue{
+		"/tv":      {EntryType: networktables.Double, Double: tv},
+		"/tx":      {EntryType: networktables.Double, Double: target.Yaw},
+		"/ty":      {EntryType: networktables.Double, Double: target.Pitch},
+		"/ta":      {EntryType: networktables.Double, Double: target.Area * 100},
+		"/ts":      {EntryType: networktables.Double, Double: rect.Angle},
+		"/tl":      {EntryType: networktables.Double, Double: milliseconds(result.Latency)},
+		"/tshort":  {EntryType: networktables.Double, Double: short},
+		"/tlong":   {EntryType: networktables.Double, Double: long},
+		"/thor":    {EntryType: networktables.Double, Double: float64(target.BoundingBox.Dx())},
+		"/tvert":   {EntryType: networktables.Double, Double: float64(target.BoundingBox.Dy())},
+		"/getpipe": {EntryType: networktables.Double, Double: float64(pipelineIndex)},
+		"/tcornxy": doubleArray(corners),
+	}
+
+	for name, value := range values {
+		name = limelightTable + name
+		if err := s.NT.UpdateValue(name, value); err != nil {
+			s.Logger.WithField("entry", name).Debugf("unable to update networktables entry: %s", err)
+		}
+	}
+}
+
+// pipelineIndex returns the index of the named pipeline config in the sorted list of
+// pipeline configs, or -1 if it can't be found.
+func (s *Server) pipelineIndex(name string) int {
+	names, err := s.pipelineNames()
+	if err != nil {
+		s.Logger.Warnf("unable to list pipeline configs: %s", err)
+		return -1
+	}
+
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+
+	return -1
+}
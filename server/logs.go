@@ -0,0 +1,193 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// logBufferSize is the number of recent log entries kept in memory.
+	logBufferSize = 500
+
+	// logSubscriberBuffer is the number of log entries buffered for each streaming client.
+	logSubscriberBuffer = 64
+)
+
+// logEntry is a log entry served at /logs.
+type logEntry struct {
+	Time    time.Time              `json:"time"`
+	Level   logrus.Level           `json:"level"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+// logBuffer is a logrus hook that keeps the most recent log entries in a ring buffer, and
+// sends new entries to subscribers.
+type logBuffer struct {
+	mu      sync.Mutex
+	entries []logEntry
+	next    int
+	full    bool
+
+	subscribers map[chan logEntry]struct{}
+}
+
+func newLogBuffer(size int) *logBuffer {
+	return &logBuffer{entries: make([]logEntry, size)}
+}
+
+func (b *logBuffer) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (b *logBuffer) Fire(e *logrus.Entry) error {
+	entry := logEntry{Time: e.Time, Level: e.Level, Message: e.Message}
+	if len(e.Data) > 0 {
+		entry.Fields = make(map[string]interface{}, len(e.Data))
+		for k, v := range e.Data {
+			// errors don't encode to JSON usefully
+			if err, ok := v.(error); ok {
+				v = err.Error()
+			}
+			entry.Fields[k] = v
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.entries[b.next] = entry
+	b.next = (b.next + 1) % len(b.entries)
+	b.full = b.full || b.next == 0
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- entry:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// recent returns the buffered entries at the level or more severe, oldest first.
+func (b *logBuffer) recent(level logrus.Level) []logEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ordered := b.entries[:b.next]
+	if b.full {
+		ordered = append(append([]logEntry(nil), b.entries[b.next:]...), b.entries[:b.next]...)
+	}
+
+	entries := make([]logEntry, 0, len(ordered))
+	for _, entry := range ordered {
+		if entry.Level <= level {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// subscribe returns a channel that receives new entries, and a function that unsubscribes it.
+func (b *logBuffer) subscribe() (<-chan logEntry, func()) {
+	ch := make(chan logEntry, logSubscriberBuffer)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscribers == nil {
+		b.subscribers = make(map[chan logEntry]struct{})
+	}
+	b.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.subscribers, ch)
+	}
+}
+
+// getLogs serves the recent log entries at the level query parameter or more severe (by
+// default, every level). If the stream query parameter is true, the entries are followed by
+// new entries as server-sent events.
+func (s *Server) getLogs(res http.ResponseWriter, req *http.Request) {
+	level := logrus.TraceLevel
+	if raw := req.URL.Query().Get("level"); raw != "" {
+		var err error
+		if level, err = logrus.ParseLevel(raw); err != nil {
+			respond(res, fmt.Errorf("invalid level %q", raw), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var stream bool
+	if raw := req.URL.Query().Get("stream"); raw != "" {
+		var err error
+		if stream, err = strconv.ParseBool(raw); err != nil {
+			respond(res, fmt.Errorf("invalid stream %q", raw), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if !stream {
+		respond(res, s.logs.recent(level), http.StatusOK)
+		return
+	}
+
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		respond(res, errors.New("streaming isn't supported"), http.StatusInternalServerError)
+		return
+	}
+
+	// subscribe before reading the recent entries, so none are missed in between
+	entries, unsubscribe := s.logs.subscribe()
+	defer unsubscribe()
+
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.WriteHeader(http.StatusOK)
+
+	send := func(entry logEntry) error {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(res, "event: log\ndata: %s\n\n", data)
+		return err
+	}
+
+	for _, entry := range s.logs.recent(level) {
+		if err := send(entry); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case entry := <-entries:
+			if entry.Level > level {
+				continue
+			}
+
+			if err := send(entry); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
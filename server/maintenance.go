@@ -0,0 +1,57 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gloworm-vision/gloworm-app/store"
+)
+
+// checkStoreResponse is the result of checking the store's integrity.
+type checkStoreResponse struct {
+	// Problem describes how the store is corrupt. It's empty if it isn't.
+	Problem string `json:"problem,omitempty"`
+
+	// Duration is how long the store took to check.
+	Duration time.Duration `json:"duration"`
+}
+
+// compactStoreResponse is the result of compacting the store.
+type compactStoreResponse struct {
+	// Duration is how long the store took to compact, during which nothing else could use it.
+	Duration time.Duration `json:"duration"`
+}
+
+// checkStore checks the store's integrity, such as after the device was powered off while it
+// was being written. A corrupt store is reported in the response, rather than as an error.
+func (s *Server) checkStore(res http.ResponseWriter, req *http.Request) {
+	start := time.Now()
+	err := s.Store.Check()
+	took := time.Since(start)
+
+	if err != nil && !errors.Is(err, store.ErrCorrupt) {
+		respond(res, err, statusCode(err))
+		return
+	}
+
+	response := checkStoreResponse{Duration: took}
+	if err != nil {
+		s.requestLogger(req).Warnf("store is corrupt: %s", err)
+		response.Problem = err.Error()
+	}
+
+	respond(res, response, http.StatusOK)
+}
+
+// compactStore reclaims space the store no longer uses. It blocks every other use of the
+// store until it's done, so it's meant for maintenance between events rather than matches.
+func (s *Server) compactStore(res http.ResponseWriter, req *http.Request) {
+	start := time.Now()
+	if err := s.Store.Compact(); err != nil {
+		respond(res, err, statusCode(err))
+		return
+	}
+
+	respond(res, compactStoreResponse{Duration: time.Since(start)}, http.StatusOK)
+}
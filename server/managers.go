@@ -11,14 +11,54 @@ import (
 // pipelineManager synchronizes access to the underlying pipeline.
 type pipelineManager struct {
 	pipeline *pipeline.Pipeline
+	name     string
 	mu       *sync.RWMutex
+
+	// switched, if set, is called with the new name when the pipeline switches to a
+	// different config.
+	switched func(name string)
+}
+
+// SetConfig replaces the pipeline with a new pipeline created from the named config.
+func (p *pipelineManager) SetConfig(name string, config pipeline.Config) error {
+	pipeline, err := pipeline.New(config)
+	if err != nil {
+		return fmt.Errorf("unable to create new pipeline from config: %w", err)
+	}
+
+	p.mu.Lock()
+	p.pipeline = pipeline
+	switched := p.name != name
+	p.name = name
+	p.mu.Unlock()
+
+	if switched && p.switched != nil {
+		p.switched(name)
+	}
+
+	return nil
+}
+
+// Name returns the name of the pipeline's config.
+func (p *pipelineManager) Name() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.name
 }
 
-func (p *pipelineManager) SetConfig(config pipeline.Config) {
+// Rename updates the name of the pipeline's config if it was renamed.
+func (p *pipelineManager) Rename(from, to string) {
 	p.mu.Lock()
-	defer p.mu.Unlock()
+	renamed := p.name == from
+	if renamed {
+		p.name = to
+	}
+	p.mu.Unlock()
 
-	p.pipeline = &pipeline.Pipeline{Config: config}
+	if renamed && p.switched != nil {
+		p.switched(to)
+	}
 }
 
 func (p *pipelineManager) Pipeline() *pipeline.Pipeline {
@@ -34,13 +74,32 @@ func (p *pipelineManager) Pipeline() *pipeline.Pipeline {
 type hardwareManager struct {
 	hardware hardware.Hardware
 	mu       *sync.RWMutex
+
+	// statuses caches the statuses last set on the hardware, so they're only written
+	// when they change.
+	statuses map[hardware.Status]bool
+
+	// lights is the state the lights were last set to, if they've been set.
+	lights *Lights
+
+	// stopBlink stops the lights blinking, if they are.
+	stopBlink func()
 }
 
 func (h *hardwareManager) Update(config hardware.Config) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	h.hardware.Close()
+	h.stopBlinking()
+
+	if h.hardware != nil {
+		if err := h.hardware.Close(); err != nil {
+			return fmt.Errorf("unable to close hardware: %w", err)
+		}
+	}
+
+	h.statuses = nil
+	h.lights = nil
 
 	var err error
 	h.hardware, err = hardware.New(config)
@@ -51,9 +110,63 @@ func (h *hardwareManager) Update(config hardware.Config) error {
 	return nil
 }
 
+// Close turns off the lights, if the hardware has them, and closes the hardware.
+func (h *hardwareManager) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.stopBlinking()
+
+	if h.hardware == nil {
+		return nil
+	}
+
+	if lights, ok := h.hardware.(hardware.BinaryLight); ok {
+		if err := lights.SetLights(false); err != nil {
+			return fmt.Errorf("unable to turn off lights: %w", err)
+		}
+	}
+
+	if err := h.hardware.Close(); err != nil {
+		return fmt.Errorf("unable to close hardware: %w", err)
+	}
+
+	h.hardware = nil
+	h.lights = nil
+
+	return nil
+}
+
 func (h *hardwareManager) View(fn func(h hardware.Hardware)) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
 	fn(h.hardware)
 }
+
+// SetStatus sets a status on the hardware if it has status indicators. The status is only
+// written to the hardware when it changes.
+func (h *hardwareManager) SetStatus(status hardware.Status, value bool) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	indicators, ok := h.hardware.(hardware.StatusIndicators)
+	if !ok {
+		return nil
+	}
+
+	if last, ok := h.statuses[status]; ok && last == value {
+		return nil
+	}
+
+	if err := indicators.SetStatus(status, value); err != nil {
+		return fmt.Errorf("unable to set status: %w", err)
+	}
+
+	if h.statuses == nil {
+		h.statuses = make(map[hardware.Status]bool)
+	}
+	h.statuses[status] = value
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gloworm-vision/gloworm-app/networktables"
+	"github.com/gloworm-vision/gloworm-app/store"
+)
+
+const (
+	// matchPollInterval is how often the match phase is checked, which is frequent enough
+	// that the auto pipeline is active within the first few frames of autonomous.
+	matchPollInterval = 50 * time.Millisecond
+
+	// defaultEndgameStart is how long into teleop the endgame starts by default.
+	defaultEndgameStart = 105 * time.Second
+)
+
+// MatchPhase is the phase of the match the robot is in, read from the FMS control data.
+type MatchPhase string
+
+const (
+	// MatchUnknown is before the control data has been read, or while the robot is in test
+	// mode.
+	MatchUnknown  MatchPhase = ""
+	MatchDisabled MatchPhase = "disabled"
+	MatchAuto     MatchPhase = "auto"
+	MatchTeleop   MatchPhase = "teleop"
+	MatchEndgame  MatchPhase = "endgame"
+)
+
+// MatchStatus is the current match phase, served at /match.
+type MatchStatus struct {
+	Phase MatchPhase `json:"phase"`
+	Since time.Time  `json:"since"`
+
+	// FMSAttached is whether the robot is connected to the FMS, rather than practicing.
+	FMSAttached bool `json:"fmsAttached"`
+}
+
+// matchMonitor synchronizes access to the match status.
+type matchMonitor struct {
+	mu     sync.RWMutex
+	status MatchStatus
+}
+
+func (m *matchMonitor) set(status MatchStatus) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.status = status
+}
+
+func (m *matchMonitor) Status() MatchStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.status
+}
+
+// schedule returns the pipeline config the settings switch to in the phase, if any.
+func schedule(settings store.MatchSettings, phase MatchPhase) string {
+	switch phase {
+	case MatchDisabled:
+		return settings.Disabled
+	case MatchAuto:
+		return settings.Auto
+	case MatchTeleop:
+		return settings.Teleop
+	case MatchEndgame:
+		return settings.Endgame
+	}
+
+	return ""
+}
+
+// validateMatchSettings returns an error if a pipeline config the match settings switch to
+// doesn't exist.
+func (s *Server) validateMatchSettings(settings store.MatchSettings) error {
+	for _, name := range []string{settings.Disabled, settings.Auto, settings.Teleop, settings.Endgame} {
+		if name == "" {
+			continue
+		}
+
+		if _, err := s.Store.PipelineConfig(name); err != nil {
+			return fmt.Errorf("unable to switch to pipeline %q during matches: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// followMatch tracks the match phase from the FMS control data robot code publishes, and
+// switches the active pipeline when a phase starts if the match settings name one for it,
+// until ctx is done. Pipelines are only switched when a phase starts, so they can still be
+// switched by hand or by robot code during the phase.
+func (s *Server) followMatch(ctx context.Context) {
+	ticker := time.NewTicker(matchPollInterval)
+	defer ticker.Stop()
+
+	var status MatchStatus
+	var teleopStart time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		entry, err := s.NT.Get(fmsControlData)
+		if err != nil || entry.Value.EntryType != networktables.Double {
+			continue
+		}
+
+		settings := s.settingsManager.Settings().Match
+		endgameStart := defaultEndgameStart
+		if settings.EndgameStart > 0 {
+			endgameStart = time.Duration(settings.EndgameStart * float64(time.Second))
+		}
+
+		control, now := int(entry.Value.Double), time.Now()
+
+		phase := MatchDisabled
+		switch {
+		case control&fmsEnabled == 0:
+		case control&fmsTest != 0:
+			phase = MatchUnknown
+		case control&fmsAuto != 0:
+			phase = MatchAuto
+		default:
+			if status.Phase != MatchTeleop && status.Phase != MatchEndgame {
+				teleopStart = now
+			}
+
+			phase = MatchTeleop
+			if now.Sub(teleopStart) >= endgameStart {
+				phase = MatchEndgame
+			}
+		}
+
+		attached := control&fmsAttached != 0
+		if phase == status.Phase && attached == status.FMSAttached {
+			continue
+		}
+
+		// the phase started when it changed, not when the FMS was attached or detached
+		started := phase != status.Phase
+		if started {
+			status.Phase, status.Since = phase, now
+		}
+		status.FMSAttached = attached
+		s.matchMonitor.set(status)
+
+		name := schedule(settings, phase)
+		if !started || name == "" {
+			continue
+		}
+
+		switched, err := s.activatePipeline(name)
+		if err != nil {
+			s.Logger.WithField("phase", phase).Warnf("unable to switch pipeline for match phase: %s", err)
+			continue
+		}
+		if switched {
+			s.Logger.WithField("phase", phase).WithField("pipeline", name).Info("switched pipeline for match phase")
+		}
+	}
+}
+
+func (s *Server) getMatch(res http.ResponseWriter, req *http.Request) {
+	respond(res, s.matchMonitor.Status(), http.StatusOK)
+}
@@ -0,0 +1,211 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gloworm-vision/gloworm-app/network"
+)
+
+// networkConfirmTimeout is how long an applied network config has to be confirmed before
+// it's rolled back, so a config that makes the device unreachable undoes itself.
+const networkConfirmTimeout = 2 * time.Minute
+
+// networkApplyTimeout is how long applying a network config can take. It isn't tied to the
+// request, since the client's connection can drop as the address changes, which would leave
+// the config partly applied.
+const networkApplyTimeout = 30 * time.Second
+
+var (
+	// errNetworkUnmanaged is returned when the server isn't managing the device's network.
+	errNetworkUnmanaged = errors.New("network configuration isn't enabled")
+
+	// errNoPendingNetwork is returned when confirming a network config that isn't pending.
+	errNoPendingNetwork = errors.New("no network config waiting to be confirmed")
+)
+
+// NetworkResponse is the device's network config and status, served at /network.
+type NetworkResponse struct {
+	Config network.Config `json:"config"`
+
+	// Status is omitted if the interface's state can't be read.
+	Status *network.Status `json:"status,omitempty"`
+
+	// Pending is set while an applied config is waiting to be confirmed.
+	Pending *PendingNetwork `json:"pending,omitempty"`
+}
+
+// PendingNetwork is an applied network config waiting to be confirmed. If it isn't
+// confirmed by Expires, the previous config is reapplied.
+type PendingNetwork struct {
+	Previous network.Config `json:"previous"`
+	Expires  time.Time      `json:"expires"`
+}
+
+// networkConfirmer rolls back applied network configs that aren't confirmed in time.
+type networkConfirmer struct {
+	mu      sync.Mutex
+	pending *PendingNetwork
+	timer   *time.Timer
+}
+
+// Pending returns the config waiting to be confirmed, if there is one.
+func (c *networkConfirmer) Pending() *PendingNetwork {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pending == nil {
+		return nil
+	}
+
+	pending := *c.pending
+	return &pending
+}
+
+// Start waits for the applied config to be confirmed, calling rollback with the previous config
+// if it isn't. If a config is already waiting, its previous config is kept, since that's the
+// last one known to work.
+func (c *networkConfirmer) Start(previous network.Config, rollback func(previous network.Config)) PendingNetwork {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pending != nil {
+		c.timer.Stop()
+		previous = c.pending.Previous
+	}
+
+	c.pending = &PendingNetwork{Previous: previous, Expires: time.Now().Add(networkConfirmTimeout)}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(networkConfirmTimeout, func() {
+		c.mu.Lock()
+		if c.timer != timer {
+			// confirmed, or replaced by another config, while waiting for the lock
+			c.mu.Unlock()
+			return
+		}
+		c.pending, c.timer = nil, nil
+		c.mu.Unlock()
+
+		rollback(previous)
+	})
+	c.timer = timer
+
+	return *c.pending
+}
+
+// Confirm keeps the applied config.
+func (c *networkConfirmer) Confirm() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pending == nil {
+		return errNoPendingNetwork
+	}
+
+	c.timer.Stop()
+	c.pending, c.timer = nil, nil
+
+	return nil
+}
+
+// getNetwork responds with the config and status of the interface in the interface query
+// parameter, or the default interface.
+func (s *Server) getNetwork(res http.ResponseWriter, req *http.Request) {
+	if s.Network == nil {
+		respond(res, errNetworkUnmanaged, http.StatusNotImplemented)
+		return
+	}
+
+	iface := req.URL.Query().Get("interface")
+	config, err := s.Network.Config(iface)
+	if err != nil {
+		respond(res, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := NetworkResponse{Config: config, Pending: s.networkConfirmer.Pending()}
+	if status, err := s.Network.Status(config.Interface); err == nil {
+		resp.Status = &status
+	}
+
+	respond(res, resp, http.StatusOK)
+}
+
+// putNetwork applies the network config in the body, merged into the current config of the
+// interface in the interface query parameter, or the default interface. It must be confirmed
+// within networkConfirmTimeout at /network/confirm, or the previous config is reapplied.
+func (s *Server) putNetwork(res http.ResponseWriter, req *http.Request) {
+	if s.Network == nil {
+		respond(res, errNetworkUnmanaged, http.StatusNotImplemented)
+		return
+	}
+
+	previous, err := s.Network.Config(req.URL.Query().Get("interface"))
+	if err != nil {
+		respond(res, err, http.StatusInternalServerError)
+		return
+	}
+
+	config := previous
+	if err := json.NewDecoder(req.Body).Decode(&config); err != nil {
+		respond(res, err, http.StatusBadRequest)
+		return
+	}
+
+	// the previous config, which is rolled back to, is only of the interface in the query
+	if config.Interface != previous.Interface {
+		respond(res, fmt.Errorf("interface %q doesn't match the interface being configured, %q", config.Interface, previous.Interface), http.StatusBadRequest)
+		return
+	}
+
+	if err := config.Validate(); err != nil {
+		respond(res, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), networkApplyTimeout)
+	defer cancel()
+
+	if err := s.Network.Apply(ctx, config); err != nil {
+		respond(res, err, http.StatusInternalServerError)
+		return
+	}
+
+	pending := s.networkConfirmer.Start(previous, func(previous network.Config) {
+		s.Logger.Warn("network config wasn't confirmed, rolling back")
+
+		ctx, cancel := context.WithTimeout(context.Background(), networkApplyTimeout)
+		defer cancel()
+
+		if err := s.Network.Apply(ctx, previous); err != nil {
+			s.Logger.Errorf("unable to roll back network config: %s", err)
+		}
+	})
+
+	s.requestLogger(req).WithField("interface", config.Interface).Info("applied network config, waiting for confirmation")
+
+	respond(res, NetworkResponse{Config: config, Pending: &pending}, http.StatusAccepted)
+}
+
+// confirmNetwork keeps the applied network config.
+func (s *Server) confirmNetwork(res http.ResponseWriter, req *http.Request) {
+	if s.Network == nil {
+		respond(res, errNetworkUnmanaged, http.StatusNotImplemented)
+		return
+	}
+
+	if err := s.networkConfirmer.Confirm(); err != nil {
+		respond(res, err, http.StatusConflict)
+		return
+	}
+
+	s.requestLogger(req).Info("network config confirmed")
+
+	respond(res, nil, http.StatusNoContent)
+}
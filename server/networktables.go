@@ -0,0 +1,140 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gloworm-vision/gloworm-app/networktables"
+	"github.com/gloworm-vision/gloworm-app/pipeline"
+)
+
+// ntTable is the networktables table the server publishes results to. Additional cameras
+// publish to subtables of it. See cameraTable.
+const ntTable = "/gloworm"
+
+// ntEntries are the networktables entries the server publishes results to, relative to
+// the table they're published in.
+var ntEntries = []networktables.Entry{
+	{Name: "/tv", Value: networktables.EntryValue{EntryType: networktables.Boolean}},
+	{Name: "/stale", Value: networktables.EntryValue{EntryType: networktables.Boolean}},
+	{Name: "/x", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/y", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/tx", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/ty", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/distance", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/corners", Value: networktables.EntryValue{EntryType: networktables.DoubleArray, DoubleArray: []float64{}}},
+	{Name: "/pose", Value: networktables.EntryValue{EntryType: networktables.DoubleArray, DoubleArray: []float64{}}},
+	{Name: "/latency", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/averageLatency", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/sequence", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/captureTime", Value: networktables.EntryValue{EntryType: networktables.Double}},
+
+	{Name: "/targets/id", Value: networktables.EntryValue{EntryType: networktables.DoubleArray, DoubleArray: []float64{}}},
+	{Name: "/targets/x", Value: networktables.EntryValue{EntryType: networktables.DoubleArray, DoubleArray: []float64{}}},
+	{Name: "/targets/y", Value: networktables.EntryValue{EntryType: networktables.DoubleArray, DoubleArray: []float64{}}},
+	{Name: "/targets/tx", Value: networktables.EntryValue{EntryType: networktables.DoubleArray, DoubleArray: []float64{}}},
+	{Name: "/targets/ty", Value: networktables.EntryValue{EntryType: networktables.DoubleArray, DoubleArray: []float64{}}},
+	{Name: "/targets/distance", Value: networktables.EntryValue{EntryType: networktables.DoubleArray, DoubleArray: []float64{}}},
+	{Name: "/targets/area", Value: networktables.EntryValue{EntryType: networktables.DoubleArray, DoubleArray: []float64{}}},
+	{Name: "/targets/confidence", Value: networktables.EntryValue{EntryType: networktables.DoubleArray, DoubleArray: []float64{}}},
+	{Name: "/targets/boundingBox", Value: networktables.EntryValue{EntryType: networktables.DoubleArray, DoubleArray: []float64{}}},
+}
+
+// createEntries creates all of the networktables entries the server publishes to in the table.
+func (s *Server) createEntries(table string) error {
+	for _, entry := range ntEntries {
+		entry.Name = table + entry.Name
+		if err := s.NT.Create(entry); err != nil {
+			return fmt.Errorf("unable to create networktables entry %q: %w", entry.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// publishResult updates the networktables entries in the table with the given result. The
+// first (best) target is published on its own, and every target is published as parallel
+// arrays under targets. Latencies and the capture time are published in milliseconds. Failing
+// to update an entry isn't fatal, so errors are only logged.
+func (s *Server) publishResult(table string, result pipeline.Result) {
+	targets := result.Targets
+
+	var target pipeline.Target
+	if len(targets) > 0 {
+		target = targets[0]
+	}
+
+	var corners []float64
+	for _, corner := range target.Corners {
+		corners = append(corners, float64(corner.X), float64(corner.Y))
+	}
+
+	var pose []float64
+	if target.Pose != nil {
+		pose = append(pose, target.Pose.Translation[:]...)
+		pose = append(pose, target.Pose.Rotation[:]...)
+	}
+
+	var ids, xs, ys, txs, tys, distances, areas, confidences, boxes []float64
+	for _, t := range targets {
+		ids = append(ids, float64(t.ID))
+		xs = append(xs, float64(t.Centroid.X))
+		ys = append(ys, float64(t.Centroid.Y))
+		txs = append(txs, t.Yaw)
+		tys = append(tys, t.Pitch)
+		distances = append(distances, t.Distance)
+		areas = append(areas, t.Area)
+		confidences = append(confidences, t.Confidence)
+		boxes = append(boxes,
+			float64(t.BoundingBox.Min.X), float64(t.BoundingBox.Min.Y),
+			float64(t.BoundingBox.Dx()), float64(t.BoundingBox.Dy()))
+	}
+
+	values := map[string]networktables.EntryValue{
+		"/tv":    {EntryType: networktables.Boolean, Boolean: len(targets) > 0},
+		"/stale": {EntryType: networktables.Boolean, Boolean: target.Stale},
+
+		"/x":        {EntryType: networktables.Double, Double: float64(target.Centroid.X)},
+		"/y":        {EntryType: networktables.Double, Double: float64(target.Centroid.Y)},
+		"/tx":       {EntryType: networktables.Double, Double: target.Yaw},
+		"/ty":       {EntryType: networktables.Double, Double: target.Pitch},
+		"/distance": {EntryType: networktables.Double, Double: target.Distance},
+		"/corners":  doubleArray(corners),
+		"/pose":     doubleArray(pose),
+
+		"/latency":        {EntryType: networktables.Double, Double: milliseconds(result.Latency)},
+		"/averageLatency": {EntryType: networktables.Double, Double: milliseconds(result.AverageLatency)},
+		"/sequence":       {EntryType: networktables.Double, Double: float64(result.Sequence)},
+		"/captureTime":    {EntryType: networktables.Double, Double: milliseconds(result.CaptureTime)},
+
+		"/targets/id":          doubleArray(ids),
+		"/targets/x":           doubleArray(xs),
+		"/targets/y":           doubleArray(ys),
+		"/targets/tx":          doubleArray(txs),
+		"/targets/ty":          doubleArray(tys),
+		"/targets/distance":    doubleArray(distances),
+		"/targets/area":        doubleArray(areas),
+		"/targets/confidence":  doubleArray(confidences),
+		"/targets/boundingBox": doubleArray(boxes),
+	}
+
+	for name, value := range values {
+		name = table + name
+		if err := s.NT.UpdateValue(name, value); err != nil {
+			s.Logger.WithField("entry", name).Debugf("unable to update networktables entry: %s", err)
+		}
+	}
+}
+
+// doubleArray creates a double array entry value, ensuring the array is never nil.
+func doubleArray(values []float64) networktables.EntryValue {
+	if values == nil {
+		values = []float64{}
+	}
+
+	return networktables.EntryValue{EntryType: networktables.DoubleArray, DoubleArray: values}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gloworm-vision/gloworm-app/networktables"
+)
+
+// pipelineEntryPollInterval is how often the entries robot code switches pipelines with are
+// checked.
+const pipelineEntryPollInterval = 50 * time.Millisecond
+
+// limelightPipelineEntry is the entry Limelight robot code writes the index of the pipeline
+// it wants to.
+const limelightPipelineEntry = limelightTable + "/pipeline"
+
+// switchPipelines switches the active pipeline whenever robot code writes a new value to
+// NTPipelineEntry, or the Limelight pipeline entry if NTLimelight is enabled, until ctx is
+// done. Entries are only read when they change, so pipelines switched over HTTP stay active
+// until the robot asks for a different one.
+func (s *Server) switchPipelines(ctx context.Context) {
+	var entries []string
+	if s.NTPipelineEntry != "" {
+		entries = append(entries, s.NTPipelineEntry)
+	}
+	if s.NTLimelight {
+		entries = append(entries, limelightPipelineEntry)
+	}
+
+	ticker := time.NewTicker(pipelineEntryPollInterval)
+	defer ticker.Stop()
+
+	last := make(map[string]string)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, name := range entries {
+			entry, err := s.NT.Get(name)
+			if err != nil {
+				continue
+			}
+
+			value := entryString(entry.Value)
+			if seen, ok := last[name]; ok && seen == value {
+				continue
+			}
+			last[name] = value
+
+			if err := s.switchPipeline(entry.Value); err != nil {
+				s.Logger.WithField("entry", name).Warnf("unable to switch pipeline: %s", err)
+			}
+		}
+	}
+}
+
+// entryString returns the value of a string or double entry as a string, which is used to
+// tell when it changes.
+func entryString(value networktables.EntryValue) string {
+	if value.EntryType == networktables.String {
+		return value.String
+	}
+
+	return fmt.Sprint(value.Double)
+}
+
+// switchPipeline switches the active pipeline to the one named by a string entry, or at the
+// index in the sorted list of pipeline configs given by a double entry.
+func (s *Server) switchPipeline(value networktables.EntryValue) error {
+	var name string
+	switch value.EntryType {
+	case networktables.String:
+		name = value.String
+	case networktables.Double:
+		names, err := s.pipelineNames()
+		if err != nil {
+			return fmt.Errorf("unable to list pipeline configs: %w", err)
+		}
+
+		index := int(value.Double)
+		if index < 0 || index >= len(names) {
+			return fmt.Errorf("no pipeline config at index %d", index)
+		}
+
+		name = names[index]
+	default:
+		return errors.New("entry must be a string or double")
+	}
+
+	switched, err := s.activatePipeline(name)
+	if err != nil {
+		return err
+	}
+
+	if switched {
+		s.Logger.WithField("pipeline", name).Info("switched pipeline from networktables")
+	}
+	return nil
+}
+
+// activatePipeline switches the active pipeline to the named config, and returns whether it
+// switched. It doesn't if the config is already active.
+func (s *Server) activatePipeline(name string) (bool, error) {
+	if name == s.pipelineManager.Name() {
+		return false, nil
+	}
+
+	config, err := s.Store.PipelineConfig(name)
+	if err != nil {
+		return false, err
+	}
+
+	if err := s.pipelineManager.SetConfig(name, config); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
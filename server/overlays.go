@@ -0,0 +1,62 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/gloworm-vision/gloworm-app/pipeline"
+)
+
+// Overlays are the parts of the overlay drawn onto the main stream. They're toggled at
+// runtime rather than saved in pipeline configs, and reset when the server restarts.
+type Overlays struct {
+	pipeline.Layers
+
+	// FPS is the capture, processed and stream frame rates.
+	FPS bool `json:"fps"`
+}
+
+// defaultOverlays returns the overlays drawn until they're toggled, which are everything the
+// pipeline config draws, and the frame rates if ShowFPS is set.
+func (s *Server) defaultOverlays() Overlays {
+	return Overlays{Layers: pipeline.AllLayers, FPS: s.ShowFPS}
+}
+
+// overlayManager synchronizes access to the overlays.
+type overlayManager struct {
+	mu       sync.RWMutex
+	overlays Overlays
+}
+
+func (m *overlayManager) Overlays() Overlays {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.overlays
+}
+
+func (m *overlayManager) SetOverlays(overlays Overlays) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.overlays = overlays
+}
+
+func (s *Server) getOverlays(res http.ResponseWriter, req *http.Request) {
+	respond(res, s.overlays.Overlays(), http.StatusOK)
+}
+
+// putOverlays toggles the overlays in the body. Overlays that aren't in the body are left as
+// they are.
+func (s *Server) putOverlays(res http.ResponseWriter, req *http.Request) {
+	overlays := s.overlays.Overlays()
+	if err := json.NewDecoder(req.Body).Decode(&overlays); err != nil {
+		respond(res, err, http.StatusBadRequest)
+		return
+	}
+
+	s.overlays.SetOverlays(overlays)
+
+	respond(res, overlays, http.StatusOK)
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/gloworm-vision/gloworm-app/networktables"
+	"github.com/gloworm-vision/gloworm-app/pipeline"
+)
+
+// photonTable returns the networktables table PhotonVision publishes the camera's results to.
+func photonTable(camera string) string {
+	return "/photonvision/" + camera
+}
+
+// photonEntries are the PhotonVision networktables entries the server publishes to when
+// NTPhotonCamera is set, relative to the camera's photonTable.
+var photonEntries = []networktables.Entry{
+	{Name: "/rawBytes", Value: networktables.EntryValue{EntryType: networktables.RawData, RawData: []byte{}}},
+	{Name: "/latencyMillis", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/hasTarget", Value: networktables.EntryValue{EntryType: networktables.Boolean}},
+	{Name: "/targetPitch", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/targetYaw", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/targetArea", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/targetSkew", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/targetPose", Value: networktables.EntryValue{EntryType: networktables.DoubleArray, DoubleArray: []float64{}}},
+	{Name: "/targetPixelsX", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/targetPixelsY", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/pipelineIndex", Value: networktables.EntryValue{EntryType: networktables.Double}},
+	{Name: "/driverMode", Value: networktables.EntryValue{EntryType: networktables.Boolean}},
+}
+
+// createPhotonEntries creates the PhotonVision networktables entries of the camera.
+func (s *Server) createPhotonEntries(camera string) error {
+	for _, entry := range photonEntries {
+		entry.Name = photonTable(camera) + entry.Name
+		if err := s.NT.Create(entry); err != nil {
+			return fmt.Errorf("unable to create networktables entry %q: %w", entry.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// publishPhoton updates the PhotonVision networktables entries of the camera with the
+// result of the active pipeline. The best target is published on its own, and every target
+// is published in rawBytes, which PhotonLib decodes into a PhotonPipelineResult.
+func (s *Server) publishPhoton(camera string, config pipeline.Config, result pipeline.Result, pipelineIndex int) {
+	var target pipeline.Target
+	if len(result.Targets) > 0 {
+		target = result.Targets[0]
+	}
+
+	var pose []float64
+	if target.Pose != nil {
+		transform := photonTransform(target.Pose)
+		pose = transform[:]
+	}
+
+	values := map[string]networktables.EntryValue{
+		"/rawBytes":      {EntryType: networktables.RawData, RawData: photonPacket(result)},
+		"/latencyMillis": {EntryType: networktables.Double, Double: milliseconds(result.Latency)},
+		"/hasTarget":     {EntryType: networktables.Boolean, Boolean: len(result.Targets) > 0},
+		"/targetPitch":   {EntryType: networktables.Double, Double: target.Pitch},
+		"/targetYaw":     {EntryType: networktables.Double, Double: target.Yaw},
+		"/targetArea":    {EntryType: networktables.Double, Double: target.Area * 100},
+		"/targetSkew":    {EntryType: networktables.Double, Double: target.RotatedRect.Angle},
+		"/targetPose":    doubleArray(pose),
+		"/targetPixelsX": {EntryType: networktables.Double, Double: float64(target.Centroid.X)},
+		"/targetPixelsY": {EntryType: networktables.Double, Double: float64(target.Centroid.Y)},
+		"/pipelineIndex": {EntryType: networktables.Double, Double: float64(pipelineIndex)},
+		"/driverMode":    {EntryType: networktables.Boolean, Boolean: config.Type == pipeline.TypeDriver},
+	}
+
+	for name, value := range values {
+		name = photonTable(camera) + name
+		if err := s.NT.UpdateValue(name, value); err != nil {
+			s.Logger.WithField("entry", name).Debugf("unable to update networktables entry: %s", err)
+		}
+	}
+}
+
+// photonTransform converts a pose to PhotonVision's camera-to-target Transform2d: x forward
+// and y left in meters, and the rotation counterclockwise in degrees. The rotation is only
+// accurate for targets rotated about the vertical axis. It's zero if the pose is nil.
+func photonTransform(pose *pipeline.Pose) [3]float64 {
+	if pose == nil {
+		return [3]float64{}
+	}
+
+	// poses are in camera coordinates, with x right, y down and z forward
+	return [3]float64{
+		pose.Translation[2],
+		-pose.Translation[0],
+		-pose.Rotation[1] * 180 / math.Pi,
+	}
+}
+
+// photonPacket serializes the result like a PhotonPipelineResult, as big-endian values:
+// the latency in milliseconds, the number of targets as a byte, then for each target its
+// yaw, pitch, area (as a percentage), skew, camera-to-target transform (see
+// photonTransform) and four corners (x then y).
+func photonPacket(result pipeline.Result) []byte {
+	var buf bytes.Buffer
+	write := func(v float64) {
+		_ = binary.Write(&buf, binary.BigEndian, v)
+	}
+
+	targets := result.Targets
+	if len(targets) > math.MaxUint8 {
+		targets = targets[:math.MaxUint8]
+	}
+
+	write(milliseconds(result.Latency))
+	buf.WriteByte(byte(len(targets)))
+
+	for _, t := range targets {
+		write(t.Yaw)
+		write(t.Pitch)
+		write(t.Area * 100)
+		write(t.RotatedRect.Angle)
+
+		for _, v := range photonTransform(t.Pose) {
+			write(v)
+		}
+
+		for i := 0; i < 4; i++ {
+			if i < len(t.Corners) {
+				write(float64(t.Corners[i].X))
+				write(float64(t.Corners[i].Y))
+			} else {
+				write(0)
+				write(0)
+			}
+		}
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/gloworm-vision/gloworm-app/pipeline"
+	"github.com/julienschmidt/httprouter"
+	"gocv.io/x/gocv"
+)
+
+// maxTestImageSize is the largest image that can be uploaded to test a pipeline on.
+const maxTestImageSize = 32 << 20
+
+// testImageTypes are the media types of images a pipeline can be tested on.
+var testImageTypes = map[string]bool{"image/jpeg": true, "image/png": true}
+
+// pipelineTestResponse is the result of running a pipeline config on an uploaded image.
+type pipelineTestResponse struct {
+	Result pipeline.Result `json:"result"`
+
+	// Image is the annotated output frame, as a JPEG. It's base64 encoded in JSON.
+	Image []byte `json:"image"`
+}
+
+// testPipeline runs the named pipeline config on the JPEG or PNG image in the request body,
+// responding with its result and annotated output. It runs on its own pipeline, so it doesn't
+// affect the vision loop, which makes it useful for regression checks against saved images.
+func (s *Server) testPipeline(res http.ResponseWriter, req *http.Request) {
+	params := httprouter.ParamsFromContext(req.Context())
+	name := params.ByName("name")
+
+	config, err := s.Store.PipelineConfig(name)
+	if err != nil {
+		respond(res, err, statusCode(err))
+		return
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); !testImageTypes[mediaType] {
+		respond(res, fmt.Errorf("unsupported image type %q, expected a JPEG or PNG", mediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	data, err := ioutil.ReadAll(http.MaxBytesReader(res, req.Body, maxTestImageSize))
+	if err != nil {
+		respond(res, fmt.Errorf("unable to read image: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	frame, err := gocv.IMDecode(data, gocv.IMReadColor)
+	if err != nil {
+		respond(res, fmt.Errorf("unable to decode image: %w", err), http.StatusUnprocessableEntity)
+		return
+	}
+	defer frame.Close()
+
+	if frame.Empty() {
+		respond(res, errors.New("unable to decode image"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	p, err := pipeline.New(config)
+	if err != nil {
+		respond(res, err, http.StatusUnprocessableEntity)
+		return
+	}
+	defer p.Close()
+
+	out := frame.Clone()
+	defer out.Close()
+
+	result := p.ProcessFrame(frame, &out, time.Now())
+
+	image, err := gocv.IMEncode(".jpg", out)
+	if err != nil {
+		respond(res, fmt.Errorf("unable to encode output: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	respond(res, pipelineTestResponse{Result: result, Image: image}, http.StatusOK)
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gloworm-vision/gloworm-app/source"
+	"gocv.io/x/gocv"
+)
+
+const (
+	// minReconnectBackoff and maxReconnectBackoff bound the wait between attempts to reopen
+	// the capture, which doubles after each failed attempt.
+	minReconnectBackoff = 250 * time.Millisecond
+	maxReconnectBackoff = 5 * time.Second
+)
+
+// CaptureState is whether the capture is reading frames.
+type CaptureState string
+
+const (
+	CaptureConnected    CaptureState = "connected"
+	CaptureReconnecting CaptureState = "reconnecting"
+)
+
+// CaptureStatus is the state of the capture, served at /camera/status.
+type CaptureStatus struct {
+	State CaptureState `json:"state"`
+
+	// Since is when the capture entered the state.
+	Since time.Time `json:"since"`
+
+	// Attempts is the number of failed attempts to reopen the capture while reconnecting.
+	Attempts int `json:"attempts"`
+
+	// Error is the latest error reading from or reopening the capture.
+	Error string `json:"error,omitempty"`
+
+	// Captured is the number of frames read from the capture, and Dropped is the number of
+	// them skipped because processing fell behind.
+	Captured uint64 `json:"captured"`
+	Dropped  uint64 `json:"dropped"`
+}
+
+// captureMonitor synchronizes access to the capture status.
+type captureMonitor struct {
+	mu     sync.RWMutex
+	status CaptureStatus
+	queue  *frameQueue
+}
+
+func (c *captureMonitor) set(state CaptureState, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.status.State != state {
+		c.status = CaptureStatus{State: state, Since: time.Now()}
+	}
+
+	c.status.Error = ""
+	if err != nil {
+		c.status.Error = err.Error()
+	}
+}
+
+func (c *captureMonitor) failed(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.status.Attempts++
+	c.status.Error = err.Error()
+}
+
+// setQueue sets the queue frames are counted by.
+func (c *captureMonitor) setQueue(queue *frameQueue) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.queue = queue
+}
+
+func (c *captureMonitor) Status() CaptureStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	status := c.status
+	if c.queue != nil {
+		status.Captured, status.Dropped = c.queue.counts()
+	}
+
+	return status
+}
+
+// reconnect closes the capture and reopens it with OpenCapture, backing off between failed
+// attempts until it succeeds or ctx is done. The active pipeline's camera settings are
+// reapplied to the new capture.
+func (s *Server) reconnect(ctx context.Context, readErr error) error {
+	s.Logger.Warnf("%s, reconnecting", readErr)
+	s.captureMonitor.set(CaptureReconnecting, readErr)
+
+	if err := s.Capture.Close(); err != nil {
+		s.Logger.Warnf("unable to close capture: %s", err)
+	}
+
+	capture, err := reopen(ctx, s.OpenCapture, func(err error) {
+		s.Logger.Debugf("unable to reopen capture: %s", err)
+		s.captureMonitor.failed(err)
+	})
+	if err != nil {
+		return err
+	}
+	s.Capture = capture
+
+	if pipeline := s.pipelineManager.Pipeline(); pipeline != nil && pipeline.Config.Camera != nil {
+		if !pipeline.Config.Camera.Apply(s.Capture) {
+			s.Logger.Warn(errCaptureNotSettable)
+		}
+	}
+
+	s.Logger.Info("reconnected capture")
+	s.captureMonitor.set(CaptureConnected, nil)
+
+	return nil
+}
+
+// reopen opens a capture with open, backing off between failed attempts until it succeeds or
+// ctx is done. failed is called with the error of each failed attempt.
+func reopen(ctx context.Context, open func() (source.FrameSource, error), failed func(err error)) (source.FrameSource, error) {
+	backoff := minReconnectBackoff
+	for {
+		capture, err := open()
+		if err == nil {
+			return capture, nil
+		}
+
+		failed(err)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > maxReconnectBackoff {
+			backoff = maxReconnectBackoff
+		}
+	}
+}
+
+// readFrame reads the next frame from the capture, reconnecting if it can't be read and
+// OpenCapture is set.
+func (s *Server) readFrame(ctx context.Context, frame *gocv.Mat) error {
+	for !s.Capture.Read(frame) {
+		err := errors.New("couldn't read from capture")
+		if s.OpenCapture == nil {
+			return err
+		}
+
+		if err := s.reconnect(ctx, err); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) getCaptureStatus(res http.ResponseWriter, req *http.Request) {
+	respond(res, s.captureMonitor.Status(), http.StatusOK)
+}
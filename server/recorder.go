@@ -0,0 +1,310 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gloworm-vision/gloworm-app/networktables"
+	"github.com/julienschmidt/httprouter"
+	"gocv.io/x/gocv"
+)
+
+const (
+	// defaultMaxSegmentSize is the size segments are rotated at if Server.MaxSegmentSize isn't set.
+	defaultMaxSegmentSize = 100 << 20
+
+	// defaultRecordingFPS is the frame rate recorded at before the capture frame rate is known.
+	defaultRecordingFPS = 30
+
+	// segmentSizeInterval is the number of frames between checking the size of segments.
+	segmentSizeInterval = 30
+
+	// fmsControlData is the networktables entry the FMS publishes the match state to, and
+	// the rest are its bits for the robot being enabled, in autonomous or test mode, and the
+	// FMS being attached.
+	fmsControlData = "/FMSInfo/FMSControlData"
+	fmsEnabled     = 1 << 0
+	fmsAuto        = 1 << 1
+	fmsTest        = 1 << 2
+	fmsAttached    = 1 << 4
+
+	// fmsPollInterval is how often the match state is checked when recording matches.
+	fmsPollInterval = time.Second
+)
+
+// Codec is the video codec recordings are encoded with.
+type Codec string
+
+const (
+	CodecMJPEG Codec = "mjpeg"
+	CodecH264  Codec = "h264"
+)
+
+// fourCC returns the codec's four character code and the extension of the file it's
+// recorded to.
+func (c Codec) fourCC() (string, string) {
+	if c == CodecH264 {
+		return "avc1", ".mp4"
+	}
+
+	return "MJPG", ".avi"
+}
+
+// RecordingOptions are which streams are recorded, and how.
+type RecordingOptions struct {
+	Raw       bool  `json:"raw"`
+	Processed bool  `json:"processed"`
+	Codec     Codec `json:"codec"`
+}
+
+// RecordingStatus is the state of the recorder, served at /recording.
+type RecordingStatus struct {
+	Recording bool              `json:"recording"`
+	Options   *RecordingOptions `json:"options,omitempty"`
+	Started   *time.Time        `json:"started,omitempty"`
+
+	// Segments are the names of the segments being recorded.
+	Segments []string `json:"segments,omitempty"`
+}
+
+// segment is a video file being recorded. Once it reaches the maximum size, it's closed
+// and the next segment is started.
+type segment struct {
+	writer *gocv.VideoWriter
+	path   string
+	frames int
+}
+
+// recorder records the raw and processed frames to timestamped segments.
+type recorder struct {
+	dir     string
+	maxSize int64
+
+	mu       sync.Mutex
+	options  *RecordingOptions
+	started  time.Time
+	segments map[string]*segment // by stream, raw or processed
+	sequence map[string]int
+}
+
+// Start starts recording, replacing the options if already recording.
+func (r *recorder) Start(options RecordingOptions) error {
+	if !options.Raw && !options.Processed {
+		return errors.New("no streams to record")
+	}
+
+	if options.Codec == "" {
+		options.Codec = CodecMJPEG
+	} else if options.Codec != CodecMJPEG && options.Codec != CodecH264 {
+		return fmt.Errorf("unsupported codec %q", options.Codec)
+	}
+
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return fmt.Errorf("unable to create recording directory: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closeSegments()
+	r.options, r.started = &options, time.Now()
+	r.sequence = make(map[string]int)
+
+	return nil
+}
+
+// Stop stops recording, closing the segments.
+func (r *recorder) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closeSegments()
+	r.options = nil
+}
+
+func (r *recorder) Status() RecordingStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.options == nil {
+		return RecordingStatus{}
+	}
+
+	options, started := *r.options, r.started
+	status := RecordingStatus{Recording: true, Options: &options, Started: &started}
+	for _, seg := range r.segments {
+		status.Segments = append(status.Segments, filepath.Base(seg.path))
+	}
+
+	return status
+}
+
+// Write records the frame of the stream, raw or processed, if it's being recorded. Errors
+// stop the stream's segment, and the next frame starts a new one.
+func (r *recorder) Write(stream string, frame gocv.Mat, fps float64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.options == nil || frame.Empty() {
+		return nil
+	}
+	if (stream == viewRaw && !r.options.Raw) || (stream == viewProcessed && !r.options.Processed) {
+		return nil
+	}
+
+	seg, ok := r.segments[stream]
+	if !ok {
+		var err error
+		if seg, err = r.openSegment(stream, frame, fps); err != nil {
+			return err
+		}
+	}
+
+	if err := seg.writer.Write(frame); err != nil {
+		r.closeSegment(stream)
+		return fmt.Errorf("unable to write %s frame: %w", stream, err)
+	}
+	seg.frames++
+
+	if seg.frames%segmentSizeInterval == 0 {
+		if info, err := os.Stat(seg.path); err == nil && info.Size() >= r.maxSize {
+			r.closeSegment(stream)
+		}
+	}
+
+	return nil
+}
+
+// openSegment starts the next segment of the stream. r.mu must be held.
+func (r *recorder) openSegment(stream string, frame gocv.Mat, fps float64) (*segment, error) {
+	if fps <= 0 {
+		fps = defaultRecordingFPS
+	}
+
+	fourCC, ext := r.options.Codec.fourCC()
+	name := fmt.Sprintf("%s-%s-%03d%s", r.started.Format("20060102-150405"), stream, r.sequence[stream], ext)
+	path := filepath.Join(r.dir, name)
+
+	writer, err := gocv.VideoWriterFile(path, fourCC, fps, frame.Cols(), frame.Rows(), frame.Channels() == 3)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s segment: %w", stream, err)
+	}
+
+	if r.segments == nil {
+		r.segments = make(map[string]*segment)
+	}
+
+	seg := &segment{writer: writer, path: path}
+	r.segments[stream] = seg
+	r.sequence[stream]++
+
+	return seg, nil
+}
+
+// closeSegment closes the stream's segment, if it has one. r.mu must be held.
+func (r *recorder) closeSegment(stream string) {
+	if seg, ok := r.segments[stream]; ok {
+		seg.writer.Close()
+		delete(r.segments, stream)
+	}
+}
+
+// closeSegments closes every segment. r.mu must be held.
+func (r *recorder) closeSegments() {
+	for stream := range r.segments {
+		r.closeSegment(stream)
+	}
+}
+
+// recordMatches starts recording the raw and processed streams when a match starts (the
+// robot is enabled while the FMS is attached), and stops once it's disabled, until ctx is
+// done. It only stops recordings it started.
+func (s *Server) recordMatches(ctx context.Context) {
+	ticker := time.NewTicker(fmsPollInterval)
+	defer ticker.Stop()
+
+	var recording bool
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		entry, err := s.NT.Get(fmsControlData)
+		if err != nil || entry.Value.EntryType != networktables.Double {
+			continue
+		}
+
+		control := int(entry.Value.Double)
+		inMatch := control&fmsAttached != 0 && control&fmsEnabled != 0
+
+		switch {
+		case inMatch && !recording && !s.recorder.Status().Recording:
+			s.Logger.Info("match started, recording")
+			if err := s.recorder.Start(RecordingOptions{Raw: true, Processed: true}); err != nil {
+				s.Logger.Warnf("unable to start recording match: %s", err)
+				continue
+			}
+			recording = true
+		case !inMatch && recording:
+			s.Logger.Info("match ended, stopped recording")
+			s.recorder.Stop()
+			recording = false
+		}
+	}
+}
+
+func (s *Server) getRecording(res http.ResponseWriter, req *http.Request) {
+	respond(res, s.recorder.Status(), http.StatusOK)
+}
+
+func (s *Server) startRecording(res http.ResponseWriter, req *http.Request) {
+	options := RecordingOptions{Raw: true, Processed: true}
+	if req.ContentLength != 0 {
+		if err := json.NewDecoder(req.Body).Decode(&options); err != nil {
+			respond(res, err, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := s.recorder.Start(options); err != nil {
+		respond(res, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	respond(res, s.recorder.Status(), http.StatusOK)
+}
+
+// recordingExts are the extensions of recorded segments.
+var recordingExts = map[string]bool{"avi": true, "mp4": true}
+
+func (s *Server) listRecordings(res http.ResponseWriter, req *http.Request) {
+	recordings, err := listFiles(s.RecordingDir, recordingExts)
+	if err != nil {
+		respond(res, err, http.StatusInternalServerError)
+		return
+	}
+
+	respond(res, recordings, http.StatusOK)
+}
+
+// getRecordingFile downloads the named recorded segment.
+func (s *Server) getRecordingFile(res http.ResponseWriter, req *http.Request) {
+	params := httprouter.ParamsFromContext(req.Context())
+
+	serveSavedFile(res, req, s.RecordingDir, params.ByName("name"), recordingExts)
+}
+
+func (s *Server) stopRecording(res http.ResponseWriter, req *http.Request) {
+	s.recorder.Stop()
+
+	respond(res, nil, http.StatusNoContent)
+}
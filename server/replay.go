@@ -0,0 +1,189 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gloworm-vision/gloworm-app/pipeline"
+	"github.com/gloworm-vision/gloworm-app/source"
+	"gocv.io/x/gocv"
+)
+
+const (
+	// replayStream is the name of the stream the output of replays is served on.
+	replayStream = "replay"
+
+	// maxReplaySize is the largest video that can be uploaded to replay. The upload has to
+	// finish within the server's 15 second read timeout, which only leaves time for short
+	// clips over the robot's network.
+	maxReplaySize = 64 << 20
+)
+
+type replayRequest struct {
+	// Recording is the name of a recorded segment to replay.
+	Recording string `json:"recording"`
+}
+
+// replayFrame is the result of a replayed frame.
+type replayFrame struct {
+	Frame int `json:"frame"`
+	pipeline.Result
+}
+
+// replaySummary is sent once every frame has been replayed.
+type replaySummary struct {
+	Frames       int `json:"frames"`
+	TargetFrames int `json:"targetFrames"`
+}
+
+// replay feeds a video through the active pipeline config, or the config named by the
+// pipeline query parameter, streaming the result of every frame back as server-sent events
+// and the output frames to the replay stream. The video is either the request body, or a
+// recorded segment named by a JSON body. The replay runs on its own pipeline, so it doesn't
+// affect the vision loop.
+func (s *Server) replay(res http.ResponseWriter, req *http.Request) {
+	config, err := s.replayConfig(req.URL.Query().Get("pipeline"))
+	if err != nil {
+		respond(res, err, statusCode(err))
+		return
+	}
+
+	path, cleanup, err := s.replayVideo(res, req)
+	if err != nil {
+		respond(res, err, http.StatusBadRequest)
+		return
+	}
+	defer cleanup()
+
+	video, err := source.OpenVideo(path, false)
+	if err != nil {
+		respond(res, err, http.StatusUnprocessableEntity)
+		return
+	}
+	defer video.Close()
+
+	p, err := pipeline.New(config)
+	if err != nil {
+		respond(res, err, http.StatusUnprocessableEntity)
+		return
+	}
+	defer p.Close()
+
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		respond(res, errors.New("streaming isn't supported"), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.WriteHeader(http.StatusOK)
+
+	send := func(event string, data interface{}) error {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintf(res, "event: %s\ndata: %s\n\n", event, encoded); err != nil {
+			return err
+		}
+		flusher.Flush()
+
+		return nil
+	}
+
+	frame := gocv.NewMat()
+	defer frame.Close()
+
+	var summary replaySummary
+	for video.Read(&frame) {
+		if req.Context().Err() != nil {
+			return
+		}
+
+		result := p.ProcessFrame(frame, &frame, time.Now())
+		result.Sequence = uint64(summary.Frames + 1)
+		p.DrawStamp(&frame, result)
+
+		if buf, err := gocv.IMEncode(".jpg", frame); err == nil {
+			s.streams[replayStream].UpdateJPEG(buf)
+		}
+
+		if len(result.Targets) > 0 {
+			summary.TargetFrames++
+		}
+
+		if err := send("result", replayFrame{Frame: summary.Frames, Result: result}); err != nil {
+			return
+		}
+		summary.Frames++
+	}
+
+	_ = send("done", summary)
+}
+
+// replayConfig returns the named pipeline config, or the active pipeline's config if the
+// name is empty.
+func (s *Server) replayConfig(name string) (pipeline.Config, error) {
+	if name != "" {
+		return s.Store.PipelineConfig(name)
+	}
+
+	active := s.pipelineManager.Pipeline()
+	if active == nil {
+		return pipeline.Config{}, errors.New("no active pipeline")
+	}
+
+	return active.Config, nil
+}
+
+// replayVideo returns the path of the video to replay, and a function that removes it if
+// it was uploaded.
+func (s *Server) replayVideo(res http.ResponseWriter, req *http.Request) (string, func(), error) {
+	noop := func() {}
+
+	if mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); mediaType == "application/json" {
+		var replay replayRequest
+		if err := json.NewDecoder(req.Body).Decode(&replay); err != nil {
+			return "", noop, err
+		}
+
+		name := replay.Recording
+		if name == "" || name != filepath.Base(name) || !hasExt(name, recordingExts) {
+			return "", noop, fmt.Errorf("invalid recording %q", name)
+		}
+
+		path := filepath.Join(s.RecordingDir, name)
+		if _, err := os.Stat(path); err != nil {
+			return "", noop, fmt.Errorf("no recording %q", name)
+		}
+
+		return path, noop, nil
+	}
+
+	f, err := ioutil.TempFile("", "gloworm-replay-*")
+	if err != nil {
+		return "", noop, fmt.Errorf("unable to create upload file: %w", err)
+	}
+	cleanup := func() { os.Remove(f.Name()) }
+
+	_, err = io.Copy(f, http.MaxBytesReader(res, req.Body, maxReplaySize))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", noop, fmt.Errorf("unable to upload video: %w", err)
+	}
+
+	return f.Name(), cleanup, nil
+}
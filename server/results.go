@@ -0,0 +1,369 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gloworm-vision/gloworm-app/pipeline"
+)
+
+// resultMessage is a frame's pipeline result, as sent to clients watching live results.
+type resultMessage struct {
+	pipeline.Result
+
+	// Pipeline is the name of the active pipeline config.
+	Pipeline string `json:"pipeline"`
+
+	TargetFound bool `json:"targetFound"`
+
+	// FPS is the rate the vision loop is processing frames at.
+	FPS float64 `json:"fps"`
+
+	// FrameRates are the rates frames are captured, processed and streamed at. It's only set
+	// for the main camera.
+	FrameRates *FrameRates `json:"frameRates,omitempty"`
+}
+
+// resultSubscriberBuffer is the number of results buffered for each subscriber. Results
+// are dropped for subscribers that fall further behind.
+const resultSubscriberBuffer = 4
+
+// defaultResultHistory is the number of recent results kept by default, which is about a
+// match's worth at 30 fps.
+const defaultResultHistory = 4500
+
+// resultBroadcaster fans out each frame's result to subscribers, such as websocket clients,
+// and keeps the latest result for clients that poll. The most recent results are kept in a
+// ring buffer, so clients can backfill results they missed.
+type resultBroadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan resultMessage]struct{}
+
+	latest    resultMessage
+	hasLatest bool
+
+	history []resultMessage
+	next    int
+	full    bool
+}
+
+func newResultBroadcaster(history int) *resultBroadcaster {
+	return &resultBroadcaster{history: make([]resultMessage, history)}
+}
+
+// subscribe returns a channel that receives results, and a function that unsubscribes it.
+func (b *resultBroadcaster) subscribe() (<-chan resultMessage, func()) {
+	ch := make(chan resultMessage, resultSubscriberBuffer)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscribers == nil {
+		b.subscribers = make(map[chan resultMessage]struct{})
+	}
+	b.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.subscribers, ch)
+	}
+}
+
+// publish sends the result to every subscriber without blocking the vision loop.
+func (b *resultBroadcaster) publish(msg resultMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.latest, b.hasLatest = msg, true
+
+	if len(b.history) > 0 {
+		b.history[b.next] = msg
+		b.next = (b.next + 1) % len(b.history)
+		b.full = b.full || b.next == 0
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+}
+
+// last returns the latest result, and false if no results have been published yet.
+func (b *resultBroadcaster) last() (resultMessage, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.latest, b.hasLatest
+}
+
+// since returns the kept results of frames captured after the time, oldest first.
+func (b *resultBroadcaster) since(t time.Time) []resultMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ordered := b.history[:b.next]
+	if b.full {
+		ordered = append(append([]resultMessage(nil), b.history[b.next:]...), b.history[:b.next]...)
+	}
+
+	results := make([]resultMessage, 0, len(ordered))
+	for _, msg := range ordered {
+		if msg.Timestamp.After(t) {
+			results = append(results, msg)
+		}
+	}
+
+	return results
+}
+
+// frameRateWindow is the number of frames the frame rate is measured over.
+const frameRateWindow = 30
+
+// frameRate measures the rate of frames over the last frameRateWindow frames. The zero
+// value is ready to use.
+type frameRate struct {
+	times [frameRateWindow]time.Time
+	next  int
+	count int
+}
+
+// tick records a frame at the given time, and returns the current frame rate.
+func (f *frameRate) tick(t time.Time) float64 {
+	// the number of intervals between the oldest recorded frame and this one
+	intervals := f.count
+
+	oldest := f.times[0]
+	if f.count == frameRateWindow {
+		oldest = f.times[f.next]
+	} else {
+		f.count++
+	}
+
+	f.times[f.next] = t
+	f.next = (f.next + 1) % frameRateWindow
+
+	elapsed := t.Sub(oldest).Seconds()
+	if intervals == 0 || elapsed <= 0 {
+		return 0
+	}
+
+	return float64(intervals) / elapsed
+}
+
+// frameRateTimeout is how long after the last frame a frame rate is reported as 0, so rates
+// don't stay frozen at their last value when frames stop.
+const frameRateTimeout = time.Second
+
+// FrameRates are the rates in frames per second the vision loop is capturing frames at,
+// processing them with the active pipeline at, and updating the main stream at. Frames are
+// captured faster than they're processed when processing falls behind, and the difference is
+// dropped.
+type FrameRates struct {
+	Capture   float64 `json:"capture"`
+	Processed float64 `json:"processed"`
+	Stream    float64 `json:"stream"`
+}
+
+// frameRateMonitor measures the vision loop's frame rates, which are ticked by different
+// goroutines.
+type frameRateMonitor struct {
+	mu sync.Mutex
+
+	capture, processed, stream measuredRate
+}
+
+// measuredRate is a frame rate, and the rate as of its last frame.
+type measuredRate struct {
+	frameRate
+
+	value float64
+	last  time.Time
+}
+
+func (r *measuredRate) tick(t time.Time) float64 {
+	r.value, r.last = r.frameRate.tick(t), t
+	return r.value
+}
+
+// current returns the rate, or 0 if there hasn't been a frame within frameRateTimeout.
+func (r *measuredRate) current(now time.Time) float64 {
+	if now.Sub(r.last) > frameRateTimeout {
+		return 0
+	}
+
+	return r.value
+}
+
+// captured records a captured frame, and returns the capture rate.
+func (m *frameRateMonitor) captured(t time.Time) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.capture.tick(t)
+}
+
+// processedFrame records a processed frame.
+func (m *frameRateMonitor) processedFrame(t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.processed.tick(t)
+}
+
+// streamed records a frame sent to the main stream.
+func (m *frameRateMonitor) streamed(t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stream.tick(t)
+}
+
+// Rates returns the current frame rates.
+func (m *frameRateMonitor) Rates() FrameRates {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	return FrameRates{
+		Capture:   m.capture.current(now),
+		Processed: m.processed.current(now),
+		Stream:    m.stream.current(now),
+	}
+}
+
+// latestResult responds with the most recent result.
+func (s *Server) latestResult(res http.ResponseWriter, req *http.Request) {
+	result, ok := s.results.last()
+	if !ok {
+		respond(res, errors.New("no results yet"), http.StatusNotFound)
+		return
+	}
+
+	respond(res, result, http.StatusOK)
+}
+
+// resultHistory responds with the recent results, oldest first. If the since query parameter
+// is set (as an RFC 3339 timestamp), only results of frames captured after it are included, so
+// a reconnecting client can backfill the results it missed.
+func (s *Server) resultHistory(res http.ResponseWriter, req *http.Request) {
+	serveResultHistory(res, req, s.results)
+}
+
+// serveResultHistory responds with the recent results from the broadcaster, filtered by the
+// since query parameter.
+func serveResultHistory(res http.ResponseWriter, req *http.Request, results *resultBroadcaster) {
+	var since time.Time
+	if raw := req.URL.Query().Get("since"); raw != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339Nano, raw); err != nil {
+			respond(res, fmt.Errorf("invalid since %q", raw), http.StatusBadRequest)
+			return
+		}
+	}
+
+	respond(res, results.since(since), http.StatusOK)
+}
+
+// resultsWebsocket streams each frame's result to the client as a JSON text message.
+func (s *Server) resultsWebsocket(res http.ResponseWriter, req *http.Request) {
+	conn, err := upgradeWebsocket(res, req)
+	if err != nil {
+		s.requestLogger(req).Debugf("unable to upgrade results websocket: %s", err)
+		return
+	}
+	defer conn.Close()
+
+	results, unsubscribe := s.results.subscribe()
+	defer unsubscribe()
+
+	// clients don't send anything, but reading is needed to notice when they disconnect
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case result := <-results:
+			data, err := json.Marshal(result)
+			if err != nil {
+				s.requestLogger(req).Warnf("unable to encode result: %s", err)
+				continue
+			}
+
+			if err := conn.WriteText(data); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// resultsEvents streams each frame's result to the client as server-sent events, for clients
+// that can't use websockets. The optional maxRate query parameter limits the number of results
+// sent per second; results in between are skipped.
+func (s *Server) resultsEvents(res http.ResponseWriter, req *http.Request) {
+	var interval time.Duration
+	if raw := req.URL.Query().Get("maxRate"); raw != "" {
+		maxRate, err := strconv.ParseFloat(raw, 64)
+		if err != nil || maxRate <= 0 {
+			respond(res, fmt.Errorf("invalid max rate %q", raw), http.StatusBadRequest)
+			return
+		}
+
+		interval = time.Duration(float64(time.Second) / maxRate)
+	}
+
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		respond(res, errors.New("streaming isn't supported"), http.StatusInternalServerError)
+		return
+	}
+
+	results, unsubscribe := s.results.subscribe()
+	defer unsubscribe()
+
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var last time.Time
+	for {
+		select {
+		case result := <-results:
+			if interval > 0 && time.Since(last) < interval {
+				continue
+			}
+			last = time.Now()
+
+			data, err := json.Marshal(result)
+			if err != nil {
+				s.requestLogger(req).Warnf("unable to encode result: %s", err)
+				continue
+			}
+
+			if _, err := fmt.Fprintf(res, "event: result\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
@@ -2,16 +2,22 @@ package server
 
 import (
 	"context"
-	"errors"
+	"expvar"
 	"fmt"
+	"io/fs"
+	"log"
+	"net"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/gloworm-vision/gloworm-app/hardware"
+	"github.com/gloworm-vision/gloworm-app/network"
 	"github.com/gloworm-vision/gloworm-app/networktables"
 	"github.com/gloworm-vision/gloworm-app/pipeline"
+	"github.com/gloworm-vision/gloworm-app/source"
 	"github.com/gloworm-vision/gloworm-app/store"
+	"github.com/gloworm-vision/gloworm-app/system"
 	"github.com/hybridgroup/mjpeg"
 	"github.com/julienschmidt/httprouter"
 	"github.com/sirupsen/logrus"
@@ -22,18 +28,201 @@ type Server struct {
 	Addr string
 
 	Store   store.Store
-	Capture *gocv.VideoCapture
-	Logger  *logrus.Logger
-	NT      networktables.Client
-
-	stream *mjpeg.Stream
-
-	pipelineManager *pipelineManager
-	hardwareManager *hardwareManager
+	Capture source.FrameSource
+
+	// OpenCapture, if set, is used to reopen the capture after it fails to read a frame,
+	// instead of stopping the server. The capture is closed before it's reopened.
+	OpenCapture func() (source.FrameSource, error)
+
+	// Cameras are additional capture devices by ID, each with its own pipeline (initially the
+	// default pipeline), stream and results, served under /cameras/:id. Their results are
+	// published to networktables under /gloworm/cameras/<id>.
+	Cameras map[string]source.FrameSource
+
+	// OpenCamera, if set, is used to reopen the additional camera with the ID after it fails
+	// to read a frame, as OpenCapture is for the capture. Otherwise the camera stops being
+	// read, but the server keeps running.
+	OpenCamera func(id string) (source.FrameSource, error)
+
+	// StereoCameras, if set, names two of Cameras that are read together as a stereo pair
+	// instead of separately. Their frames are processed by the left camera's pipeline, which
+	// triangulates the position of targets if its config has a stereo calibration.
+	StereoCameras *StereoPair
+
+	Logger *logrus.Logger
+	NT     networktables.Client
+
+	// NTLimelight enables also publishing results to the /limelight networktables table,
+	// with the same keys and units as a Limelight, so robot code written for a Limelight
+	// works unchanged.
+	NTLimelight bool
+
+	// NTPhotonCamera, if set, enables also publishing results to the PhotonVision
+	// networktables tables, so robot code using PhotonLib works unchanged. It's the camera
+	// name used for the capture, and additional cameras use their IDs.
+	NTPhotonCamera string
+
+	// NTPipelineEntry, if set, is the networktables entry robot code writes to switch the
+	// active pipeline, either the name of the pipeline config or (like a Limelight) its
+	// index in the sorted list of pipeline configs. If NTLimelight is enabled, the Limelight
+	// pipeline entry switches it too.
+	NTPipelineEntry string
+
+	// UDPAddr, if set, is the address each frame's result is also sent to as a UDP datagram
+	// in UDPFormat (UDPBinary by default), for robot code that wants lower latency than
+	// networktables.
+	UDPAddr   string
+	UDPFormat string
+
+	// ResultHistory is the number of recent results kept for each camera (4500 by default, about
+	// a match at 30 fps), served at /results/history so dashboards can backfill results they
+	// missed and teams can review a match afterwards.
+	ResultHistory int
+
+	// MaxStreamClients is the most clients that can watch streams at once, across every
+	// stream, since each one costs bandwidth and CPU. Further clients are rejected with a 503.
+	// There's no limit if it's 0.
+	MaxStreamClients int
+
+	// DebugStreams enables streaming the intermediate images of the active pipeline (for
+	// example, the thresholded mask) at /stream/:name, where name is the pipeline stage.
+	DebugStreams bool
+
+	// ShowFPS draws the capture, processed and stream frame rates onto the main stream, until
+	// they're toggled at /overlays.
+	ShowFPS bool
+
+	// Auth, if set, is required to make requests that change the server's state, such as
+	// putting configs and RPCs.
+	Auth *Auth
+
+	// AllowedOrigins are the origins allowed to make cross-origin requests, such as a UI
+	// served from a laptop during development. "*" allows any origin, but only origins named
+	// explicitly can make requests with the browser's credentials. By default, only
+	// same-origin requests are allowed.
+	AllowedOrigins []string
+
+	// TLSCert and TLSKey, if set, are the paths of the PEM encoded certificate and key the API
+	// is served over HTTPS with. If SelfSignedTLS is set and they don't exist, a self-signed
+	// certificate is generated and saved to them.
+	TLSCert       string
+	TLSKey        string
+	SelfSignedTLS bool
+
+	// HTTPRedirectAddr, if set along with TLS, is the address plain HTTP requests are
+	// redirected to HTTPS from.
+	HTTPRedirectAddr string
+
+	// Network, if set, lets the device's network interface and hostname be configured at
+	// /network.
+	Network *network.Manager
+
+	// UI, if set, is the web UI served at /. Paths that don't match a route or a file serve
+	// the UI's index.html, so the UI can route on the client.
+	UI fs.FS
+
+	// SnapshotDir is the directory snapshots of raw and processed frames are saved to. The
+	// oldest snapshots are deleted to keep their total size under MaxSnapshotSize bytes
+	// (1 GiB by default).
+	SnapshotDir     string
+	MaxSnapshotSize int64
+
+	// RecordingDir is the directory recordings of the raw and processed streams are saved to,
+	// in segments of up to about MaxSegmentSize bytes (100 MiB by default).
+	RecordingDir   string
+	MaxSegmentSize int64
+
+	// BackupDir, if set, is the directory a copy of the store is saved to every BackupInterval
+	// (an hour by default) if it changed, to recover from an SD card corrupted by a hard power
+	// off. The oldest copies are deleted to keep MaxBackups of them (24 by default).
+	BackupDir      string
+	BackupInterval time.Duration
+	MaxBackups     int
+
+	// RecordMatches enables recording the raw and processed streams during matches, when
+	// the FMS enables the robot.
+	RecordMatches bool
+
+	// Profiling enables profiling how long each pipeline stage (and encoding the stream) takes,
+	// which is served at /profile.
+	Profiling bool
+
+	// DebugEndpoints enables serving runtime profiles at /debug/pprof and runtime vars (such
+	// as frame counters and the number of goroutines) at /debug/vars, to profile performance
+	// on the device. They need credentials, if any are set.
+	DebugEndpoints bool
+
+	// Workers is the number of frames processed in parallel, each by a worker with its own
+	// copy of the active pipeline, to make use of multi-core devices. Pipelines with stages
+	// that carry state between frames (such as tracking) still process one frame at a time.
+	// Results are always published in the order frames were captured.
+	Workers int
+
+	stream   *mjpeg.Stream
+	streams  map[string]*mjpeg.Stream
+	views    map[string]*viewStream
+	variants map[string]*scaledStream
+
+	// streamClients is the number of clients watching streams.
+	streamClients int32
+
+	frameRequests    chan chan gocv.Mat
+	captureRequests  chan func(capture source.FrameSource)
+	captureMonitor   *captureMonitor
+	frameRates       *frameRateMonitor
+	overlays         *overlayManager
+	snapshotRequests chan chan snapshot
+	snapshots        *snapshotGallery
+	results          *resultBroadcaster
+	changes          *changeBroadcaster
+	logs             *logBuffer
+	system           *system.Monitor
+	recorder         *recorder
+	calibrator       *calibrator
+	udp              net.Conn
+	redirectServer   *http.Server
+	networkConfirmer *networkConfirmer
+	matchMonitor     *matchMonitor
+
+	cameras map[string]*camera
+
+	pipelineManager   *pipelineManager
+	comparisonManager *comparisonManager
+	hardwareManager   *hardwareManager
+	settingsManager   *settingsManager
 }
 
+// Run serves the API and runs the vision loops until ctx is done or either fails. When it
+// returns, the hardware lights are off, and the captures and store are closed.
 func (s *Server) Run(ctx context.Context) error {
-	s.stream = mjpeg.NewStream()
+	s.logs = newLogBuffer(logBufferSize)
+	s.system = &system.Monitor{}
+	s.recorder = &recorder{dir: s.RecordingDir, maxSize: s.MaxSegmentSize}
+	if s.recorder.maxSize <= 0 {
+		s.recorder.maxSize = defaultMaxSegmentSize
+	}
+	s.calibrator = &calibrator{}
+	s.networkConfirmer = &networkConfirmer{}
+	s.matchMonitor = &matchMonitor{}
+	s.Logger.AddHook(s.logs)
+
+	s.initStreams()
+	s.frameRequests = make(chan chan gocv.Mat)
+	s.captureRequests = make(chan func(capture source.FrameSource))
+	s.snapshotRequests = make(chan chan snapshot)
+	s.snapshots = &snapshotGallery{dir: s.SnapshotDir, maxSize: s.MaxSnapshotSize}
+	if s.snapshots.maxSize <= 0 {
+		s.snapshots.maxSize = defaultMaxSnapshotSize
+	}
+	if s.ResultHistory <= 0 {
+		s.ResultHistory = defaultResultHistory
+	}
+	s.results = newResultBroadcaster(s.ResultHistory)
+	s.changes = &changeBroadcaster{}
+	s.captureMonitor = &captureMonitor{}
+	s.frameRates = &frameRateMonitor{}
+	s.overlays = &overlayManager{overlays: s.defaultOverlays()}
+	s.captureMonitor.set(CaptureConnected, nil)
 
 	if err := s.init(); err != nil {
 		return fmt.Errorf("unable to initialize: %w", err)
@@ -41,72 +230,221 @@ func (s *Server) Run(ctx context.Context) error {
 
 	mux := httprouter.New()
 
-	mux.Handler(http.MethodGet, "/stream", s.stream)
+	mux.HandlerFunc(http.MethodGet, "/stream", s.getMainStream)
+	mux.HandlerFunc(http.MethodGet, "/stream/:name", s.getStream)
+	mux.HandlerFunc(http.MethodGet, "/result", s.latestResult)
+	mux.HandlerFunc(http.MethodGet, "/ws", s.resultsWebsocket)
+	mux.HandlerFunc(http.MethodGet, "/ws/tune", s.tuneWebsocket)
+	mux.HandlerFunc(http.MethodGet, "/results/stream", s.resultsEvents)
+	mux.HandlerFunc(http.MethodGet, "/results/history", s.resultHistory)
+	mux.HandlerFunc(http.MethodGet, "/watch", s.watch)
+
+	mux.HandlerFunc(http.MethodPost, "/snapshots", s.postSnapshot)
+	mux.HandlerFunc(http.MethodGet, "/snapshots", s.listSnapshots)
+	mux.HandlerFunc(http.MethodGet, "/snapshots/:name", s.getSnapshot)
+	mux.HandlerFunc(http.MethodDelete, "/snapshots/:name", s.deleteSnapshot)
+	mux.HandlerFunc(http.MethodPut, "/snapshots/:name/tags", s.putSnapshotTags)
+
+	mux.HandlerFunc(http.MethodGet, "/recording", s.getRecording)
+	mux.HandlerFunc(http.MethodPost, "/recording/start", s.startRecording)
+	mux.HandlerFunc(http.MethodPost, "/recording/stop", s.stopRecording)
+	mux.HandlerFunc(http.MethodGet, "/recordings", s.listRecordings)
+	mux.HandlerFunc(http.MethodGet, "/recordings/:name", s.getRecordingFile)
+	mux.HandlerFunc(http.MethodPost, "/replay", s.replay)
 
 	mux.HandlerFunc(http.MethodGet, "/pipeline", s.getDefaultPipeline)
 	mux.HandlerFunc(http.MethodPut, "/pipeline", s.putDefaultPipeline)
 	mux.HandlerFunc(http.MethodGet, "/pipelines", s.pipelines)
 	mux.HandlerFunc(http.MethodGet, "/pipelines/:name", s.getPipeline)
 	mux.HandlerFunc(http.MethodPut, "/pipelines/:name", s.putPipeline)
+	mux.HandlerFunc(http.MethodDelete, "/pipelines/:name", s.deletePipeline)
+	mux.HandlerFunc(http.MethodPost, "/pipelines/:name/duplicate", s.duplicatePipeline)
+	mux.HandlerFunc(http.MethodPost, "/pipelines/:name/rename", s.renamePipeline)
+	mux.HandlerFunc(http.MethodPost, "/pipelines/:name/test", s.testPipeline)
+	mux.HandlerFunc(http.MethodGet, "/pipelines/:name/revisions", s.pipelineRevisions)
+	mux.HandlerFunc(http.MethodPost, "/pipelines/:name/restore", s.restorePipeline)
+	mux.HandlerFunc(http.MethodGet, "/pipelines/:name/metadata", s.getPipelineMetadata)
+	mux.HandlerFunc(http.MethodPut, "/pipelines/:name/metadata", s.putPipelineMetadata)
+
+	mux.HandlerFunc(http.MethodGet, "/schema/pipeline", s.pipelineSchema)
+	mux.HandlerFunc(http.MethodGet, "/profile", s.profile)
+
+	if s.DebugEndpoints {
+		s.publishDebugVars()
+		mux.HandlerFunc(http.MethodGet, "/debug/vars", s.requireDebugAuth(expvar.Handler().ServeHTTP))
+		mux.HandlerFunc(http.MethodGet, "/debug/pprof/*profile", s.requireDebugAuth(s.pprof))
+		mux.HandlerFunc(http.MethodPost, "/debug/pprof/*profile", s.requireDebugAuth(s.pprof))
+	}
+	mux.HandlerFunc(http.MethodGet, "/logs", s.getLogs)
+	mux.HandlerFunc(http.MethodGet, "/system", s.getSystem)
+	mux.HandlerFunc(http.MethodGet, "/match", s.getMatch)
+	mux.HandlerFunc(http.MethodGet, "/network", s.getNetwork)
+	mux.HandlerFunc(http.MethodPut, "/network", s.putNetwork)
+	mux.HandlerFunc(http.MethodPost, "/network/confirm", s.confirmNetwork)
+	mux.HandlerFunc(http.MethodGet, "/overlays", s.getOverlays)
+	mux.HandlerFunc(http.MethodPut, "/overlays", s.putOverlays)
+
+	mux.HandlerFunc(http.MethodGet, "/comparison", s.getComparison)
+	mux.HandlerFunc(http.MethodPut, "/comparison", s.putComparison)
+	mux.HandlerFunc(http.MethodDelete, "/comparison", s.deleteComparison)
+
+	mux.HandlerFunc(http.MethodGet, "/cameras", s.listCameras)
+	mux.HandlerFunc(http.MethodGet, "/cameras/:id/stream", s.getCameraStream)
+	mux.HandlerFunc(http.MethodGet, "/cameras/:id/result", s.getCameraResult)
+	mux.HandlerFunc(http.MethodGet, "/cameras/:id/results/history", s.getCameraResultHistory)
+	mux.HandlerFunc(http.MethodGet, "/cameras/:id/pipeline", s.getCameraPipeline)
+	mux.HandlerFunc(http.MethodPut, "/cameras/:id/pipeline", s.putCameraPipeline)
+
+	mux.HandlerFunc(http.MethodGet, "/camera", s.getCamera)
+	mux.HandlerFunc(http.MethodPut, "/camera", s.putCamera)
+	mux.HandlerFunc(http.MethodGet, "/camera/status", s.getCaptureStatus)
+
+	mux.HandlerFunc(http.MethodGet, "/calibration", s.getCalibration)
+	mux.HandlerFunc(http.MethodPost, "/calibration", s.startCalibration)
+	mux.HandlerFunc(http.MethodDelete, "/calibration", s.stopCalibration)
+	mux.HandlerFunc(http.MethodPost, "/calibration/capture", s.captureCalibration)
+	mux.HandlerFunc(http.MethodPost, "/calibration/solve", s.solveCalibration)
+	mux.HandlerFunc(http.MethodPost, "/calibration/save", s.saveCalibration)
+
+	mux.HandlerFunc(http.MethodGet, "/config/export", s.exportConfig)
+	mux.HandlerFunc(http.MethodPost, "/config/import", s.importConfig)
+
+	mux.HandlerFunc(http.MethodGet, "/settings", s.getSettings)
+	mux.HandlerFunc(http.MethodPut, "/settings", s.putSettings)
+	mux.HandlerFunc(http.MethodGet, "/settings/logging", s.getLogging)
+	mux.HandlerFunc(http.MethodPut, "/settings/logging", s.putLogging)
 
 	mux.HandlerFunc(http.MethodGet, "/hardware", s.getHardware)
 	mux.HandlerFunc(http.MethodPut, "/hardware", s.putHardware)
+	mux.HandlerFunc(http.MethodGet, "/hardware/status", s.getHardwareStatus)
+	mux.HandlerFunc(http.MethodPut, "/hardware/lights", s.putLights)
 
 	mux.HandlerFunc(http.MethodPost, "/rpc/updatePipeline", s.updatePipeline)
 	mux.HandlerFunc(http.MethodPost, "/rpc/updateHardware", s.updateHardware)
+	mux.HandlerFunc(http.MethodPost, "/rpc/suggestThresholds", s.suggestThresholds)
+	mux.HandlerFunc(http.MethodPost, "/rpc/checkStore", s.checkStore)
+	mux.HandlerFunc(http.MethodPost, "/rpc/compactStore", s.compactStore)
+
+	if s.UI != nil {
+		mux.NotFound = uiHandler(s.UI)
+	}
 
 	httpServer := &http.Server{
 		Addr:              s.Addr,
-		Handler:           mux,
+		Handler:           s.accessLog(s.cors(s.requireAuth(mux))),
 		ReadTimeout:       time.Second * 15,
 		ReadHeaderTimeout: time.Second * 15,
 		IdleTimeout:       time.Second * 30,
 		MaxHeaderBytes:    4096,
+		ConnContext:       withConn,
+		ErrorLog:          log.New(s.Logger.WriterLevel(logrus.WarnLevel), "", 0),
+	}
+
+	if err := s.initTLS(); err != nil {
+		return fmt.Errorf("unable to initialize tls: %w", err)
 	}
 
-	listenErrs := make(chan error)
+	listenErrs := make(chan error, 2)
 	go func() {
-		s.Logger.WithField("addr", s.Addr).Info("serving http")
-		listenErrs <- httpServer.ListenAndServe()
+		listenErrs <- s.listen(httpServer)
 	}()
 
+	if s.tlsEnabled() && s.HTTPRedirectAddr != "" {
+		s.redirectServer = s.newRedirectServer()
+		go func() {
+			s.Logger.WithField("addr", s.HTTPRedirectAddr).Info("redirecting http to https")
+			listenErrs <- s.redirectServer.ListenAndServe()
+		}()
+	}
+
 	visionCtx, cancelVision := context.WithCancel(ctx)
 	defer cancelVision()
 
-	visionErrs := make(chan error)
-	go func() {
-		s.Logger.Info("starting vision loop")
-		visionErrs <- s.runVision(visionCtx)
-	}()
+	var visionLoops sync.WaitGroup
+	visionErrs := make(chan error, 1+len(s.cameras))
+	runLoop := func(run func(ctx context.Context) error) {
+		visionLoops.Add(1)
+		go func() {
+			defer visionLoops.Done()
+			visionErrs <- run(visionCtx)
+		}()
+	}
+
+	s.Logger.Info("starting vision loop")
+	runLoop(s.runVision)
+
+	var left, right *camera
+	if s.StereoCameras != nil {
+		left, right, _ = s.stereoCameras()
+		s.Logger.WithField("left", left.id).WithField("right", right.id).Info("starting stereo vision loop")
+		runLoop(func(ctx context.Context) error { return s.runStereo(ctx, left, right) })
+	}
+
+	for _, cam := range s.cameras {
+		if cam == left || cam == right {
+			continue
+		}
+
+		cam := cam
+		s.Logger.WithField("camera", cam.id).Info("starting camera vision loop")
+		runLoop(func(ctx context.Context) error { return s.runCamera(ctx, cam) })
+	}
+
+	if s.RecordMatches {
+		go s.recordMatches(visionCtx)
+	}
 
+	if s.NTPipelineEntry != "" || s.NTLimelight {
+		go s.switchPipelines(visionCtx)
+	}
+
+	go s.followMatch(visionCtx)
+	go s.watchStore(visionCtx)
+
+	if s.BackupDir != "" {
+		go s.backupStore(visionCtx)
+	}
+
+	var err error
 	select {
-	case err := <-listenErrs:
-		return err
-	case err := <-visionErrs:
-		httpServer.Shutdown(ctx)
-		return err
+	case err = <-listenErrs:
+	case err = <-visionErrs:
 	case <-ctx.Done():
-		return httpServer.Shutdown(ctx)
 	}
+
+	s.Logger.Info("shutting down")
+
+	// the vision loops use the hardware and captures, so they're stopped first
+	cancelVision()
+	visionLoops.Wait()
+
+	if shutdownErr := s.shutdown(httpServer); err == nil {
+		err = shutdownErr
+	}
+
+	return err
 }
 
 // init attempts to initialize the hardware manager and pipeline manager
 // with configs from the store, and create all network tables entries
 func (s *Server) init() error {
-	err := s.NT.Create(networktables.Entry{
-		Name:  "/gloworm/x",
-		Value: networktables.EntryValue{EntryType: networktables.Double, Double: 0.0},
-	})
-	if err != nil {
-		return fmt.Errorf("unable to create networktables entry: %w", err)
+	// the settings choose the networktables server, so they're applied before creating entries
+	s.initSettings()
+
+	if err := s.createEntries(ntTable); err != nil {
+		return fmt.Errorf("unable to create networktables entries: %w", err)
 	}
 
-	err = s.NT.Create(networktables.Entry{
-		Name:  "/gloworm/y",
-		Value: networktables.EntryValue{EntryType: networktables.Double, Double: 0.0},
-	})
-	if err != nil {
-		return fmt.Errorf("unable to create networktables entry: %w", err)
+	if s.NTLimelight {
+		if err := s.createLimelightEntries(); err != nil {
+			return fmt.Errorf("unable to create limelight networktables entries: %w", err)
+		}
+	}
+
+	if s.NTPhotonCamera != "" {
+		if err := s.createPhotonEntries(s.NTPhotonCamera); err != nil {
+			return fmt.Errorf("unable to create photonvision networktables entries: %w", err)
+		}
 	}
 
 	s.hardwareManager = &hardwareManager{mu: new(sync.RWMutex)}
@@ -123,54 +461,269 @@ func (s *Server) init() error {
 		s.Logger.Warnf("no hardware config found: %s", err)
 	}
 
-	s.pipelineManager = &pipelineManager{mu: new(sync.RWMutex)}
+	s.pipelineManager = &pipelineManager{mu: new(sync.RWMutex), switched: func(name string) {
+		s.changes.publish(change{Kind: changeActive, Name: name})
+	}}
+	s.comparisonManager = &comparisonManager{mu: new(sync.RWMutex)}
 
 	defaultConfig, err := s.Store.DefaultPipelineConfig()
 	if err == nil {
 		config, err := s.Store.PipelineConfig(defaultConfig)
 		if err == nil {
-			s.pipelineManager.pipeline = &pipeline.Pipeline{Config: config}
-		} else {
+			err = s.pipelineManager.SetConfig(defaultConfig, config)
+		}
+		if err != nil {
 			s.Logger.Warnf("unable to setup default pipeline config: %s", err)
 		}
 	} else {
 		s.Logger.Warnf("no default pipeline config found: %s", err)
 	}
 
+	if err := s.initCameras(); err != nil {
+		return fmt.Errorf("unable to initialize cameras: %w", err)
+	}
+
+	if err := s.initUDP(); err != nil {
+		return fmt.Errorf("unable to initialize udp: %w", err)
+	}
+
 	return nil
 }
 
+// runVision captures frames in one goroutine and processes, streams and publishes them in
+// this one, so slow processing or encoding drops frames instead of stalling the camera. If
+// there's more than one worker, frames are processed by them in parallel, and finished here
+// in the order they were captured.
 func (s *Server) runVision(ctx context.Context) error {
-	frameBuffer := gocv.NewMat()
-	defer frameBuffer.Close()
+	queue := newFrameQueue()
+	s.captureMonitor.setQueue(queue)
+	defer queue.close()
 
-	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		default:
-			if s.Capture.Read(&frameBuffer) == false {
-				return errors.New("couldn't read from capture")
+	ctx, cancel := context.WithCancel(ctx)
+	captureErrs := make(chan error, 1)
+	go func() {
+		captureErrs <- s.captureFrames(ctx, queue)
+		close(queue.frames)
+	}()
+
+	// stop capturing if processing fails, closing any frames still waiting
+	defer func() {
+		cancel()
+		for frame := range queue.frames {
+			frame.mat.Close()
+		}
+	}()
+
+	// the index of the active pipeline, which is only needed for limelight and photonvision
+	// compatibility
+	pipelineIndex := -1
+
+	// the pipelines used for the last frame, which are closed once they've been replaced
+	var lastPipeline, lastCandidate *pipeline.Pipeline
+	var lastName string
+	defer func() {
+		closeReplaced(lastPipeline, nil)
+		closeReplaced(lastCandidate, nil)
+	}()
+
+	workers := s.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan *visionJob)
+	var workerGroup sync.WaitGroup
+	if workers > 1 {
+		for i := 0; i < workers; i++ {
+			workerGroup.Add(1)
+			go func() {
+				defer workerGroup.Done()
+				s.runWorker(jobs)
+			}()
+		}
+	}
+
+	// the frames being processed, in the order they were captured
+	var pending []*visionJob
+	defer func() {
+		close(jobs)
+		for _, job := range pending {
+			<-job.done
+			job.frame.mat.Close()
+			for _, snapshot := range job.snapshots {
+				snapshot.raw.Close()
+			}
+		}
+		workerGroup.Wait()
+	}()
+
+	// start handles the raw frame and starts processing it with the active pipeline
+	start := func(frame capturedFrame) *visionJob {
+		frameBuffer, captured, fps := frame.mat, frame.captured, frame.fps
+		job := &visionJob{frame: frame, done: make(chan struct{})}
+		overlays := s.overlays.Overlays()
+		job.layers = overlays.Layers
+
+		s.serveFrameRequests(frameBuffer)
+		job.snapshots = s.acceptSnapshotRequests(frameBuffer)
+		if err := s.views[viewRaw].update(frameBuffer); err != nil {
+			s.Logger.Warnf("unable to encode raw frame: %s", err)
+		}
+		if err := s.recorder.Write(viewRaw, frameBuffer, fps); err != nil {
+			s.Logger.Warnf("unable to record raw frame: %s", err)
+		}
+
+		// the candidate is processed first, since the active pipeline draws onto the frame
+		lastCandidate = closeReplaced(lastCandidate, s.comparisonManager.Pipeline())
+		job.candidate, job.comparing = s.processComparison(lastCandidate, frameBuffer, captured)
+
+		pipeline, name := s.pipelineManager.Pipeline(), s.pipelineManager.Name()
+		if pipeline != lastPipeline && pipeline != nil {
+			if lastPipeline == nil || name != lastName || ledsChanged(lastPipeline.Config, pipeline.Config) {
+				s.applyLEDs(pipeline.Config)
 			}
+			if s.NTLimelight || s.NTPhotonCamera != "" {
+				pipelineIndex = s.pipelineIndex(name)
+			}
+		}
+		lastPipeline, lastName = closeReplaced(lastPipeline, pipeline), name
+		job.pipeline, job.name, job.pipelineIndex = pipeline, name, pipelineIndex
+		if pipeline != nil {
+			pipeline.Layers = &job.layers
+		}
 
-			pipeline := s.pipelineManager.Pipeline()
-			if pipeline != nil {
-				s.Logger.Debug("pipeline processing")
-				point, ok := pipeline.ProcessFrame(frameBuffer, &frameBuffer)
+		if pipeline != nil && workers > 1 && !pipeline.Stateful() {
+			jobs <- job
+			return job
+		}
+
+		if pipeline != nil {
+			s.Logger.Debug("pipeline processing")
+			pipeline.Intermediate = s.updateIntermediate
+			pipeline.Profiling = s.Profiling
+
+			job.result = pipeline.ProcessFrame(frameBuffer, &job.frame.mat, captured)
+		}
+		close(job.done)
 
-				fmt.Println(s.NT.UpdateValue("/gloworm/x", networktables.EntryValue{EntryType: networktables.Double, Double: float64(point.X)}))
-				fmt.Println(s.NT.UpdateValue("/gloworm/y", networktables.EntryValue{EntryType: networktables.Double, Double: float64(point.Y)}))
+		return job
+	}
 
-				s.Logger.Infof("point: %v, ok: %v", point, ok)
+	// finish publishes the frame's result and streams it
+	finish := func(job *visionJob) error {
+		frameBuffer, fps, pipeline := job.frame.mat, job.frame.fps, job.pipeline
+
+		if pipeline != nil {
+			s.frameRates.processedFrame(time.Now())
+			rates := s.frameRates.Rates()
+
+			result := job.result
+			result.Sequence = job.frame.sequence
+			pipeline.DrawStamp(&frameBuffer, result)
+			if job.copied {
+				for _, d := range result.StageDurations {
+					pipeline.Record(d.Stage, d.Duration)
+				}
+			}
+			if job.comparing {
+				job.candidate.Sequence = job.frame.sequence
+				s.comparisonManager.SetResults(result, job.candidate)
+			}
 
+			s.publishResult(ntTable, result)
+			if s.NTLimelight {
+				s.publishLimelight(result, job.pipelineIndex)
+			}
+			if s.NTPhotonCamera != "" {
+				s.publishPhoton(s.NTPhotonCamera, pipeline.Config, result, job.pipelineIndex)
 			}
+			msg := resultMessage{
+				Result:      result,
+				Pipeline:    job.name,
+				TargetFound: len(result.Targets) > 0,
+				FPS:         rates.Processed,
+				FrameRates:  &rates,
+			}
+			s.results.publish(msg)
+			s.sendUDP(msg)
 
-			buf, err := gocv.IMEncode(".jpg", frameBuffer)
-			if err != nil {
-				return fmt.Errorf("encode original frame buffer: %w", err)
+			if err := s.hardwareManager.SetStatus(hardware.TargetAquired, len(result.Targets) > 0); err != nil {
+				s.Logger.Warnf("unable to set target status: %s", err)
 			}
 
-			s.stream.UpdateJPEG(buf)
+			s.Logger.Debugf("result: %v", result)
+		}
+
+		completeSnapshotRequests(job.snapshots, frameBuffer)
+		if err := s.recorder.Write(viewProcessed, frameBuffer, fps); err != nil {
+			s.Logger.Warnf("unable to record processed frame: %s", err)
 		}
+
+		if s.overlays.Overlays().FPS {
+			drawFrameRates(&frameBuffer, s.frameRates.Rates())
+		}
+
+		quality := s.settingsManager.Settings().Stream.Quality
+		for name, variant := range s.variants {
+			if err := variant.update(frameBuffer, quality); err != nil {
+				s.Logger.WithField("variant", name).Warnf("unable to encode stream variant: %s", err)
+			}
+		}
+
+		encodeStart := time.Now()
+		buf, err := encodeJPEG(frameBuffer, quality)
+		if err != nil {
+			frameBuffer.Close()
+			return fmt.Errorf("encode original frame buffer: %w", err)
+		}
+		if pipeline != nil {
+			pipeline.Record("encode", time.Since(encodeStart))
+		}
+
+		s.stream.UpdateJPEG(buf)
+		s.frameRates.streamed(time.Now())
+		queue.put(frameBuffer)
+
+		return nil
 	}
+
+	for {
+		// frames are only taken from the queue while a worker is free, so stale frames are
+		// dropped by the queue rather than waiting here
+		var frames <-chan capturedFrame
+		if len(pending) < workers {
+			frames = queue.frames
+		}
+
+		var done <-chan struct{}
+		if len(pending) > 0 {
+			done = pending[0].done
+		}
+
+		select {
+		case frame, ok := <-frames:
+			if !ok {
+				return <-captureErrs
+			}
+
+			pending = append(pending, start(frame))
+		case <-done:
+			job := pending[0]
+			pending = pending[1:]
+
+			if err := finish(job); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// closeReplaced closes the last pipeline if it has been replaced by next, and returns next.
+// Only the vision loop processes frames, so it's the only place pipelines can be closed safely.
+func closeReplaced(last, next *pipeline.Pipeline) *pipeline.Pipeline {
+	if last != nil && last != next {
+		last.Close()
+	}
+
+	return next
 }
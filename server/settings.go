@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/gloworm-vision/gloworm-app/store"
+	"github.com/sirupsen/logrus"
+)
+
+// settingsManager synchronizes access to the device settings.
+type settingsManager struct {
+	settings store.Settings
+	mu       *sync.RWMutex
+}
+
+func (m *settingsManager) Settings() store.Settings {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.settings
+}
+
+func (m *settingsManager) SetSettings(settings store.Settings) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.settings = settings
+}
+
+// initSettings loads the settings from the store and applies the logging settings and the
+// networktables settings, unless the client's address, identity or logger were set explicitly.
+func (s *Server) initSettings() {
+	s.settingsManager = &settingsManager{mu: new(sync.RWMutex)}
+	if s.NT.Logger == nil {
+		s.NT.Logger = s.Logger
+	}
+
+	settings, err := s.Store.Settings()
+	if err != nil {
+		s.Logger.Warnf("unable to get settings: %s", err)
+		return
+	}
+	s.settingsManager.SetSettings(settings)
+
+	// the logger is left as it was configured unless logging settings were put
+	if settings.Logging != (store.LoggingSettings{}) {
+		s.applyLogging(settings.Logging)
+	}
+
+	if s.NT.Addr == "" {
+		s.NT.Addr = settings.NTServerAddr()
+	}
+	if s.NT.Identity == "" {
+		s.NT.Identity = settings.Nickname
+	}
+}
+
+func (s *Server) getSettings(res http.ResponseWriter, req *http.Request) {
+	respond(res, s.settingsManager.Settings(), http.StatusOK)
+}
+
+// putSettings replaces the settings. The networktables settings are only applied when the
+// server restarts, since the client can't change servers while it's in use. The hostname is
+// set if it changed and the server manages the network.
+func (s *Server) putSettings(res http.ResponseWriter, req *http.Request) {
+	var settings store.Settings
+	if err := json.NewDecoder(req.Body).Decode(&settings); err != nil {
+		respond(res, err, http.StatusBadRequest)
+		return
+	}
+
+	if err := settings.Validate(); err != nil {
+		respond(res, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err := s.validateMatchSettings(settings.Match); err != nil {
+		respond(res, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	previous := s.settingsManager.Settings()
+	if s.Network != nil && settings.Hostname != "" && settings.Hostname != previous.Hostname {
+		ctx, cancel := context.WithTimeout(context.Background(), networkApplyTimeout)
+		defer cancel()
+
+		if err := s.Network.SetHostname(ctx, settings.Hostname); err != nil {
+			respond(res, err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := s.Store.PutSettings(settings); err != nil {
+		respond(res, err, statusCode(err))
+		return
+	}
+
+	s.settingsManager.SetSettings(settings)
+	s.applyLogging(settings.Logging)
+
+	respond(res, nil, http.StatusNoContent)
+}
+
+// applyLogging sets the level and format of the server's logger, which the vision loops,
+// networktables client and HTTP server all log to.
+func (s *Server) applyLogging(logging store.LoggingSettings) {
+	level := logrus.InfoLevel
+	if logging.Level != "" {
+		// the level was validated when the settings were put
+		level, _ = logrus.ParseLevel(logging.Level)
+	}
+	s.Logger.SetLevel(level)
+
+	if logging.JSON {
+		s.Logger.SetFormatter(&logrus.JSONFormatter{})
+	} else {
+		s.Logger.SetFormatter(&logrus.TextFormatter{})
+	}
+}
+
+func (s *Server) getLogging(res http.ResponseWriter, req *http.Request) {
+	respond(res, s.settingsManager.Settings().Logging, http.StatusOK)
+}
+
+// putLogging replaces just the logging settings.
+func (s *Server) putLogging(res http.ResponseWriter, req *http.Request) {
+	var logging store.LoggingSettings
+	if err := json.NewDecoder(req.Body).Decode(&logging); err != nil {
+		respond(res, err, http.StatusBadRequest)
+		return
+	}
+
+	settings := s.settingsManager.Settings()
+	settings.Logging = logging
+	if err := settings.Validate(); err != nil {
+		respond(res, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err := s.Store.PutSettings(settings); err != nil {
+		respond(res, err, statusCode(err))
+		return
+	}
+
+	s.settingsManager.SetSettings(settings)
+	s.applyLogging(logging)
+
+	respond(res, nil, http.StatusNoContent)
+}
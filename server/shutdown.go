@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// shutdownTimeout is how long in-flight requests have to finish when the server shuts down.
+const shutdownTimeout = time.Second * 5
+
+// shutdown stops the server in order, once the vision loops have stopped: the hardware
+// lights are turned off, recording is stopped, the captures, UDP connection and store are
+// closed, and finally the HTTP servers are shut down, waiting up to shutdownTimeout for
+// in-flight requests. Errors are logged, and the first is returned.
+func (s *Server) shutdown(httpServer *http.Server) error {
+	var first error
+	fail := func(err error) {
+		s.Logger.Warnf("unable to shut down cleanly: %s", err)
+		if first == nil {
+			first = err
+		}
+	}
+
+	if err := s.hardwareManager.Close(); err != nil {
+		fail(err)
+	}
+
+	// segments are unplayable unless they're closed
+	s.recorder.Stop()
+
+	if err := s.Capture.Close(); err != nil {
+		fail(fmt.Errorf("unable to close capture: %w", err))
+	}
+	for id, cam := range s.cameras {
+		if err := cam.capture.Close(); err != nil {
+			fail(fmt.Errorf("unable to close camera %q: %w", id, err))
+		}
+	}
+
+	if s.udp != nil {
+		if err := s.udp.Close(); err != nil {
+			fail(fmt.Errorf("unable to close udp connection: %w", err))
+		}
+	}
+
+	if err := s.Store.Close(); err != nil {
+		fail(fmt.Errorf("unable to close store: %w", err))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := httpServer.Shutdown(ctx); err != nil {
+		fail(fmt.Errorf("unable to shut down http server: %w", err))
+	}
+
+	if s.redirectServer != nil {
+		if err := s.redirectServer.Shutdown(ctx); err != nil {
+			fail(fmt.Errorf("unable to shut down redirect server: %w", err))
+		}
+	}
+
+	return first
+}
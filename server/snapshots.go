@@ -0,0 +1,200 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"gocv.io/x/gocv"
+)
+
+// snapshotFormats are the image formats snapshots can be saved in, by file extension.
+var snapshotFormats = map[string]bool{"jpg": true, "png": true}
+
+// snapshot is a raw frame and the same frame after it was processed by the active pipeline.
+type snapshot struct {
+	raw, processed gocv.Mat
+}
+
+// pendingSnapshot is a snapshot request accepted by the vision loop, which has the raw
+// frame but is waiting on the processed frame.
+type pendingSnapshot struct {
+	raw   gocv.Mat
+	reply chan snapshot
+}
+
+// grabSnapshot returns copies of the next raw and processed frames from the vision loop.
+// The caller must close both mats.
+func (s *Server) grabSnapshot(ctx context.Context) (snapshot, error) {
+	reply := make(chan snapshot, 1)
+
+	ctx, cancel := context.WithTimeout(ctx, frameTimeout)
+	defer cancel()
+
+	select {
+	case s.snapshotRequests <- reply:
+	case <-ctx.Done():
+		return snapshot{}, fmt.Errorf("vision loop isn't reading frames: %w", ctx.Err())
+	}
+
+	select {
+	case snap := <-reply:
+		if snap.raw.Empty() {
+			snap.raw.Close()
+			snap.processed.Close()
+			return snapshot{}, errors.New("captured frame is empty")
+		}
+
+		return snap, nil
+	case <-ctx.Done():
+		// the vision loop accepted the request, so make sure the frames it replies with
+		// get closed
+		go func() {
+			snap := <-reply
+			snap.raw.Close()
+			snap.processed.Close()
+		}()
+
+		return snapshot{}, fmt.Errorf("timed out waiting for frame: %w", ctx.Err())
+	}
+}
+
+// acceptSnapshotRequests accepts any pending grabSnapshot calls with a copy of the raw
+// frame. They must be completed once the frame is processed.
+func (s *Server) acceptSnapshotRequests(frame gocv.Mat) []pendingSnapshot {
+	var pending []pendingSnapshot
+	for {
+		select {
+		case reply := <-s.snapshotRequests:
+			pending = append(pending, pendingSnapshot{raw: frame.Clone(), reply: reply})
+		default:
+			return pending
+		}
+	}
+}
+
+// completeSnapshotRequests replies to accepted grabSnapshot calls with a copy of the
+// processed frame.
+func completeSnapshotRequests(pending []pendingSnapshot, frame gocv.Mat) {
+	for _, p := range pending {
+		p.reply <- snapshot{raw: p.raw, processed: frame.Clone()}
+	}
+}
+
+type snapshotResponse struct {
+	Raw       string `json:"raw"`
+	Processed string `json:"processed"`
+}
+
+// postSnapshot saves the next raw and processed frames to the snapshot directory, as JPEG
+// images unless the format query parameter is png.
+func (s *Server) postSnapshot(res http.ResponseWriter, req *http.Request) {
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = "jpg"
+	}
+	if !snapshotFormats[format] {
+		respond(res, fmt.Errorf("unsupported snapshot format %q", format), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err := os.MkdirAll(s.SnapshotDir, 0755); err != nil {
+		respond(res, fmt.Errorf("unable to create snapshot directory: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	snap, err := s.grabSnapshot(req.Context())
+	if err != nil {
+		respond(res, err, http.StatusServiceUnavailable)
+		return
+	}
+	defer snap.raw.Close()
+	defer snap.processed.Close()
+
+	prefix := time.Now().Format("20060102-150405.000")
+	resp := snapshotResponse{
+		Raw:       prefix + "-raw." + format,
+		Processed: prefix + "-processed." + format,
+	}
+
+	if !gocv.IMWrite(filepath.Join(s.SnapshotDir, resp.Raw), snap.raw) ||
+		!gocv.IMWrite(filepath.Join(s.SnapshotDir, resp.Processed), snap.processed) {
+		respond(res, errors.New("unable to write snapshot"), http.StatusInternalServerError)
+		return
+	}
+
+	evicted, err := s.snapshots.Evict(resp.Raw, resp.Processed)
+	if err != nil {
+		s.requestLogger(req).Warnf("unable to evict old snapshots: %s", err)
+	}
+	if len(evicted) > 0 {
+		s.requestLogger(req).WithField("snapshots", evicted).Info("evicted old snapshots")
+	}
+
+	respond(res, resp, http.StatusCreated)
+}
+
+// listSnapshots lists the saved snapshots and their tags, only those with the tag query
+// parameter if it's set.
+func (s *Server) listSnapshots(res http.ResponseWriter, req *http.Request) {
+	snapshots, err := s.snapshots.List(req.URL.Query().Get("tag"))
+	if err != nil {
+		respond(res, err, http.StatusInternalServerError)
+		return
+	}
+
+	respond(res, snapshots, http.StatusOK)
+}
+
+// putSnapshotTags replaces the named snapshot's tags with the list of tags in the body.
+func (s *Server) putSnapshotTags(res http.ResponseWriter, req *http.Request) {
+	params := httprouter.ParamsFromContext(req.Context())
+
+	var tags []string
+	if err := json.NewDecoder(req.Body).Decode(&tags); err != nil {
+		respond(res, err, http.StatusBadRequest)
+		return
+	}
+
+	tags, err := s.snapshots.SetTags(params.ByName("name"), tags)
+	if err != nil {
+		respond(res, err, snapshotStatusCode(err))
+		return
+	}
+
+	respond(res, tags, http.StatusOK)
+}
+
+func (s *Server) deleteSnapshot(res http.ResponseWriter, req *http.Request) {
+	params := httprouter.ParamsFromContext(req.Context())
+
+	if err := s.snapshots.Delete(params.ByName("name")); err != nil {
+		respond(res, err, snapshotStatusCode(err))
+		return
+	}
+
+	respond(res, nil, http.StatusNoContent)
+}
+
+// snapshotStatusCode returns the status code to respond to a snapshot request that failed
+// with the error.
+func snapshotStatusCode(err error) int {
+	if errors.Is(err, errNoSnapshot) {
+		return http.StatusNotFound
+	}
+
+	return http.StatusInternalServerError
+}
+
+// getSnapshot downloads the named snapshot image.
+func (s *Server) getSnapshot(res http.ResponseWriter, req *http.Request) {
+	params := httprouter.ParamsFromContext(req.Context())
+
+	serveSavedFile(res, req, s.SnapshotDir, params.ByName("name"), snapshotFormats)
+}
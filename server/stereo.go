@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gloworm-vision/gloworm-app/pipeline"
+	"gocv.io/x/gocv"
+)
+
+// StereoPair names two of the additional cameras that are read together as a stereo pair.
+type StereoPair struct {
+	Left, Right string
+}
+
+// stereoCameras returns the left and right cameras of StereoCameras, returning an error if
+// they aren't two different additional cameras.
+func (s *Server) stereoCameras() (*camera, *camera, error) {
+	pair := s.StereoCameras
+	if pair.Left == pair.Right {
+		return nil, nil, fmt.Errorf("stereo cameras must be different, not both %q", pair.Left)
+	}
+
+	left, ok := s.cameras[pair.Left]
+	if !ok {
+		return nil, nil, fmt.Errorf("no left stereo camera %q", pair.Left)
+	}
+
+	right, ok := s.cameras[pair.Right]
+	if !ok {
+		return nil, nil, fmt.Errorf("no right stereo camera %q", pair.Right)
+	}
+
+	return left, right, nil
+}
+
+// runStereo runs the vision loop of a stereo pair of cameras, in place of their own. Frames
+// are processed by a stereo pipeline of the left camera's pipeline config, and the result is
+// published as the left camera's. If the config has no stereo calibration, only the left
+// frame is processed, without triangulating positions.
+func (s *Server) runStereo(ctx context.Context, left, right *camera) error {
+	leftFrame, rightFrame := gocv.NewMat(), gocv.NewMat()
+	defer leftFrame.Close()
+	defer rightFrame.Close()
+
+	var rate frameRate
+	var sequence uint64
+
+	var lastLeft, lastRight *pipeline.Pipeline
+	var stereo *pipeline.StereoPipeline
+	defer func() {
+		closeReplaced(lastLeft, nil)
+		closeReplaced(lastRight, nil)
+		if stereo != nil {
+			stereo.Close()
+		}
+	}()
+
+	pipelineIndex := -1
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+			if err := s.readStereo(ctx, left, right, &leftFrame, &rightFrame); err != nil {
+				// the pair is given up on rather than stopping the other cameras
+				<-ctx.Done()
+				return nil
+			}
+			captured := time.Now()
+			fps := rate.tick(captured)
+			sequence++
+
+			p := left.pipelineManager.Pipeline()
+			if p != lastLeft && p != nil {
+				if s.NTPhotonCamera != "" {
+					pipelineIndex = s.pipelineIndex(left.pipelineManager.Name())
+				}
+
+				if p.Config.Camera != nil {
+					for _, cam := range []*camera{left, right} {
+						if !p.Config.Camera.Apply(cam.capture) {
+							s.Logger.WithField("camera", cam.id).Warn(errCaptureNotSettable)
+						}
+					}
+				}
+
+				if stereo != nil {
+					stereo.Close()
+					stereo = nil
+				}
+
+				if p.Config.Stereo != nil {
+					var err error
+					if stereo, err = pipeline.NewStereo(p.Config); err != nil {
+						s.Logger.WithField("camera", left.id).Warnf("unable to create stereo pipeline: %s", err)
+					}
+				}
+			}
+			lastLeft = closeReplaced(lastLeft, p)
+
+			// the right camera's pipeline isn't used while it's paired, but it's still replaced
+			lastRight = closeReplaced(lastRight, right.pipelineManager.Pipeline())
+
+			if p != nil {
+				var result pipeline.Result
+				if stereo != nil {
+					result = stereo.ProcessFrames(leftFrame, rightFrame, &leftFrame, &rightFrame, captured)
+				} else {
+					result = p.ProcessFrame(leftFrame, &leftFrame, captured)
+				}
+				result.Sequence = sequence
+				p.DrawStamp(&leftFrame, result)
+
+				s.publishResult(cameraTable(left.id), result)
+				if s.NTPhotonCamera != "" {
+					s.publishPhoton(left.id, p.Config, result, pipelineIndex)
+				}
+				left.results.publish(resultMessage{
+					Result:      result,
+					Pipeline:    left.pipelineManager.Name(),
+					TargetFound: len(result.Targets) > 0,
+					FPS:         fps,
+				})
+			}
+
+			for _, frame := range []struct {
+				cam   *camera
+				frame gocv.Mat
+			}{{left, leftFrame}, {right, rightFrame}} {
+				buf, err := gocv.IMEncode(".jpg", frame.frame)
+				if err != nil {
+					return fmt.Errorf("encode camera %q frame buffer: %w", frame.cam.id, err)
+				}
+
+				frame.cam.stream.UpdateJPEG(buf)
+			}
+		}
+	}
+}
+
+// readStereo reads the next frame from both cameras of a stereo pair at the same time, so
+// they're captured as close together as possible. A camera that can't be read is reconnected,
+// and both are read again.
+func (s *Server) readStereo(ctx context.Context, left, right *camera, leftFrame, rightFrame *gocv.Mat) error {
+	for {
+		var leftOK, rightOK bool
+
+		var reads sync.WaitGroup
+		reads.Add(2)
+		go func() {
+			defer reads.Done()
+			leftOK = left.capture.Read(leftFrame)
+		}()
+		go func() {
+			defer reads.Done()
+			rightOK = right.capture.Read(rightFrame)
+		}()
+		reads.Wait()
+
+		if leftOK && rightOK {
+			return nil
+		}
+
+		if !leftOK {
+			if err := s.reconnectCamera(ctx, left); err != nil {
+				return err
+			}
+		}
+
+		if !rightOK {
+			if err := s.reconnectCamera(ctx, right); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gloworm-vision/gloworm-app/pipeline"
+	"github.com/hybridgroup/mjpeg"
+	"github.com/julienschmidt/httprouter"
+	"github.com/sirupsen/logrus"
+	"gocv.io/x/gocv"
+)
+
+// intermediateStages are the pipeline stages whose output is streamed when debug streams
+// are enabled.
+var intermediateStages = []string{pipeline.StageConvert, pipeline.StageThreshold, pipeline.StageMorph}
+
+// Views of the main stream, selected with the view query parameter of /stream.
+const (
+	// viewProcessed is the frame annotated by the active pipeline, and the default view.
+	viewProcessed = "processed"
+
+	// viewRaw is the untouched camera frame.
+	viewRaw = "raw"
+
+	// viewThreshold is the binary mask output by the threshold stage.
+	viewThreshold = "threshold"
+)
+
+// Variants of the main stream at other resolutions, served at /stream/:name.
+const (
+	// variantLow is the processed frame scaled down to fit lowVariantSize, for dashboards
+	// on slow links.
+	variantLow = "low"
+
+	// variantHigh is the processed frame at the capture resolution, which is the main stream.
+	variantHigh = "high"
+)
+
+// slowStreamWrite is the longest a frame can take to write to a stream client before the
+// client is considered stalled and disconnected.
+const slowStreamWrite = time.Second * 5
+
+var (
+	// errTooManyStreamClients is responded with when MaxStreamClients are already watching.
+	errTooManyStreamClients = errors.New("too many stream clients")
+
+	// errSlowStreamClient ends the stream for a client that stalled.
+	errSlowStreamClient = errors.New("stream client is too slow")
+)
+
+// lowVariantSize is the size the low variant is scaled to fit within.
+var lowVariantSize = image.Pt(320, 240)
+
+// viewStream is a stream that's only updated while it has clients, since encoding every
+// frame of views nobody is watching would slow down the vision loop.
+type viewStream struct {
+	*mjpeg.Stream
+
+	clients int32
+}
+
+func newViewStream() *viewStream {
+	return &viewStream{Stream: mjpeg.NewStream()}
+}
+
+func (v *viewStream) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	atomic.AddInt32(&v.clients, 1)
+	defer atomic.AddInt32(&v.clients, -1)
+
+	v.Stream.ServeHTTP(res, req)
+}
+
+// watched returns whether the stream has any clients.
+func (v *viewStream) watched() bool {
+	return atomic.LoadInt32(&v.clients) > 0
+}
+
+// update encodes the frame to the stream if it's being watched.
+func (v *viewStream) update(mat gocv.Mat) error {
+	if !v.watched() || mat.Empty() {
+		return nil
+	}
+
+	buf, err := gocv.IMEncode(".jpg", mat)
+	if err != nil {
+		return err
+	}
+
+	v.UpdateJPEG(buf)
+	return nil
+}
+
+// scaledStream is a view of the main stream scaled down to fit within a size. Like other
+// views, it's only scaled and encoded while it has clients, and then only once per frame
+// however many clients it has.
+type scaledStream struct {
+	*viewStream
+
+	size image.Point
+}
+
+// update scales the frame and encodes it to the stream if it's being watched.
+func (v *scaledStream) update(frame gocv.Mat, quality int) error {
+	if !v.watched() || frame.Empty() {
+		return nil
+	}
+
+	// the frame is scaled to fit, keeping its aspect ratio
+	scale := math.Min(float64(v.size.X)/float64(frame.Cols()), float64(v.size.Y)/float64(frame.Rows()))
+	if scale > 1 {
+		scale = 1
+	}
+
+	scaled := gocv.NewMat()
+	defer scaled.Close()
+	gocv.Resize(frame, &scaled, image.Point{}, scale, scale, gocv.InterpolationArea)
+
+	buf, err := encodeJPEG(scaled, quality)
+	if err != nil {
+		return err
+	}
+
+	v.UpdateJPEG(buf)
+	return nil
+}
+
+// initStreams creates the main, view, variant, comparison and replay streams and, if enabled, the
+// intermediate stage streams.
+func (s *Server) initStreams() {
+	s.stream = mjpeg.NewStream()
+	s.views = map[string]*viewStream{viewRaw: newViewStream(), viewThreshold: newViewStream()}
+	s.variants = map[string]*scaledStream{variantLow: {viewStream: newViewStream(), size: lowVariantSize}}
+
+	s.streams = map[string]*mjpeg.Stream{comparisonStream: mjpeg.NewStream(), replayStream: mjpeg.NewStream()}
+	if s.DebugStreams {
+		for _, stage := range intermediateStages {
+			s.streams[stage] = mjpeg.NewStream()
+		}
+	}
+}
+
+// updateIntermediate encodes a pipeline's intermediate output to its stream, if the
+// stage has one.
+func (s *Server) updateIntermediate(stage string, mat gocv.Mat) {
+	if stage == pipeline.StageThreshold {
+		if err := s.views[viewThreshold].update(mat); err != nil {
+			s.Logger.Warnf("unable to encode threshold frame: %s", err)
+		}
+	}
+
+	stream, ok := s.streams[stage]
+	if !ok || mat.Empty() {
+		return
+	}
+
+	buf, err := gocv.IMEncode(".jpg", mat)
+	if err != nil {
+		s.Logger.WithField("stage", stage).Warnf("unable to encode intermediate frame: %s", err)
+		return
+	}
+
+	stream.UpdateJPEG(buf)
+}
+
+// throttledWriter drops writes that come sooner than the interval after the last write it
+// let through. The mjpeg stream writes each frame with a single write, so this drops frames
+// for one client without affecting the others.
+type throttledWriter struct {
+	http.ResponseWriter
+
+	interval time.Duration
+	last     time.Time
+}
+
+func (t *throttledWriter) Write(b []byte) (int, error) {
+	now := time.Now()
+	if now.Sub(t.last) < t.interval {
+		return len(b), nil
+	}
+	t.last = now
+
+	return t.ResponseWriter.Write(b)
+}
+
+// connKey is the context key of the connection a request was received on.
+type connKey struct{}
+
+// withConn adds the connection to the context of the requests received on it, so a stalled
+// stream client's connection can be closed while a write to it is blocked.
+func withConn(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, conn)
+}
+
+// streamClient is a client watching a stream. It's disconnected if a frame takes longer than
+// slowStreamWrite to write, since a stalled client would otherwise hold its place in the
+// client limit until its connection times out.
+type streamClient struct {
+	http.ResponseWriter
+
+	// conn is the client's connection, which is closed if it stalls. It's nil if the server
+	// wasn't created with withConn.
+	conn net.Conn
+
+	// release gives up the client's place in the client limit.
+	release func()
+	logger  *logrus.Entry
+
+	slow int32
+}
+
+func (c *streamClient) Write(b []byte) (int, error) {
+	if atomic.LoadInt32(&c.slow) == 1 {
+		return 0, errSlowStreamClient
+	}
+
+	timer := time.AfterFunc(slowStreamWrite, c.stalled)
+	n, err := c.ResponseWriter.Write(b)
+	timer.Stop()
+
+	if err == nil && atomic.LoadInt32(&c.slow) == 1 {
+		err = errSlowStreamClient
+	}
+
+	return n, err
+}
+
+// stalled marks the client as slow and closes its connection, so its blocked write returns
+// and the stream ends, and frees its place for another client straight away.
+func (c *streamClient) stalled() {
+	if atomic.CompareAndSwapInt32(&c.slow, 0, 1) {
+		c.logger.Info("stream client stalled, disconnecting it")
+		c.release()
+
+		if c.conn != nil {
+			if err := c.conn.Close(); err != nil {
+				c.logger.Debugf("unable to close stalled stream client connection: %s", err)
+			}
+		}
+	}
+}
+
+// encodeJPEG encodes the frame as a JPEG with the quality from 1 to 100, or OpenCV's default
+// quality if it's 0.
+func encodeJPEG(mat gocv.Mat, quality int) ([]byte, error) {
+	if quality == 0 {
+		return gocv.IMEncode(".jpg", mat)
+	}
+
+	return gocv.IMEncodeWithParams(".jpg", mat, []int{int(gocv.IMWriteJpegQuality), quality})
+}
+
+// serveStream serves the stream, limited to the frame rate in the fps query parameter if
+// it's set, or otherwise the default from the stream settings. Clients beyond
+// MaxStreamClients are rejected, and stalled clients are disconnected.
+func (s *Server) serveStream(res http.ResponseWriter, req *http.Request, stream http.Handler) {
+	fps := s.settingsManager.Settings().Stream.FPS
+	if raw := req.URL.Query().Get("fps"); raw != "" {
+		var err error
+		fps, err = strconv.ParseFloat(raw, 64)
+		if err != nil || fps <= 0 {
+			respond(res, fmt.Errorf("invalid fps %q", raw), http.StatusBadRequest)
+			return
+		}
+	}
+
+	release, ok := s.acquireStreamClient()
+	if !ok {
+		respond(res, errTooManyStreamClients, http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+
+	conn, _ := req.Context().Value(connKey{}).(net.Conn)
+
+	var w http.ResponseWriter = &streamClient{ResponseWriter: res, conn: conn, release: release, logger: s.requestLogger(req)}
+	if fps != 0 {
+		w = &throttledWriter{ResponseWriter: w, interval: time.Duration(float64(time.Second) / fps)}
+	}
+
+	stream.ServeHTTP(w, req)
+}
+
+// acquireStreamClient takes a place for a client to watch a stream, returning false if
+// MaxStreamClients are already watching. The returned function gives the place up, and can be
+// called more than once.
+func (s *Server) acquireStreamClient() (func(), bool) {
+	clients := atomic.AddInt32(&s.streamClients, 1)
+	if s.MaxStreamClients > 0 && int(clients) > s.MaxStreamClients {
+		atomic.AddInt32(&s.streamClients, -1)
+		return nil, false
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { atomic.AddInt32(&s.streamClients, -1) })
+	}, true
+}
+
+// getMainStream serves the main stream, or the view of it selected by the view query
+// parameter.
+func (s *Server) getMainStream(res http.ResponseWriter, req *http.Request) {
+	view := req.URL.Query().Get("view")
+	if view == "" || view == viewProcessed {
+		s.serveStream(res, req, s.stream)
+		return
+	}
+
+	stream, ok := s.views[view]
+	if !ok {
+		respond(res, fmt.Errorf("unknown view %q", view), http.StatusBadRequest)
+		return
+	}
+
+	s.serveStream(res, req, stream)
+}
+
+// getStream serves the named stream or variant of the main stream.
+func (s *Server) getStream(res http.ResponseWriter, req *http.Request) {
+	params := httprouter.ParamsFromContext(req.Context())
+	name := params.ByName("name")
+
+	if name == variantHigh {
+		s.serveStream(res, req, s.stream)
+		return
+	}
+
+	if variant, ok := s.variants[name]; ok {
+		s.serveStream(res, req, variant)
+		return
+	}
+
+	stream, ok := s.streams[name]
+	if !ok {
+		respond(res, fmt.Errorf("no stream %q", name), http.StatusNotFound)
+		return
+	}
+
+	s.serveStream(res, req, stream)
+}
+
+// drawFrameRates draws the frame rates onto the frame, below the pipeline's stamp.
+func drawFrameRates(frame *gocv.Mat, rates FrameRates) {
+	text := fmt.Sprintf("capture %.1f processed %.1f stream %.1f fps", rates.Capture, rates.Processed, rates.Stream)
+	gocv.PutText(frame, text, image.Pt(5, 30), gocv.FontHersheyPlain, 1, color.RGBA{R: 255, G: 255, B: 255, A: 255}, 1)
+}
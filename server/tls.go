@@ -0,0 +1,158 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// selfSignedValidity is how long generated self-signed certificates are valid for.
+const selfSignedValidity = 10 * 365 * 24 * time.Hour
+
+// tlsEnabled returns whether the API is served over HTTPS.
+func (s *Server) tlsEnabled() bool {
+	return s.TLSCert != "" && s.TLSKey != ""
+}
+
+// initTLS generates a self-signed certificate at TLSCert and TLSKey if SelfSignedTLS is set and
+// they don't exist yet. The certificate is saved so browsers only have to trust it once.
+func (s *Server) initTLS() error {
+	if !s.tlsEnabled() || !s.SelfSignedTLS {
+		return nil
+	}
+
+	if _, err := os.Stat(s.TLSCert); err == nil {
+		return nil
+	}
+
+	cert, key, err := generateCertificate(certificateHosts())
+	if err != nil {
+		return fmt.Errorf("unable to generate self-signed certificate: %w", err)
+	}
+
+	if err := ioutil.WriteFile(s.TLSKey, key, 0600); err != nil {
+		return fmt.Errorf("unable to write key: %w", err)
+	}
+	if err := ioutil.WriteFile(s.TLSCert, cert, 0644); err != nil {
+		return fmt.Errorf("unable to write certificate: %w", err)
+	}
+
+	s.Logger.WithField("cert", s.TLSCert).Info("generated self-signed certificate")
+
+	return nil
+}
+
+// certificateHosts returns the hostnames and addresses the device can be reached at, which
+// self-signed certificates are valid for.
+func certificateHosts() []string {
+	hosts := []string{"localhost"}
+	if hostname, err := os.Hostname(); err == nil {
+		hosts = append(hosts, hostname, hostname+".local")
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return hosts
+	}
+
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			hosts = append(hosts, ipNet.IP.String())
+		}
+	}
+
+	return hosts
+}
+
+// generateCertificate generates a self-signed certificate for the hosts, returning the
+// PEM encoded certificate and private key.
+func generateCertificate(hosts []string) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to generate key: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to generate serial number: %w", err)
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"gloworm"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to create certificate: %w", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to marshal key: %w", err)
+	}
+
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	return cert, keyPEM, nil
+}
+
+// newRedirectServer returns a server that redirects plain HTTP requests on HTTPRedirectAddr
+// to the same URL over HTTPS.
+func (s *Server) newRedirectServer() *http.Server {
+	_, port, _ := net.SplitHostPort(s.Addr)
+
+	return &http.Server{
+		Addr:              s.HTTPRedirectAddr,
+		ReadHeaderTimeout: time.Second * 15,
+		Handler: http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			host := req.Host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			if port != "" && port != "443" {
+				host = net.JoinHostPort(host, port)
+			}
+
+			http.Redirect(res, req, "https://"+host+req.URL.RequestURI(), http.StatusMovedPermanently)
+		}),
+	}
+}
+
+// listen serves the API over HTTPS if it's enabled, and HTTP otherwise.
+func (s *Server) listen(httpServer *http.Server) error {
+	if !s.tlsEnabled() {
+		s.Logger.WithField("addr", s.Addr).Info("serving http")
+		return httpServer.ListenAndServe()
+	}
+
+	httpServer.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+
+	s.Logger.WithField("addr", s.Addr).Info("serving https")
+	return httpServer.ListenAndServeTLS(s.TLSCert, s.TLSKey)
+}
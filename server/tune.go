@@ -0,0 +1,290 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gloworm-vision/gloworm-app/pipeline"
+)
+
+// Types of tuning websocket messages. Clients send patch, commit and revert messages, and the
+// server replies with config, applied, committed and error messages.
+const (
+	// tunePatch merges a partial config into the tuned config and applies it to the active
+	// pipeline, without saving it.
+	tunePatch = "patch"
+
+	// tuneCommit saves the tuned config to the store.
+	tuneCommit = "commit"
+
+	// tuneRevert discards unsaved changes, reapplying the saved config.
+	tuneRevert = "revert"
+
+	// tuneConfig is the whole tuned config, sent when the session starts and when it's
+	// reverted or reset because the active pipeline changed.
+	tuneConfig = "config"
+
+	tuneApplied   = "applied"
+	tuneCommitted = "committed"
+	tuneError     = "error"
+)
+
+// tuneMessage is a message on the tuning websocket.
+type tuneMessage struct {
+	Type     string          `json:"type"`
+	Pipeline string          `json:"pipeline,omitempty"`
+	Config   json.RawMessage `json:"config,omitempty"`
+
+	Error  string                `json:"error,omitempty"`
+	Fields []pipeline.FieldError `json:"fields,omitempty"`
+}
+
+// tuneSession is the state of a tuning websocket: the active pipeline's name and its config
+// with the changes made so far.
+type tuneSession struct {
+	name   string
+	config pipeline.Config
+}
+
+// tuneWebsocket lets a client tune the active pipeline live, applying each change to it
+// without saving until the client commits. It's a GET request, so it's authorized here
+// rather than by requireAuth.
+func (s *Server) tuneWebsocket(res http.ResponseWriter, req *http.Request) {
+	if !s.allowedUpgrade(req) {
+		respond(res, errors.New("origin not allowed"), http.StatusForbidden)
+		return
+	}
+
+	if !s.Auth.authorizedUpgrade(req) {
+		respond(res, errUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	session, err := s.activeTuneSession()
+	if err != nil {
+		respond(res, err, http.StatusConflict)
+		return
+	}
+
+	conn, err := upgradeWebsocket(res, req)
+	if err != nil {
+		s.requestLogger(req).Debugf("unable to upgrade tuning websocket: %s", err)
+		return
+	}
+	defer conn.Close()
+
+	send := func(msg tuneMessage) bool {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			s.requestLogger(req).Warnf("unable to encode tuning message: %s", err)
+			return true
+		}
+
+		return conn.WriteText(data) == nil
+	}
+
+	sendConfig := func() bool {
+		config, err := json.Marshal(session.config)
+		if err != nil {
+			s.requestLogger(req).Warnf("unable to encode tuned config: %s", err)
+			return true
+		}
+
+		return send(tuneMessage{Type: tuneConfig, Pipeline: session.name, Config: config})
+	}
+
+	if !sendConfig() {
+		return
+	}
+
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var msg tuneMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			if !send(tuneErrorMessage(fmt.Errorf("invalid message: %w", err))) {
+				return
+			}
+			continue
+		}
+
+		// tuning a pipeline that's no longer active would switch back to it
+		if active := s.pipelineManager.Name(); active != session.name {
+			if session, err = s.activeTuneSession(); err != nil {
+				send(tuneErrorMessage(err))
+				return
+			}
+
+			if !send(tuneMessage{Type: tuneError, Error: fmt.Sprintf("active pipeline changed to %q", active)}) || !sendConfig() {
+				return
+			}
+			continue
+		}
+
+		var reply tuneMessage
+		switch msg.Type {
+		case tunePatch:
+			err = s.patchTuneSession(&session, msg.Config)
+			reply = tuneMessage{Type: tuneApplied, Pipeline: session.name}
+		case tuneCommit:
+			err = s.Store.PutPipelineConfig(session.name, session.config)
+			reply = tuneMessage{Type: tuneCommitted, Pipeline: session.name}
+		case tuneRevert:
+			if err = s.revertTuneSession(&session); err == nil {
+				if !sendConfig() {
+					return
+				}
+				continue
+			}
+		default:
+			err = fmt.Errorf("unknown message type %q", msg.Type)
+		}
+
+		if err != nil {
+			reply = tuneErrorMessage(err)
+		}
+
+		if !send(reply) {
+			return
+		}
+	}
+}
+
+// tuneErrorMessage returns the error message for the error, including the invalid fields if
+// it's a validation error.
+func tuneErrorMessage(err error) tuneMessage {
+	msg := tuneMessage{Type: tuneError, Error: err.Error()}
+
+	var validation pipeline.ValidationError
+	if errors.As(err, &validation) {
+		msg.Fields = validation.Fields
+	}
+
+	return msg
+}
+
+// activeTuneSession starts tuning the active pipeline.
+func (s *Server) activeTuneSession() (tuneSession, error) {
+	name, active := s.pipelineManager.Name(), s.pipelineManager.Pipeline()
+	if active == nil {
+		return tuneSession{}, errors.New("no active pipeline")
+	}
+
+	config, err := cloneConfig(active.Config)
+	if err != nil {
+		return tuneSession{}, err
+	}
+
+	return tuneSession{name: name, config: config}, nil
+}
+
+// patchTuneSession merges the partial config into the session's config and applies it.
+func (s *Server) patchTuneSession(session *tuneSession, patch json.RawMessage) error {
+	config, err := mergeTunePatch(session.config, patch)
+	if err != nil {
+		return err
+	}
+
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	if err := s.pipelineManager.SetConfig(session.name, config); err != nil {
+		return err
+	}
+	session.config = config
+
+	return nil
+}
+
+// revertTuneSession reapplies the session's saved config.
+func (s *Server) revertTuneSession(session *tuneSession) error {
+	config, err := s.Store.PipelineConfig(session.name)
+	if err != nil {
+		return err
+	}
+
+	if err := s.pipelineManager.SetConfig(session.name, config); err != nil {
+		return err
+	}
+	session.config = config
+
+	return nil
+}
+
+// mergeTunePatch returns the config with the partial config merged into it. The patch is
+// merged as JSON objects rather than decoded onto the config, since it has no schema version
+// and decoding it alone would migrate it, resetting fields it doesn't set to their defaults.
+// The merged config is decoded afresh, so slices and maps shared with the active pipeline
+// aren't modified in place.
+func mergeTunePatch(config pipeline.Config, patch json.RawMessage) (pipeline.Config, error) {
+	var merged pipeline.Config
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return merged, fmt.Errorf("unable to marshal config: %w", err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return merged, fmt.Errorf("unable to unmarshal config: %w", err)
+	}
+
+	var patchFields map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &patchFields); err != nil {
+		return merged, fmt.Errorf("invalid config: %w", err)
+	}
+
+	if data, err = json.Marshal(mergeObjects(fields, patchFields)); err != nil {
+		return merged, fmt.Errorf("unable to marshal merged config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return merged, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return merged, nil
+}
+
+// mergeObjects overlays the patch's fields onto the object's, recursing into fields that are
+// objects in both, and returns the object.
+func mergeObjects(object, patch map[string]json.RawMessage) map[string]json.RawMessage {
+	if object == nil {
+		object = make(map[string]json.RawMessage, len(patch))
+	}
+
+	for key, value := range patch {
+		var objectField, patchField map[string]json.RawMessage
+		if json.Unmarshal(object[key], &objectField) == nil && objectField != nil &&
+			json.Unmarshal(value, &patchField) == nil && patchField != nil {
+			// the fields were just decoded, so they're valid JSON and marshalling can't fail
+			object[key], _ = json.Marshal(mergeObjects(objectField, patchField))
+			continue
+		}
+
+		object[key] = value
+	}
+
+	return object
+}
+
+// cloneConfig returns a deep copy of the config.
+func cloneConfig(config pipeline.Config) (pipeline.Config, error) {
+	var clone pipeline.Config
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return clone, fmt.Errorf("unable to marshal config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &clone); err != nil {
+		return clone, fmt.Errorf("unable to unmarshal config: %w", err)
+	}
+
+	return clone, nil
+}
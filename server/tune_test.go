@@ -0,0 +1,43 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gloworm-vision/gloworm-app/pipeline"
+)
+
+// TestMergeTunePatch checks that patching some fields of a tuned config leaves the others as
+// they are, rather than resetting them to their defaults for configs without a schema version.
+func TestMergeTunePatch(t *testing.T) {
+	config := pipeline.Config{
+		SchemaVersion: pipeline.CurrentSchemaVersion,
+		Type:          pipeline.TypeReflective,
+		ColorSpace:    pipeline.ColorSpaceLAB,
+		SortMode:      pipeline.SortLargest,
+		MaxTargets:    5,
+		MinThresh:     pipeline.HSV{H: 50, S: 100, V: 100},
+		MaxThresh:     pipeline.HSV{H: 70, S: 255, V: 255},
+	}
+
+	merged, err := mergeTunePatch(config, json.RawMessage(`{"minThresh": {"h": 55}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if merged.ColorSpace != pipeline.ColorSpaceLAB {
+		t.Errorf("color space is %q, want %q", merged.ColorSpace, pipeline.ColorSpaceLAB)
+	}
+
+	if merged.MaxTargets != 5 {
+		t.Errorf("max targets is %d, want 5", merged.MaxTargets)
+	}
+
+	if want := (pipeline.HSV{H: 55, S: 100, V: 100}); merged.MinThresh != want {
+		t.Errorf("min thresh is %+v, want %+v", merged.MinThresh, want)
+	}
+
+	if config.MinThresh.H != 50 {
+		t.Errorf("original config's min thresh was modified")
+	}
+}
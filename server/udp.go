@@ -0,0 +1,98 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+)
+
+// Formats results are sent over UDP in.
+const (
+	// UDPBinary packs each result into big-endian values. See udpPacket.
+	UDPBinary = "binary"
+
+	// UDPJSON encodes each result as the same JSON object served at /result.
+	UDPJSON = "json"
+)
+
+// udpPacketVersion is the version of the binary packet layout, which is the first byte of
+// every packet so robot code can reject packets it doesn't understand.
+const udpPacketVersion = 2
+
+// initUDP connects to UDPAddr, if it's set.
+func (s *Server) initUDP() error {
+	if s.UDPAddr == "" {
+		return nil
+	}
+
+	switch s.UDPFormat {
+	case "", UDPBinary, UDPJSON:
+	default:
+		return fmt.Errorf("unknown udp format %q", s.UDPFormat)
+	}
+
+	conn, err := net.Dial("udp", s.UDPAddr)
+	if err != nil {
+		return fmt.Errorf("unable to dial %q: %w", s.UDPAddr, err)
+	}
+	s.udp = conn
+
+	return nil
+}
+
+// sendUDP sends the result to UDPAddr, if it's set. Datagrams are fire and forget, so errors
+// (such as nothing listening yet) are only logged at debug level.
+func (s *Server) sendUDP(msg resultMessage) {
+	if s.udp == nil {
+		return
+	}
+
+	var data []byte
+	if s.UDPFormat == UDPJSON {
+		var err error
+		if data, err = json.Marshal(msg); err != nil {
+			s.Logger.Warnf("unable to encode udp result: %s", err)
+			return
+		}
+	} else {
+		data = udpPacket(msg)
+	}
+
+	if _, err := s.udp.Write(data); err != nil {
+		s.Logger.Debugf("unable to send udp result: %s", err)
+	}
+}
+
+// udpPacket packs the result as big-endian values: the packet version as a byte, the frame's
+// sequence number as a uint64, the capture time in microseconds since the Unix epoch as an
+// int64, the latency in milliseconds, the number of targets as a byte, then for each target its
+// yaw, pitch, distance, area (as a fraction of the frame), confidence and normalized x and y
+// offsets. Every value is a float64 unless noted.
+func udpPacket(msg resultMessage) []byte {
+	var buf bytes.Buffer
+	write := func(v interface{}) {
+		_ = binary.Write(&buf, binary.BigEndian, v)
+	}
+
+	targets := msg.Targets
+	if len(targets) > math.MaxUint8 {
+		targets = targets[:math.MaxUint8]
+	}
+
+	buf.WriteByte(udpPacketVersion)
+	write(msg.Sequence)
+	write(msg.Timestamp.UnixNano() / 1000)
+	write(milliseconds(msg.Latency))
+	buf.WriteByte(byte(len(targets)))
+
+	for _, t := range targets {
+		for _, v := range []float64{t.Yaw, t.Pitch, t.Distance, t.Area, t.Confidence, t.OffsetX, t.OffsetY} {
+			write(v)
+		}
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+const (
+	// uiIndex is the page served for any path that isn't a file, since the UI routes on the
+	// client.
+	uiIndex = "index.html"
+
+	// uiIndexCacheControl makes clients revalidate the index, so they pick up new builds.
+	uiIndexCacheControl = "no-cache"
+
+	// uiAssetCacheControl lets clients cache the rest of the UI for a day.
+	uiAssetCacheControl = "public, max-age=86400"
+)
+
+// uiHandler serves the web UI files, falling back to the index for paths without a file
+// extension that don't exist.
+func uiHandler(ui fs.FS) http.Handler {
+	files := http.FileServer(http.FS(ui))
+
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			http.NotFound(res, req)
+			return
+		}
+
+		name := strings.TrimPrefix(path.Clean(req.URL.Path), "/")
+		if name == "" {
+			name = uiIndex
+		}
+
+		if _, err := fs.Stat(ui, name); err != nil {
+			if path.Ext(name) != "" {
+				http.NotFound(res, req)
+				return
+			}
+
+			name = uiIndex
+		}
+
+		if name == uiIndex {
+			// the file server redirects requests for the index to the directory
+			req.URL.Path = "/"
+			res.Header().Set("Cache-Control", uiIndexCacheControl)
+		} else {
+			res.Header().Set("Cache-Control", uiAssetCacheControl)
+		}
+
+		files.ServeHTTP(res, req)
+	})
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gloworm-vision/gloworm-app/store"
+)
+
+// changeActive is the kind of change sent when the active pipeline switches, from the API or
+// networktables. Other kinds are the store's event kinds.
+const changeActive = "active"
+
+// changeSubscriberBuffer is the number of changes buffered for each subscriber. Changes are
+// dropped for subscribers that fall further behind.
+const changeSubscriberBuffer = 16
+
+// change is a change to the configs or the active pipeline, as sent to watching clients.
+type change struct {
+	Kind string `json:"kind"`
+
+	// Name is the pipeline config that changed, or the active pipeline. It's empty if every
+	// pipeline config may have changed, such as after importing a backup.
+	Name string `json:"name,omitempty"`
+
+	// Deleted is set if the pipeline config was deleted (or renamed from).
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// changeBroadcaster fans out changes to subscribers, so open UIs can stay in sync.
+type changeBroadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan change]struct{}
+}
+
+// subscribe returns a channel that receives changes, and a function that unsubscribes it.
+func (b *changeBroadcaster) subscribe() (<-chan change, func()) {
+	ch := make(chan change, changeSubscriberBuffer)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscribers == nil {
+		b.subscribers = make(map[chan change]struct{})
+	}
+	b.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.subscribers, ch)
+	}
+}
+
+// publish sends the changes to every subscriber without blocking.
+func (b *changeBroadcaster) publish(changes ...change) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		for _, c := range changes {
+			select {
+			case ch <- c:
+			default:
+			}
+		}
+	}
+}
+
+// watchStore sends the store's events to watching clients until the context is canceled.
+// Changes made outside of the server, such as by editing a store file, are applied as if
+// they were put through the API: the active pipelines reload their configs, and the hardware
+// and settings are updated.
+func (s *Server) watchStore(ctx context.Context) {
+	events, unwatch := s.Store.Watch()
+	defer unwatch()
+
+	for {
+		select {
+		case e := <-events:
+			s.changes.publish(change{Kind: string(e.Kind), Name: e.Name, Deleted: e.Deleted})
+
+			if e.External {
+				s.applyEvent(e)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// applyEvent applies a change made outside of the server.
+func (s *Server) applyEvent(e store.Event) {
+	logger := s.Logger.WithField("event", e.Kind)
+
+	switch e.Kind {
+	case store.EventPipelineConfig:
+		if e.Deleted {
+			return
+		}
+
+		managers := []*pipelineManager{s.pipelineManager}
+		for _, cam := range s.cameras {
+			managers = append(managers, cam.pipelineManager)
+		}
+
+		for _, manager := range managers {
+			name := manager.Name()
+			if name == "" || (e.Name != "" && e.Name != name) {
+				continue
+			}
+
+			config, err := s.Store.PipelineConfig(name)
+			if err == nil {
+				err = manager.SetConfig(name, config)
+			}
+			if err != nil {
+				logger.Warnf("unable to reload pipeline config %q: %s", name, err)
+			}
+		}
+	case store.EventHardwareConfig:
+		config, err := s.Store.HardwareConfig()
+		if err == nil {
+			err = s.hardwareManager.Update(config)
+		}
+		if err != nil {
+			logger.Warnf("unable to reload hardware config: %s", err)
+		}
+	case store.EventSettings:
+		settings, err := s.Store.Settings()
+		if err != nil {
+			logger.Warnf("unable to reload settings: %s", err)
+			return
+		}
+
+		s.settingsManager.SetSettings(settings)
+		if settings.Logging != (store.LoggingSettings{}) {
+			s.applyLogging(settings.Logging)
+		}
+	}
+}
+
+// watch streams changes to the configs and the active pipeline to the client as server-sent
+// events.
+func (s *Server) watch(res http.ResponseWriter, req *http.Request) {
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		respond(res, errors.New("streaming isn't supported"), http.StatusInternalServerError)
+		return
+	}
+
+	changes, unsubscribe := s.changes.subscribe()
+	defer unsubscribe()
+
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case c := <-changes:
+			data, err := json.Marshal(c)
+			if err != nil {
+				s.requestLogger(req).Warnf("unable to encode change: %s", err)
+				continue
+			}
+
+			if _, err := fmt.Fprintf(res, "event: change\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// websocketGUID is the key suffix defined by RFC 6455 for computing the accept key.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxWebsocketMessage is the largest message read from clients.
+const maxWebsocketMessage = 1 << 16
+
+// websocket opcodes
+const (
+	opText   = 0x1
+	opBinary = 0x2
+	opClose  = 0x8
+	opPing   = 0x9
+	opPong   = 0xA
+)
+
+// websocketConn is a minimal server side websocket connection (RFC 6455), supporting
+// unfragmented text messages, which is all the API needs.
+type websocketConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+
+	writeMu sync.Mutex
+}
+
+// upgradeWebsocket upgrades the request to a websocket connection. If it fails, an error
+// response has already been written.
+func upgradeWebsocket(res http.ResponseWriter, req *http.Request) (*websocketConn, error) {
+	if !headerContains(req.Header, "Connection", "upgrade") || !headerContains(req.Header, "Upgrade", "websocket") {
+		err := errors.New("expected a websocket upgrade")
+		respond(res, err, http.StatusBadRequest)
+		return nil, err
+	}
+
+	key := req.Header.Get("Sec-Websocket-Key")
+	if key == "" || req.Header.Get("Sec-Websocket-Version") != "13" {
+		err := errors.New("unsupported websocket version")
+		res.Header().Set("Sec-Websocket-Version", "13")
+		respond(res, err, http.StatusBadRequest)
+		return nil, err
+	}
+
+	hijacker, ok := res.(http.Hijacker)
+	if !ok {
+		err := errors.New("connection can't be upgraded")
+		respond(res, err, http.StatusInternalServerError)
+		return nil, err
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("unable to hijack connection: %w", err)
+	}
+
+	// the http server's deadlines no longer apply once the connection is hijacked
+	_ = conn.SetDeadline(time.Time{})
+
+	hash := sha1.Sum([]byte(key + websocketGUID))
+	accept := base64.StdEncoding.EncodeToString(hash[:])
+
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", accept)
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unable to complete websocket handshake: %w", err)
+	}
+
+	return &websocketConn{conn: conn, rw: rw}, nil
+}
+
+func headerContains(header http.Header, name, value string) bool {
+	for _, v := range header[http.CanonicalHeaderKey(name)] {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), value) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// WriteText sends a text message. It's safe to call concurrently with ReadMessage.
+func (c *websocketConn) WriteText(data []byte) error {
+	return c.writeFrame(opText, data)
+}
+
+func (c *websocketConn) writeFrame(opcode byte, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	// server frames are never masked
+	header := []byte{0x80 | opcode}
+	switch {
+	case len(data) < 126:
+		header = append(header, byte(len(data)))
+	case len(data) <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(len(data)))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(len(data)))
+	}
+
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(data); err != nil {
+		return err
+	}
+
+	return c.rw.Flush()
+}
+
+// ReadMessage reads the next text or binary message, answering pings along the way. It
+// returns io.EOF when the client closes the connection.
+func (c *websocketConn) ReadMessage() ([]byte, error) {
+	for {
+		var header [2]byte
+		if _, err := io.ReadFull(c.rw, header[:]); err != nil {
+			return nil, err
+		}
+
+		fin, opcode := header[0]&0x80 != 0, header[0]&0x0F
+		masked, length := header[1]&0x80 != 0, uint64(header[1]&0x7F)
+
+		switch length {
+		case 126:
+			var ext [2]byte
+			if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+				return nil, err
+			}
+			length = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+				return nil, err
+			}
+			length = binary.BigEndian.Uint64(ext[:])
+		}
+
+		if !fin || !masked || length > maxWebsocketMessage {
+			// fragmented, unmasked (which clients must not send) and oversized messages
+			// aren't supported
+			c.writeFrame(opClose, []byte{0x03, 0xF0}) // 1008 policy violation
+			return nil, errors.New("unsupported websocket message")
+		}
+
+		var mask [4]byte
+		if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+			return nil, err
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(c.rw, payload); err != nil {
+			return nil, err
+		}
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+
+		switch opcode {
+		case opText, opBinary:
+			return payload, nil
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+		case opClose:
+			c.writeFrame(opClose, nil)
+			return nil, io.EOF
+		}
+	}
+}
+
+// Close closes the underlying connection.
+func (c *websocketConn) Close() error {
+	return c.conn.Close()
+}
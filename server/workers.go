@@ -0,0 +1,66 @@
+package server
+
+import (
+	"github.com/gloworm-vision/gloworm-app/pipeline"
+)
+
+// visionJob is a captured frame being processed by the active pipeline. Jobs are finished
+// (published and streamed) in the order their frames were captured, whichever worker
+// processed them.
+type visionJob struct {
+	frame     capturedFrame
+	snapshots []pendingSnapshot
+
+	// layers are the parts of the overlay drawn onto the frame.
+	layers pipeline.Layers
+
+	// pipeline is the active pipeline when the frame was captured, which is nil if there
+	// isn't one. Workers process the frame with their own copy of it.
+	pipeline      *pipeline.Pipeline
+	name          string
+	pipelineIndex int
+
+	candidate pipeline.Result
+	comparing bool
+
+	result pipeline.Result
+
+	// copied is set if the frame was processed by a copy of the pipeline, whose stage
+	// durations haven't been recorded in the pipeline's profile.
+	copied bool
+
+	// done is closed once the frame has been processed.
+	done chan struct{}
+}
+
+// runWorker processes jobs until the channel is closed. Pipelines can't process frames
+// concurrently, so each worker processes frames with its own copy of the job's pipeline,
+// which is recreated when the active pipeline is replaced.
+func (s *Server) runWorker(jobs <-chan *visionJob) {
+	var source, copied *pipeline.Pipeline
+	defer func() { closeReplaced(copied, nil) }()
+
+	for job := range jobs {
+		if job.pipeline != source {
+			copied = closeReplaced(copied, nil)
+
+			p, err := pipeline.New(job.pipeline.Config)
+			if err != nil {
+				s.Logger.Warnf("unable to copy pipeline for worker: %s", err)
+			}
+			source, copied = job.pipeline, p
+		}
+
+		if copied == nil {
+			// the frame is finished as if there were no active pipeline
+			job.pipeline = nil
+			close(job.done)
+			continue
+		}
+
+		copied.Intermediate, copied.Layers = s.updateIntermediate, &job.layers
+		job.result = copied.ProcessFrame(job.frame.mat, &job.frame.mat, job.frame.captured)
+		job.copied = true
+		close(job.done)
+	}
+}
@@ -0,0 +1,76 @@
+package source
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+// imageExts are the file extensions read from image directories.
+var imageExts = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".bmp": true}
+
+// Images reads frames from the still images in a directory, in lexical order.
+type Images struct {
+	paths []string
+	next  int
+
+	// Loop restarts from the first image once every image has been read.
+	Loop bool
+}
+
+// compile-time check for whether Images satisfies the FrameSource interface
+var _ FrameSource = &Images{}
+
+// OpenImages finds the images in the given directory.
+func OpenImages(dir string, loop bool) (*Images, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read image directory: %w", err)
+	}
+
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() || !imageExts[strings.ToLower(filepath.Ext(file.Name()))] {
+			continue
+		}
+
+		paths = append(paths, filepath.Join(dir, file.Name()))
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no images found in %q", dir)
+	}
+
+	sort.Strings(paths)
+
+	return &Images{paths: paths, Loop: loop}, nil
+}
+
+func (i *Images) Read(m *gocv.Mat) bool {
+	if i.next >= len(i.paths) {
+		if !i.Loop {
+			return false
+		}
+
+		i.next = 0
+	}
+
+	img := gocv.IMRead(i.paths[i.next], gocv.IMReadColor)
+	defer img.Close()
+	i.next++
+
+	if img.Empty() {
+		return false
+	}
+
+	img.CopyTo(m)
+	return true
+}
+
+func (i *Images) Close() error {
+	return nil
+}
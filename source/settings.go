@@ -0,0 +1,105 @@
+package source
+
+import "gocv.io/x/gocv"
+
+// Settable is implemented by sources whose capture properties can be changed, such as cameras.
+type Settable interface {
+	Set(prop gocv.VideoCaptureProperties, value float64)
+	Get(prop gocv.VideoCaptureProperties) float64
+}
+
+// compile-time check for whether a VideoCapture satisfies the Settable interface
+var _ Settable = &gocv.VideoCapture{}
+
+// Settings are camera capture settings. Unset settings are left unchanged. Values are in the
+// units of the camera driver, which vary between cameras.
+type Settings struct {
+	// Width and Height are the capture resolution, and FPS the capture frame rate. Cameras
+	// pick the closest mode they support.
+	Width  *int     `json:"width,omitempty"`
+	Height *int     `json:"height,omitempty"`
+	FPS    *float64 `json:"fps,omitempty"`
+
+	// AutoExposure enables automatic exposure, and must be disabled for Exposure to apply.
+	AutoExposure *bool    `json:"autoExposure,omitempty"`
+	Exposure     *float64 `json:"exposure,omitempty"`
+
+	Gain       *float64 `json:"gain,omitempty"`
+	Brightness *float64 `json:"brightness,omitempty"`
+
+	// WhiteBalance is the white balance color temperature, in kelvin.
+	WhiteBalance *float64 `json:"whiteBalance,omitempty"`
+}
+
+// V4L2 auto exposure modes, as OpenCV maps them onto CAP_PROP_AUTO_EXPOSURE.
+const (
+	manualExposure = 0.25
+	autoExposure   = 0.75
+)
+
+// Apply applies the settings to the source if it's settable, returning false if it isn't.
+func (s Settings) Apply(src FrameSource) bool {
+	settable, ok := src.(Settable)
+	if !ok {
+		return false
+	}
+
+	// the resolution is set first, since changing it can reset other properties
+	if s.Width != nil {
+		settable.Set(gocv.VideoCaptureFrameWidth, float64(*s.Width))
+	}
+	if s.Height != nil {
+		settable.Set(gocv.VideoCaptureFrameHeight, float64(*s.Height))
+	}
+
+	// auto exposure must be set before exposure, since exposure is ignored while it's enabled
+	if s.AutoExposure != nil {
+		mode := manualExposure
+		if *s.AutoExposure {
+			mode = autoExposure
+		}
+		settable.Set(gocv.VideoCaptureAutoExposure, mode)
+	}
+
+	for prop, value := range map[gocv.VideoCaptureProperties]*float64{
+		gocv.VideoCaptureFPS:         s.FPS,
+		gocv.VideoCaptureExposure:    s.Exposure,
+		gocv.VideoCaptureGain:        s.Gain,
+		gocv.VideoCaptureBrightness:  s.Brightness,
+		gocv.VideoCaptureTemperature: s.WhiteBalance,
+	} {
+		if value != nil {
+			settable.Set(prop, *value)
+		}
+	}
+
+	return true
+}
+
+// ReadSettings reads the current settings of the source if it's settable, returning false if
+// it isn't.
+func ReadSettings(src FrameSource) (Settings, bool) {
+	settable, ok := src.(Settable)
+	if !ok {
+		return Settings{}, false
+	}
+
+	get := func(prop gocv.VideoCaptureProperties) *float64 {
+		value := settable.Get(prop)
+		return &value
+	}
+
+	width, height := int(settable.Get(gocv.VideoCaptureFrameWidth)), int(settable.Get(gocv.VideoCaptureFrameHeight))
+	autoExposure := settable.Get(gocv.VideoCaptureAutoExposure) == autoExposure
+
+	return Settings{
+		Width:        &width,
+		Height:       &height,
+		FPS:          get(gocv.VideoCaptureFPS),
+		AutoExposure: &autoExposure,
+		Exposure:     get(gocv.VideoCaptureExposure),
+		Gain:         get(gocv.VideoCaptureGain),
+		Brightness:   get(gocv.VideoCaptureBrightness),
+		WhiteBalance: get(gocv.VideoCaptureTemperature),
+	}, true
+}
@@ -0,0 +1,53 @@
+package source
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// FrameSource is a source of frames, such as a live camera, a video file, or a directory
+// of still images.
+type FrameSource interface {
+	// Read reads the next frame into m, returning false if no frame could be read.
+	Read(m *gocv.Mat) bool
+
+	io.Closer
+}
+
+// compile-time check for whether a VideoCapture satisfies the FrameSource interface
+var _ FrameSource = &gocv.VideoCapture{}
+
+// OpenCamera opens the camera with the given device ID.
+func OpenCamera(device int) (*Camera, error) {
+	capture, err := gocv.OpenVideoCapture(device)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open camera %d: %w", device, err)
+	}
+
+	return &Camera{VideoCapture: capture, device: device}, nil
+}
+
+type throttled struct {
+	FrameSource
+
+	interval time.Duration
+	last     time.Time
+}
+
+// Throttle limits the rate frames are read from the source to at most fps frames per second,
+// which is useful for offline sources that can otherwise be read as fast as possible.
+func Throttle(src FrameSource, fps float64) FrameSource {
+	return &throttled{FrameSource: src, interval: time.Duration(float64(time.Second) / fps)}
+}
+
+func (t *throttled) Read(m *gocv.Mat) bool {
+	if wait := t.interval - time.Since(t.last); wait > 0 {
+		time.Sleep(wait)
+	}
+	t.last = time.Now()
+
+	return t.FrameSource.Read(m)
+}
@@ -0,0 +1,58 @@
+package source
+
+import (
+	"fmt"
+	"os/exec"
+
+	"gocv.io/x/gocv"
+)
+
+// v4l2Controls are the V4L2 controls of the properties OpenCV's V4L2 backend can fail to set,
+// depending on the camera and driver version.
+var v4l2Controls = map[gocv.VideoCaptureProperties]string{
+	gocv.VideoCaptureAutoExposure: "exposure_auto",
+	gocv.VideoCaptureExposure:     "exposure_absolute",
+	gocv.VideoCaptureGain:         "gain",
+	gocv.VideoCaptureBrightness:   "brightness",
+	gocv.VideoCaptureTemperature:  "white_balance_temperature",
+}
+
+// V4L2 exposure_auto menu values.
+const (
+	v4l2ManualExposure   = 1
+	v4l2AperturePriority = 3
+)
+
+// Camera is a camera capture. Properties OpenCV doesn't set are set with v4l2-ctl instead, if
+// it's installed, so cameras can be configured without running it by hand.
+type Camera struct {
+	*gocv.VideoCapture
+
+	device int
+}
+
+// compile-time check for whether a Camera satisfies the Settable interface
+var _ Settable = &Camera{}
+
+// Set sets the property, falling back to setting its V4L2 control if OpenCV didn't change it.
+func (c *Camera) Set(prop gocv.VideoCaptureProperties, value float64) {
+	c.VideoCapture.Set(prop, value)
+
+	control, ok := v4l2Controls[prop]
+	if !ok || c.VideoCapture.Get(prop) == value {
+		return
+	}
+
+	controlValue := int(value)
+	if prop == gocv.VideoCaptureAutoExposure {
+		controlValue = v4l2ManualExposure
+		if value == autoExposure {
+			controlValue = v4l2AperturePriority
+		}
+	}
+
+	// the control can still be unsupported by the camera, which leaves it unchanged like
+	// OpenCV does
+	_ = exec.Command("v4l2-ctl", "-d", fmt.Sprintf("/dev/video%d", c.device),
+		"-c", fmt.Sprintf("%s=%d", control, controlValue)).Run()
+}
@@ -0,0 +1,45 @@
+package source
+
+import (
+	"fmt"
+
+	"gocv.io/x/gocv"
+)
+
+// Video reads frames from a video file.
+type Video struct {
+	capture *gocv.VideoCapture
+
+	// Loop restarts the video from the beginning once the end is reached.
+	Loop bool
+}
+
+// compile-time check for whether Video satisfies the FrameSource interface
+var _ FrameSource = &Video{}
+
+// OpenVideo opens the video file at the given path.
+func OpenVideo(path string, loop bool) (*Video, error) {
+	capture, err := gocv.VideoCaptureFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open video %q: %w", path, err)
+	}
+
+	return &Video{capture: capture, Loop: loop}, nil
+}
+
+func (v *Video) Read(m *gocv.Mat) bool {
+	if v.capture.Read(m) && !m.Empty() {
+		return true
+	}
+
+	if !v.Loop {
+		return false
+	}
+
+	v.capture.Set(gocv.VideoCapturePosFrames, 0)
+	return v.capture.Read(m) && !m.Empty()
+}
+
+func (v *Video) Close() error {
+	return v.capture.Close()
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/gloworm-vision/gloworm-app/hardware"
+	"github.com/gloworm-vision/gloworm-app/pipeline"
+)
+
+// BackupVersion is the version of the backup format exported. Backups without a version were
+// exported before it was added, and are the same as version 1. Version 2 added ExtraSettings,
+// and version 3 added PipelineConfigMetadata.
+const BackupVersion = 3
+
+// ErrBackupVersion is returned when importing a backup from a newer version of the format.
+var ErrBackupVersion = errors.New("backup version isn't supported")
+
+// Backup is every config in a store, which can be imported to restore them or copy them to
+// another device.
+type Backup struct {
+	Version int `json:"version"`
+
+	PipelineConfigs       map[string]pipeline.Config `json:"pipelineConfigs"`
+	DefaultPipelineConfig string                     `json:"defaultPipelineConfig"`
+
+	// PipelineConfigMetadata is the metadata of the pipeline configs that have any, by name.
+	PipelineConfigMetadata map[string]PipelineConfigMetadata `json:"pipelineConfigMetadata,omitempty"`
+
+	// HardwareConfig is nil if no hardware config is set.
+	HardwareConfig *hardware.Config `json:"hardwareConfig"`
+
+	// Settings is nil if none of its fields are set.
+	Settings *Settings `json:"settings"`
+
+	// ExtraSettings are the JSON values of the settings that aren't one of Settings' fields,
+	// by key.
+	ExtraSettings map[string]json.RawMessage `json:"extraSettings,omitempty"`
+}
+
+// Validate validates the backup's version, every pipeline config, its metadata and the
+// settings, and that the default pipeline config exists. The paths of invalid fields in
+// pipeline configs are prefixed with the pipeline config they're in.
+func (b Backup) Validate() error {
+	if b.Version > BackupVersion {
+		return fmt.Errorf("backup is version %d, but only up to %d is supported: %w", b.Version, BackupVersion, ErrBackupVersion)
+	}
+
+	var invalid []pipeline.FieldError
+
+	if _, ok := b.PipelineConfigs[b.DefaultPipelineConfig]; b.DefaultPipelineConfig != "" && !ok {
+		invalid = append(invalid, pipeline.FieldError{Path: "defaultPipelineConfig", Message: "must be one of the pipeline configs"})
+	}
+
+	for name, metadata := range b.PipelineConfigMetadata {
+		if _, ok := b.PipelineConfigs[name]; !ok {
+			invalid = append(invalid, pipeline.FieldError{Path: "pipelineConfigMetadata." + name, Message: "must be one of the pipeline configs"})
+		} else if err := metadata.Validate(); err != nil {
+			invalid = append(invalid, pipeline.FieldError{Path: "pipelineConfigMetadata." + name, Message: err.Error()})
+		}
+	}
+
+	for name, config := range b.PipelineConfigs {
+		var validation pipeline.ValidationError
+		if err := config.Validate(); errors.As(err, &validation) {
+			for _, field := range validation.Fields {
+				field.Path = "pipelineConfigs." + name + "." + field.Path
+				invalid = append(invalid, field)
+			}
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if b.Settings != nil {
+		if err := b.Settings.Validate(); err != nil {
+			invalid = append(invalid, pipeline.FieldError{Path: "settings", Message: err.Error()})
+		}
+	}
+
+	if len(invalid) > 0 {
+		sort.Slice(invalid, func(i, j int) bool { return invalid[i].Path < invalid[j].Path })
+		return pipeline.ValidationError{Fields: invalid}
+	}
+
+	return nil
+}
@@ -1,9 +1,16 @@
 package store
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gloworm-vision/gloworm-app/hardware"
 	"github.com/gloworm-vision/gloworm-app/pipeline"
@@ -11,16 +18,39 @@ import (
 )
 
 type BBolt struct {
+	watchers
+
+	// mu is held while using db, and locked to replace it when compacting.
+	mu sync.RWMutex
 	db *bbolt.DB
+
+	// path, mode and options are what the db was opened with, to reopen it after compacting.
+	path    string
+	mode    os.FileMode
+	options *bbolt.Options
 }
 
 const (
 	bboltGlowormBucket        = "gloworm"
 	bboltPipelineConfigBucket = "pipeline-configs" // child of gloworm
+	bboltSettingsBucket       = "settings"         // child of gloworm
+
+	// bboltRevisionsBucket is a child of gloworm, with a child bucket of each pipeline
+	// config's revisions keyed by their big endian numbers.
+	bboltRevisionsBucket = "pipeline-config-revisions"
+
+	// bboltMetadataBucket is a child of gloworm, with the metadata of each pipeline config
+	// keyed by name.
+	bboltMetadataBucket = "pipeline-config-metadata"
 
 	// gloworm keys
 	bboltHardwareKey              = "hardware"
 	bboltDefaultPipelineConfigKey = "default-pipeline-config"
+
+	// bboltLegacySettingsKey is the gloworm key Settings were stored at as a whole, before
+	// each was stored in the settings bucket. They're moved to the bucket when the store is
+	// opened.
+	bboltLegacySettingsKey = "settings"
 )
 
 // OpenBBolt opens a BBoltDB database at the given path and creates the needed buckets
@@ -42,6 +72,44 @@ func OpenBBolt(path string, mode os.FileMode, options *bbolt.Options) (Store, er
 			return fmt.Errorf("unable to create bucket %q: %w", bboltPipelineConfigBucket, err)
 		}
 
+		_, err = glowormBucket.CreateBucketIfNotExists([]byte(bboltRevisionsBucket))
+		if err != nil {
+			return fmt.Errorf("unable to create bucket %q: %w", bboltRevisionsBucket, err)
+		}
+
+		_, err = glowormBucket.CreateBucketIfNotExists([]byte(bboltMetadataBucket))
+		if err != nil {
+			return fmt.Errorf("unable to create bucket %q: %w", bboltMetadataBucket, err)
+		}
+
+		settingsBucket := glowormBucket.Bucket([]byte(bboltSettingsBucket))
+		if settingsBucket == nil {
+			// the legacy settings key has the same name, so it's moved out of the way first
+			legacyJSON := glowormBucket.Get([]byte(bboltLegacySettingsKey))
+			if legacyJSON != nil {
+				legacyJSON = append([]byte(nil), legacyJSON...)
+				if err := glowormBucket.Delete([]byte(bboltLegacySettingsKey)); err != nil {
+					return fmt.Errorf("unable to delete legacy settings: %w", err)
+				}
+			}
+
+			settingsBucket, err = glowormBucket.CreateBucket([]byte(bboltSettingsBucket))
+			if err != nil {
+				return fmt.Errorf("unable to create bucket %q: %w", bboltSettingsBucket, err)
+			}
+
+			if legacyJSON != nil {
+				var values map[string]json.RawMessage
+				if err := json.Unmarshal(legacyJSON, &values); err != nil {
+					return fmt.Errorf("unable to unmarshal legacy settings JSON: %w", err)
+				}
+
+				if err := bboltPutSettings(settingsBucket, values); err != nil {
+					return fmt.Errorf("unable to migrate legacy settings: %w", err)
+				}
+			}
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -49,23 +117,167 @@ func OpenBBolt(path string, mode os.FileMode, options *bbolt.Options) (Store, er
 	}
 
 	return &BBolt{
-		db: db,
+		db:      db,
+		path:    path,
+		mode:    mode,
+		options: options,
 	}, nil
 }
 
 func (b *BBolt) Close() error {
-	return b.Close()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.db.Close()
+}
+
+// view runs a read-only transaction on the db.
+func (b *BBolt) view(fn func(tx *bbolt.Tx) error) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.db.View(fn)
+}
+
+// update runs a read-write transaction on the db.
+func (b *BBolt) update(fn func(tx *bbolt.Tx) error) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.db.Update(fn)
+}
+
+// Check checks the consistency of the db's pages, and that every config can be unmarshaled.
+func (b *BBolt) Check() error {
+	var problems []string
+	err := b.view(func(tx *bbolt.Tx) error {
+		for err := range tx.Check() {
+			problems = append(problems, err.Error())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to check bbolt db: %w", err)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrCorrupt, strings.Join(problems, "; "))
+	}
+
+	if _, err := b.Export(); err != nil {
+		return fmt.Errorf("%w: %s", ErrCorrupt, err)
+	}
+
+	return nil
+}
+
+// Compact copies every bucket into a new db and replaces the db with it, leaving behind the
+// free pages the db accumulated. Nothing else can use the db until it's done.
+func (b *BBolt) Compact() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	compactPath := b.path + ".compact"
+	if err := os.Remove(compactPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to remove old compacted db: %w", err)
+	}
+
+	compacted, err := bbolt.Open(compactPath, b.mode, b.options)
+	if err != nil {
+		return fmt.Errorf("unable to open compacted db: %w", err)
+	}
+
+	err = b.db.View(func(src *bbolt.Tx) error {
+		return compacted.Update(func(dst *bbolt.Tx) error {
+			return src.ForEach(func(name []byte, srcBucket *bbolt.Bucket) error {
+				dstBucket, err := dst.CreateBucket(name)
+				if err != nil {
+					return fmt.Errorf("unable to create bucket %q: %w", name, err)
+				}
+
+				return bboltCopyBucket(dstBucket, srcBucket)
+			})
+		})
+	})
+	if closeErr := compacted.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(compactPath)
+		return fmt.Errorf("unable to compact bbolt db: %w", err)
+	}
+
+	// the compacted db is opened before it replaces the db, so the store is never left without
+	// an open db. Renaming it doesn't affect the open handle.
+	db, err := bbolt.Open(compactPath, b.mode, b.options)
+	if err != nil {
+		os.Remove(compactPath)
+		return fmt.Errorf("unable to open compacted db, leaving the db uncompacted: %w", err)
+	}
+
+	if err := os.Rename(compactPath, b.path); err != nil {
+		db.Close()
+		os.Remove(compactPath)
+		return fmt.Errorf("unable to replace bbolt db, leaving it uncompacted: %w", err)
+	}
+
+	old := b.db
+	b.db = db
+
+	if err := old.Close(); err != nil {
+		return fmt.Errorf("unable to close uncompacted bbolt db: %w", err)
+	}
+
+	return nil
+}
+
+// bboltCopyBucket copies every key and child bucket from one bucket to another.
+func bboltCopyBucket(dst, src *bbolt.Bucket) error {
+	if err := dst.SetSequence(src.Sequence()); err != nil {
+		return fmt.Errorf("unable to set sequence: %w", err)
+	}
+
+	return src.ForEach(func(k, v []byte) error {
+		// child buckets have nil values
+		if v != nil {
+			return dst.Put(k, v)
+		}
+
+		child, err := dst.CreateBucket(k)
+		if err != nil {
+			return fmt.Errorf("unable to create bucket %q: %w", k, err)
+		}
+
+		return bboltCopyBucket(child, src.Bucket(k))
+	})
+}
+
+// WriteTo writes a consistent copy of the database to w, which can be opened in place of it.
+// Changes can still be made while it's written.
+func (b *BBolt) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	err := b.view(func(tx *bbolt.Tx) error {
+		var err error
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	if err != nil {
+		return n, fmt.Errorf("unable to write bbolt db copy: %w", err)
+	}
+
+	return n, nil
 }
 
 func (b *BBolt) PipelineConfig(name string) (pipeline.Config, error) {
 	var p pipeline.Config
-	err := b.db.View(func(tx *bbolt.Tx) error {
+	err := b.view(func(tx *bbolt.Tx) error {
 		glowormBucket := tx.Bucket([]byte(bboltGlowormBucket))
 		configBucket := glowormBucket.Bucket([]byte(bboltPipelineConfigBucket))
 
 		pipelineJSON := configBucket.Get([]byte(name))
 		if pipelineJSON == nil {
-			return fmt.Errorf("pipeline config does not exist")
+			return ErrPipelineConfigNotExist
 		}
 
 		if err := json.Unmarshal(pipelineJSON, &p); err != nil {
@@ -81,15 +293,24 @@ func (b *BBolt) PipelineConfig(name string) (pipeline.Config, error) {
 	return p, nil
 }
 
-func (b *BBolt) ListPipelineConfigs() ([]string, error) {
-	names := make([]string, 0)
+func (b *BBolt) ListPipelineConfigs() ([]PipelineConfigEntry, error) {
+	entries := make([]PipelineConfigEntry, 0)
 
-	err := b.db.View(func(tx *bbolt.Tx) error {
+	err := b.view(func(tx *bbolt.Tx) error {
 		glowormBucket := tx.Bucket([]byte(bboltGlowormBucket))
 		configBucket := glowormBucket.Bucket([]byte(bboltPipelineConfigBucket))
+		metadataBucket := bboltMetadataBucketOf(tx)
 
+		// keys are iterated in order, so the entries are sorted by name
 		err := configBucket.ForEach(func(k, _ []byte) error {
-			names = append(names, string(k))
+			entry := PipelineConfigEntry{Name: string(k)}
+			if metadataJSON := metadataBucket.Get(k); metadataJSON != nil {
+				if err := json.Unmarshal(metadataJSON, &entry.PipelineConfigMetadata); err != nil {
+					return fmt.Errorf("unable to unmarshal pipeline config %q metadata JSON: %w", k, err)
+				}
+			}
+
+			entries = append(entries, entry)
 			return nil
 		})
 		if err != nil {
@@ -102,11 +323,11 @@ func (b *BBolt) ListPipelineConfigs() ([]string, error) {
 		return nil, fmt.Errorf("unable to list pipeline configs: %w", err)
 	}
 
-	return names, nil
+	return entries, nil
 }
 
 func (b *BBolt) PutPipelineConfig(name string, p pipeline.Config) error {
-	err := b.db.Update(func(tx *bbolt.Tx) error {
+	err := b.update(func(tx *bbolt.Tx) error {
 		pipelineJSON, err := json.Marshal(p)
 		if err != nil {
 			return fmt.Errorf("unable to marshal pipeline config: %w", err)
@@ -114,7 +335,8 @@ func (b *BBolt) PutPipelineConfig(name string, p pipeline.Config) error {
 
 		glowormBucket := tx.Bucket([]byte(bboltGlowormBucket))
 		configBucket := glowormBucket.Bucket([]byte(bboltPipelineConfigBucket))
-		if err := configBucket.Put([]byte(name), pipelineJSON); err != nil {
+
+		if err := bboltReplacePipelineConfig(tx, configBucket, name, pipelineJSON); err != nil {
 			return fmt.Errorf("unable to put pipeline config %q: %w", name, err)
 		}
 
@@ -124,13 +346,390 @@ func (b *BBolt) PutPipelineConfig(name string, p pipeline.Config) error {
 		return fmt.Errorf("unable to update pipeline config: %w", err)
 	}
 
+	b.publish(Event{Kind: EventPipelineConfig, Name: name})
+
+	return nil
+}
+
+func (b *BBolt) DeletePipelineConfig(name string) error {
+	err := b.update(func(tx *bbolt.Tx) error {
+		glowormBucket := tx.Bucket([]byte(bboltGlowormBucket))
+		if string(glowormBucket.Get([]byte(bboltDefaultPipelineConfigKey))) == name {
+			return ErrDefaultPipelineConfig
+		}
+
+		configBucket := glowormBucket.Bucket([]byte(bboltPipelineConfigBucket))
+		if configBucket.Get([]byte(name)) == nil {
+			return ErrPipelineConfigNotExist
+		}
+
+		if err := configBucket.Delete([]byte(name)); err != nil {
+			return fmt.Errorf("unable to delete pipeline config: %w", err)
+		}
+
+		err := bboltRevisionsBucketOf(tx).DeleteBucket([]byte(name))
+		if err != nil && !errors.Is(err, bbolt.ErrBucketNotFound) {
+			return fmt.Errorf("unable to delete pipeline config revisions: %w", err)
+		}
+
+		if err := bboltMetadataBucketOf(tx).Delete([]byte(name)); err != nil {
+			return fmt.Errorf("unable to delete pipeline config metadata: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to delete pipeline config %q: %w", name, err)
+	}
+
+	b.publish(Event{Kind: EventPipelineConfig, Name: name, Deleted: true})
+
+	return nil
+}
+
+func (b *BBolt) CopyPipelineConfig(from, to string) error {
+	err := b.update(func(tx *bbolt.Tx) error {
+		glowormBucket := tx.Bucket([]byte(bboltGlowormBucket))
+		if err := bboltCopyPipelineConfig(glowormBucket.Bucket([]byte(bboltPipelineConfigBucket)), from, to); err != nil {
+			return err
+		}
+
+		// the copy has the same description, author and tags, but is created now
+		metadataBucket := bboltMetadataBucketOf(tx)
+		metadataJSON, err := touchMetadata(metadataBucket.Get([]byte(from)), time.Now(), true)
+		if err != nil {
+			return err
+		}
+
+		if err := metadataBucket.Put([]byte(to), metadataJSON); err != nil {
+			return fmt.Errorf("unable to put pipeline config metadata: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to copy pipeline config %q to %q: %w", from, to, err)
+	}
+
+	b.publish(Event{Kind: EventPipelineConfig, Name: to})
+
+	return nil
+}
+
+func (b *BBolt) RenamePipelineConfig(from, to string) error {
+	var renamedDefault bool
+	err := b.update(func(tx *bbolt.Tx) error {
+		glowormBucket := tx.Bucket([]byte(bboltGlowormBucket))
+		configBucket := glowormBucket.Bucket([]byte(bboltPipelineConfigBucket))
+
+		if err := bboltCopyPipelineConfig(configBucket, from, to); err != nil {
+			return err
+		}
+
+		if err := configBucket.Delete([]byte(from)); err != nil {
+			return fmt.Errorf("unable to delete pipeline config: %w", err)
+		}
+
+		if err := bboltRenameRevisions(bboltRevisionsBucketOf(tx), from, to); err != nil {
+			return fmt.Errorf("unable to rename pipeline config revisions: %w", err)
+		}
+
+		metadataBucket := bboltMetadataBucketOf(tx)
+		if metadataJSON := metadataBucket.Get([]byte(from)); metadataJSON != nil {
+			// Put copies the value before it's deleted
+			if err := metadataBucket.Put([]byte(to), metadataJSON); err != nil {
+				return fmt.Errorf("unable to put pipeline config metadata: %w", err)
+			}
+
+			if err := metadataBucket.Delete([]byte(from)); err != nil {
+				return fmt.Errorf("unable to delete pipeline config metadata: %w", err)
+			}
+		}
+
+		if string(glowormBucket.Get([]byte(bboltDefaultPipelineConfigKey))) == from {
+			if err := glowormBucket.Put([]byte(bboltDefaultPipelineConfigKey), []byte(to)); err != nil {
+				return fmt.Errorf("unable to put default pipeline config: %w", err)
+			}
+			renamedDefault = true
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to rename pipeline config %q to %q: %w", from, to, err)
+	}
+
+	b.publish(renameEvents(from, to, renamedDefault)...)
+
+	return nil
+}
+
+// bboltCopyPipelineConfig copies a pipeline config within the config bucket.
+func bboltCopyPipelineConfig(configBucket *bbolt.Bucket, from, to string) error {
+	pipelineJSON := configBucket.Get([]byte(from))
+	if pipelineJSON == nil {
+		return ErrPipelineConfigNotExist
+	}
+
+	if configBucket.Get([]byte(to)) != nil {
+		return ErrPipelineConfigExists
+	}
+
+	// the value is only valid for the transaction, but Put copies it
+	if err := configBucket.Put([]byte(to), pipelineJSON); err != nil {
+		return fmt.Errorf("unable to put pipeline config: %w", err)
+	}
+
+	return nil
+}
+
+func (b *BBolt) PipelineConfigRevisions(name string) ([]Revision, error) {
+	revisions := make([]Revision, 0)
+
+	err := b.view(func(tx *bbolt.Tx) error {
+		glowormBucket := tx.Bucket([]byte(bboltGlowormBucket))
+		if glowormBucket.Bucket([]byte(bboltPipelineConfigBucket)).Get([]byte(name)) == nil {
+			return ErrPipelineConfigNotExist
+		}
+
+		bucket := bboltRevisionsBucketOf(tx).Bucket([]byte(name))
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var revision Revision
+			if err := json.Unmarshal(v, &revision); err != nil {
+				return fmt.Errorf("unable to unmarshal pipeline config revision JSON: %w", err)
+			}
+			revision.Number = int(binary.BigEndian.Uint64(k))
+
+			revisions = append(revisions, revision)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list pipeline config %q revisions: %w", name, err)
+	}
+
+	return revisions, nil
+}
+
+func (b *BBolt) RestorePipelineConfig(name string, number int) error {
+	err := b.update(func(tx *bbolt.Tx) error {
+		glowormBucket := tx.Bucket([]byte(bboltGlowormBucket))
+		configBucket := glowormBucket.Bucket([]byte(bboltPipelineConfigBucket))
+		if configBucket.Get([]byte(name)) == nil {
+			return ErrPipelineConfigNotExist
+		}
+
+		var revisionJSON []byte
+		if bucket := bboltRevisionsBucketOf(tx).Bucket([]byte(name)); bucket != nil {
+			revisionJSON = bucket.Get(bboltRevisionKey(number))
+		}
+		if revisionJSON == nil {
+			return ErrRevisionNotExist
+		}
+
+		var revision bboltRevision
+		if err := json.Unmarshal(revisionJSON, &revision); err != nil {
+			return fmt.Errorf("unable to unmarshal pipeline config revision JSON: %w", err)
+		}
+
+		return bboltReplacePipelineConfig(tx, configBucket, name, revision.Config)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to restore pipeline config %q revision %d: %w", name, number, err)
+	}
+
+	b.publish(Event{Kind: EventPipelineConfig, Name: name})
+
+	return nil
+}
+
+// bboltRevision is how a pipeline config revision is stored. Its number is its key.
+type bboltRevision struct {
+	Replaced time.Time       `json:"replaced"`
+	Config   json.RawMessage `json:"config"`
+}
+
+// bboltRevisionsBucketOf returns the bucket of every pipeline config's revisions in the
+// transaction.
+func bboltRevisionsBucketOf(tx *bbolt.Tx) *bbolt.Bucket {
+	return tx.Bucket([]byte(bboltGlowormBucket)).Bucket([]byte(bboltRevisionsBucket))
+}
+
+// bboltRevisionKey returns the key of a pipeline config revision, which sorts by number.
+func bboltRevisionKey(number int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(number))
+
+	return key
+}
+
+// bboltReplacePipelineConfig puts the pipeline config JSON unless it's unchanged, keeping the
+// config it replaces as a revision, and updating its metadata's modified time.
+func bboltReplacePipelineConfig(tx *bbolt.Tx, configBucket *bbolt.Bucket, name string, pipelineJSON []byte) error {
+	old := configBucket.Get([]byte(name))
+	if old != nil && bytes.Equal(old, pipelineJSON) {
+		return nil
+	}
+
+	if old != nil {
+		// the value is only valid until the transaction changes, so it's copied
+		if err := bboltPushRevision(bboltRevisionsBucketOf(tx), name, append([]byte(nil), old...)); err != nil {
+			return err
+		}
+	}
+
+	metadataBucket := bboltMetadataBucketOf(tx)
+	metadataJSON, err := touchMetadata(metadataBucket.Get([]byte(name)), time.Now(), old == nil)
+	if err != nil {
+		return err
+	}
+
+	if err := metadataBucket.Put([]byte(name), metadataJSON); err != nil {
+		return fmt.Errorf("unable to put pipeline config metadata: %w", err)
+	}
+
+	if err := configBucket.Put([]byte(name), pipelineJSON); err != nil {
+		return fmt.Errorf("unable to put pipeline config: %w", err)
+	}
+
+	return nil
+}
+
+// bboltPushRevision keeps the pipeline config JSON as the newest revision of the named
+// config, discarding revisions beyond MaxPipelineConfigRevisions.
+func bboltPushRevision(revisionsBucket *bbolt.Bucket, name string, pipelineJSON []byte) error {
+	bucket, err := revisionsBucket.CreateBucketIfNotExists([]byte(name))
+	if err != nil {
+		return fmt.Errorf("unable to create pipeline config revisions bucket: %w", err)
+	}
+
+	number := 1
+	if k, _ := bucket.Cursor().Last(); k != nil {
+		number = int(binary.BigEndian.Uint64(k)) + 1
+	}
+
+	revisionJSON, err := json.Marshal(bboltRevision{Replaced: time.Now(), Config: pipelineJSON})
+	if err != nil {
+		return fmt.Errorf("unable to marshal pipeline config revision: %w", err)
+	}
+
+	if err := bucket.Put(bboltRevisionKey(number), revisionJSON); err != nil {
+		return fmt.Errorf("unable to put pipeline config revision: %w", err)
+	}
+
+	c := bucket.Cursor()
+	for k, _ := c.First(); k != nil && int(binary.BigEndian.Uint64(k)) <= number-MaxPipelineConfigRevisions; k, _ = c.First() {
+		if err := c.Delete(); err != nil {
+			return fmt.Errorf("unable to discard pipeline config revision: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// bboltRenameRevisions moves the revisions of the pipeline config named from to the one
+// named to.
+func bboltRenameRevisions(revisionsBucket *bbolt.Bucket, from, to string) error {
+	err := revisionsBucket.DeleteBucket([]byte(to))
+	if err != nil && !errors.Is(err, bbolt.ErrBucketNotFound) {
+		return fmt.Errorf("unable to delete bucket %q: %w", to, err)
+	}
+
+	fromBucket := revisionsBucket.Bucket([]byte(from))
+	if fromBucket == nil {
+		return nil
+	}
+
+	toBucket, err := revisionsBucket.CreateBucket([]byte(to))
+	if err != nil {
+		return fmt.Errorf("unable to create bucket %q: %w", to, err)
+	}
+
+	// Put copies keys and values, so they can be put while iterating
+	err = fromBucket.ForEach(func(k, v []byte) error {
+		return toBucket.Put(k, v)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to copy revisions: %w", err)
+	}
+
+	if err := revisionsBucket.DeleteBucket([]byte(from)); err != nil {
+		return fmt.Errorf("unable to delete bucket %q: %w", from, err)
+	}
+
+	return nil
+}
+
+func (b *BBolt) PipelineConfigMetadata(name string) (PipelineConfigMetadata, error) {
+	var metadata PipelineConfigMetadata
+	err := b.view(func(tx *bbolt.Tx) error {
+		glowormBucket := tx.Bucket([]byte(bboltGlowormBucket))
+		if glowormBucket.Bucket([]byte(bboltPipelineConfigBucket)).Get([]byte(name)) == nil {
+			return ErrPipelineConfigNotExist
+		}
+
+		metadataJSON := bboltMetadataBucketOf(tx).Get([]byte(name))
+		if metadataJSON == nil {
+			return nil
+		}
+
+		if err := json.Unmarshal(metadataJSON, &metadata); err != nil {
+			return fmt.Errorf("unable to unmarshal pipeline config metadata JSON: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return metadata, fmt.Errorf("unable to get pipeline config %q metadata: %w", name, err)
+	}
+
+	return metadata, nil
+}
+
+func (b *BBolt) PutPipelineConfigMetadata(name string, m PipelineConfigMetadata) error {
+	err := b.update(func(tx *bbolt.Tx) error {
+		glowormBucket := tx.Bucket([]byte(bboltGlowormBucket))
+		if glowormBucket.Bucket([]byte(bboltPipelineConfigBucket)).Get([]byte(name)) == nil {
+			return ErrPipelineConfigNotExist
+		}
+
+		metadataBucket := bboltMetadataBucketOf(tx)
+		metadataJSON, err := putMetadata(metadataBucket.Get([]byte(name)), m, time.Now())
+		if err != nil {
+			return err
+		}
+
+		if err := metadataBucket.Put([]byte(name), metadataJSON); err != nil {
+			return fmt.Errorf("unable to put pipeline config metadata: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to update pipeline config %q metadata: %w", name, err)
+	}
+
+	b.publish(Event{Kind: EventPipelineConfig, Name: name})
+
 	return nil
 }
 
+// bboltMetadataBucketOf returns the bucket of every pipeline config's metadata in the
+// transaction.
+func bboltMetadataBucketOf(tx *bbolt.Tx) *bbolt.Bucket {
+	return tx.Bucket([]byte(bboltGlowormBucket)).Bucket([]byte(bboltMetadataBucket))
+}
+
 func (b *BBolt) DefaultPipelineConfig() (string, error) {
 	var def string
 
-	err := b.db.View(func(tx *bbolt.Tx) error {
+	err := b.view(func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket([]byte(bboltGlowormBucket))
 		def = string(bucket.Get([]byte(bboltDefaultPipelineConfigKey)))
 		return nil
@@ -143,7 +742,7 @@ func (b *BBolt) DefaultPipelineConfig() (string, error) {
 }
 
 func (b *BBolt) PutDefaultPipelineConfig(def string) error {
-	err := b.db.Update(func(tx *bbolt.Tx) error {
+	err := b.update(func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket([]byte(bboltGlowormBucket))
 		bucket.Put([]byte(bboltDefaultPipelineConfigKey), []byte(def))
 		return nil
@@ -152,16 +751,18 @@ func (b *BBolt) PutDefaultPipelineConfig(def string) error {
 		return fmt.Errorf("unable to put default pipeline config: %w", err)
 	}
 
+	b.publish(Event{Kind: EventDefaultPipelineConfig, Name: def})
+
 	return nil
 }
 
 func (b *BBolt) HardwareConfig() (hardware.Config, error) {
 	var h hardware.Config
-	err := b.db.View(func(tx *bbolt.Tx) error {
+	err := b.view(func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket([]byte(bboltGlowormBucket))
 		hardwareJSON := bucket.Get([]byte(bboltHardwareKey))
 		if hardwareJSON == nil {
-			return fmt.Errorf("hardware config does not exist")
+			return ErrHardwareConfigNotExist
 		}
 
 		if err := json.Unmarshal(hardwareJSON, &h); err != nil {
@@ -178,7 +779,7 @@ func (b *BBolt) HardwareConfig() (hardware.Config, error) {
 }
 
 func (b *BBolt) PutHardwareConfig(p hardware.Config) error {
-	err := b.db.Update(func(tx *bbolt.Tx) error {
+	err := b.update(func(tx *bbolt.Tx) error {
 		hardwareJSON, err := json.Marshal(p)
 		if err != nil {
 			return fmt.Errorf("unable to marshal hardware config: %w", err)
@@ -195,5 +796,312 @@ func (b *BBolt) PutHardwareConfig(p hardware.Config) error {
 		return fmt.Errorf("unable to update hardware config: %w", err)
 	}
 
+	b.publish(Event{Kind: EventHardwareConfig})
+
+	return nil
+}
+
+func (b *BBolt) Settings() (Settings, error) {
+	var settings Settings
+	err := b.view(func(tx *bbolt.Tx) error {
+		values, err := bboltSettings(tx)
+		if err != nil {
+			return err
+		}
+
+		settings, err = settingsFromValues(values)
+		return err
+	})
+	if err != nil {
+		return settings, fmt.Errorf("unable to get settings: %w", err)
+	}
+
+	return settings, nil
+}
+
+func (b *BBolt) PutSettings(settings Settings) error {
+	values, err := settingsValues(settings)
+	if err != nil {
+		return err
+	}
+
+	err = b.update(func(tx *bbolt.Tx) error {
+		return bboltPutSettings(bboltSettingsBucketOf(tx), values)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to update settings: %w", err)
+	}
+
+	b.publish(Event{Kind: EventSettings})
+
+	return nil
+}
+
+func (b *BBolt) GetSetting(key string, v interface{}) error {
+	err := b.view(func(tx *bbolt.Tx) error {
+		valueJSON := bboltSettingsBucketOf(tx).Get([]byte(key))
+		if valueJSON == nil {
+			return ErrSettingNotExist
+		}
+
+		if err := json.Unmarshal(valueJSON, v); err != nil {
+			return fmt.Errorf("unable to unmarshal setting JSON: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to get setting %q: %w", key, err)
+	}
+
+	return nil
+}
+
+func (b *BBolt) PutSetting(key string, v interface{}) error {
+	if key == "" {
+		return errors.New("setting key must not be empty")
+	}
+
+	valueJSON, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("unable to marshal setting %q: %w", key, err)
+	}
+
+	err = b.update(func(tx *bbolt.Tx) error {
+		values, err := bboltSettings(tx)
+		if err != nil {
+			return err
+		}
+
+		if err := validateSetting(values, key, valueJSON); err != nil {
+			return err
+		}
+
+		return bboltSettingsBucketOf(tx).Put([]byte(key), valueJSON)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to update setting %q: %w", key, err)
+	}
+
+	b.publish(Event{Kind: EventSettings, Name: key})
+
+	return nil
+}
+
+// bboltSettingsBucketOf returns the settings bucket in the transaction.
+func bboltSettingsBucketOf(tx *bbolt.Tx) *bbolt.Bucket {
+	return tx.Bucket([]byte(bboltGlowormBucket)).Bucket([]byte(bboltSettingsBucket))
+}
+
+// bboltSettings returns the JSON value of every setting, by key.
+func bboltSettings(tx *bbolt.Tx) (map[string]json.RawMessage, error) {
+	values := make(map[string]json.RawMessage)
+	err := bboltSettingsBucketOf(tx).ForEach(func(k, v []byte) error {
+		// values are only valid for the life of the transaction
+		values[string(k)] = append(json.RawMessage(nil), v...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to iterate over settings bucket: %w", err)
+	}
+
+	return values, nil
+}
+
+// bboltPutSettings puts the JSON values of the settings in the bucket.
+func bboltPutSettings(bucket *bbolt.Bucket, values map[string]json.RawMessage) error {
+	for key, value := range values {
+		if err := bucket.Put([]byte(key), value); err != nil {
+			return fmt.Errorf("unable to put setting %q: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+func (b *BBolt) Export() (Backup, error) {
+	backup := Backup{Version: BackupVersion, PipelineConfigs: make(map[string]pipeline.Config)}
+
+	err := b.view(func(tx *bbolt.Tx) error {
+		glowormBucket := tx.Bucket([]byte(bboltGlowormBucket))
+		configBucket := glowormBucket.Bucket([]byte(bboltPipelineConfigBucket))
+
+		err := configBucket.ForEach(func(k, v []byte) error {
+			var p pipeline.Config
+			if err := json.Unmarshal(v, &p); err != nil {
+				return fmt.Errorf("unable to unmarshal pipeline config %q JSON: %w", k, err)
+			}
+
+			backup.PipelineConfigs[string(k)] = p
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("unable to iterate over config bucket: %w", err)
+		}
+
+		backup.DefaultPipelineConfig = string(glowormBucket.Get([]byte(bboltDefaultPipelineConfigKey)))
+
+		err = bboltMetadataBucketOf(tx).ForEach(func(k, v []byte) error {
+			var metadata PipelineConfigMetadata
+			if err := json.Unmarshal(v, &metadata); err != nil {
+				return fmt.Errorf("unable to unmarshal pipeline config %q metadata JSON: %w", k, err)
+			}
+
+			if backup.PipelineConfigMetadata == nil {
+				backup.PipelineConfigMetadata = make(map[string]PipelineConfigMetadata)
+			}
+			backup.PipelineConfigMetadata[string(k)] = metadata
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("unable to iterate over metadata bucket: %w", err)
+		}
+
+		if hardwareJSON := glowormBucket.Get([]byte(bboltHardwareKey)); hardwareJSON != nil {
+			backup.HardwareConfig = new(hardware.Config)
+			if err := json.Unmarshal(hardwareJSON, backup.HardwareConfig); err != nil {
+				return fmt.Errorf("unable to unmarshal hardware config JSON: %w", err)
+			}
+		}
+
+		values, err := bboltSettings(tx)
+		if err != nil {
+			return err
+		}
+
+		backup.Settings, backup.ExtraSettings, err = splitSettings(values)
+		return err
+	})
+	if err != nil {
+		return Backup{}, fmt.Errorf("unable to export configs: %w", err)
+	}
+
+	return backup, nil
+}
+
+func (b *BBolt) Import(backup Backup) error {
+	if backup.Version > BackupVersion {
+		return fmt.Errorf("backup is version %d, but only up to %d is supported: %w", backup.Version, BackupVersion, ErrBackupVersion)
+	}
+
+	if _, ok := backup.PipelineConfigs[backup.DefaultPipelineConfig]; backup.DefaultPipelineConfig != "" && !ok {
+		return fmt.Errorf("default pipeline config %q does not exist", backup.DefaultPipelineConfig)
+	}
+
+	err := b.update(func(tx *bbolt.Tx) error {
+		glowormBucket := tx.Bucket([]byte(bboltGlowormBucket))
+
+		// the pipeline configs are replaced, not merged
+		if err := glowormBucket.DeleteBucket([]byte(bboltPipelineConfigBucket)); err != nil {
+			return fmt.Errorf("unable to delete bucket %q: %w", bboltPipelineConfigBucket, err)
+		}
+
+		configBucket, err := glowormBucket.CreateBucket([]byte(bboltPipelineConfigBucket))
+		if err != nil {
+			return fmt.Errorf("unable to create bucket %q: %w", bboltPipelineConfigBucket, err)
+		}
+
+		// revisions aren't backed up, so those of the replaced configs are discarded
+		if err := glowormBucket.DeleteBucket([]byte(bboltRevisionsBucket)); err != nil {
+			return fmt.Errorf("unable to delete bucket %q: %w", bboltRevisionsBucket, err)
+		}
+
+		if _, err := glowormBucket.CreateBucket([]byte(bboltRevisionsBucket)); err != nil {
+			return fmt.Errorf("unable to create bucket %q: %w", bboltRevisionsBucket, err)
+		}
+
+		if err := glowormBucket.DeleteBucket([]byte(bboltMetadataBucket)); err != nil {
+			return fmt.Errorf("unable to delete bucket %q: %w", bboltMetadataBucket, err)
+		}
+
+		metadataBucket, err := glowormBucket.CreateBucket([]byte(bboltMetadataBucket))
+		if err != nil {
+			return fmt.Errorf("unable to create bucket %q: %w", bboltMetadataBucket, err)
+		}
+
+		for name, p := range backup.PipelineConfigs {
+			pipelineJSON, err := json.Marshal(p)
+			if err != nil {
+				return fmt.Errorf("unable to marshal pipeline config %q: %w", name, err)
+			}
+
+			if err := configBucket.Put([]byte(name), pipelineJSON); err != nil {
+				return fmt.Errorf("unable to put pipeline config %q: %w", name, err)
+			}
+
+			metadata, ok := backup.PipelineConfigMetadata[name]
+			if !ok {
+				continue
+			}
+
+			if err := bboltPutOrDelete(metadataBucket, name, metadata, false); err != nil {
+				return fmt.Errorf("unable to import pipeline config metadata: %w", err)
+			}
+		}
+
+		if err := glowormBucket.Put([]byte(bboltDefaultPipelineConfigKey), []byte(backup.DefaultPipelineConfig)); err != nil {
+			return fmt.Errorf("unable to put default pipeline config: %w", err)
+		}
+
+		if err := bboltPutOrDelete(glowormBucket, bboltHardwareKey, backup.HardwareConfig, backup.HardwareConfig == nil); err != nil {
+			return fmt.Errorf("unable to import hardware config: %w", err)
+		}
+
+		// the settings are replaced too, including those that aren't one of Settings' fields
+		if err := glowormBucket.DeleteBucket([]byte(bboltSettingsBucket)); err != nil {
+			return fmt.Errorf("unable to delete bucket %q: %w", bboltSettingsBucket, err)
+		}
+
+		settingsBucket, err := glowormBucket.CreateBucket([]byte(bboltSettingsBucket))
+		if err != nil {
+			return fmt.Errorf("unable to create bucket %q: %w", bboltSettingsBucket, err)
+		}
+
+		if err := bboltPutSettings(settingsBucket, backup.ExtraSettings); err != nil {
+			return fmt.Errorf("unable to import settings: %w", err)
+		}
+
+		if backup.Settings != nil {
+			values, err := settingsValues(*backup.Settings)
+			if err != nil {
+				return err
+			}
+
+			if err := bboltPutSettings(settingsBucket, values); err != nil {
+				return fmt.Errorf("unable to import settings: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to import configs: %w", err)
+	}
+
+	b.publish(importEvents(backup)...)
+
+	return nil
+}
+
+// bboltPutOrDelete puts the value as JSON at the key in the bucket, or deletes the key if
+// del is set.
+func bboltPutOrDelete(bucket *bbolt.Bucket, key string, v interface{}, del bool) error {
+	if del {
+		if err := bucket.Delete([]byte(key)); err != nil {
+			return fmt.Errorf("unable to delete %q: %w", key, err)
+		}
+
+		return nil
+	}
+
+	valueJSON, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("unable to marshal %q: %w", key, err)
+	}
+
+	if err := bucket.Put([]byte(key), valueJSON); err != nil {
+		return fmt.Errorf("unable to put %q: %w", key, err)
+	}
+
 	return nil
 }
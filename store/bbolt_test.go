@@ -0,0 +1,59 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go.etcd.io/bbolt"
+)
+
+// TestBBoltLegacySettings checks that settings stored as a whole under the legacy key are
+// moved into the settings bucket when the store is opened.
+func TestBBoltLegacySettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.db")
+
+	db, err := bbolt.Open(path, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucket([]byte(bboltGlowormBucket))
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(bboltLegacySettingsKey), []byte(`{"teamNumber":1234,"nickname":"front","ui":{"theme":"dark"}}`))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := OpenBBolt(path, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	settings, err := s.Settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if settings.TeamNumber != 1234 || settings.Nickname != "front" {
+		t.Errorf("settings are %+v, want the legacy settings", settings)
+	}
+
+	var ui map[string]string
+	if err := s.GetSetting("ui", &ui); err != nil {
+		t.Fatal(err)
+	}
+
+	if ui["theme"] != "dark" {
+		t.Errorf("setting %q is %v, want the legacy setting", "ui", ui)
+	}
+}
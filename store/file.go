@@ -0,0 +1,403 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gloworm-vision/gloworm-app/hardware"
+	"github.com/gloworm-vision/gloworm-app/pipeline"
+)
+
+// compile-time check for whether File satisfies the Store interface
+var _ Store = &File{}
+
+// filePollInterval is how often a File store checks whether its file was edited.
+const filePollInterval = time.Second
+
+// File is a Store that keeps every config in a human readable JSON file, so configs can be
+// edited offline, diffed in git and flashed onto images. The file is a Backup, the same as
+// exported from any store, that also has the pipeline config revisions. It's rewritten
+// atomically after every change. Edits made to the file while it's open are reloaded within
+// filePollInterval, and watchers receive external events for them. Edits that can't be read
+// are ignored until the file changes again.
+type File struct {
+	watchers
+
+	// mu serializes changes, so the file is written in the order they're made.
+	mu sync.Mutex
+
+	path   string
+	mode   os.FileMode
+	memory *Memory
+
+	// modTime and size are the file's as it was last read or written, to tell when it's
+	// edited.
+	modTime time.Time
+	size    int64
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// fileContents is what's kept in a store file. Backups don't have the pipeline config
+// revisions, so they're kept alongside, and the file can still be imported as a backup.
+type fileContents struct {
+	Backup
+
+	// Revisions are the revisions of each pipeline config, oldest first.
+	Revisions map[string][]Revision `json:"pipelineConfigRevisions,omitempty"`
+}
+
+// OpenFile opens the JSON store at the given path, creating it if it doesn't exist.
+func OpenFile(path string, mode os.FileMode) (Store, error) {
+	f := &File{path: path, mode: mode, memory: NewMemory(), stop: make(chan struct{})}
+
+	contents, err := f.read()
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if err := f.save(); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		if err := f.load(contents); err != nil {
+			return nil, fmt.Errorf("unable to load store file: %w", err)
+		}
+	}
+
+	go f.poll()
+
+	return f, nil
+}
+
+// Close stops checking whether the file was edited.
+func (f *File) Close() error {
+	f.once.Do(func() { close(f.stop) })
+
+	return nil
+}
+
+// Check checks that the file can be read, and that every config kept can be unmarshaled.
+// Unlike reloading it, the configs aren't replaced with those in the file.
+func (f *File) Check() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return fmt.Errorf("unable to read store file: %w", err)
+	}
+
+	var contents fileContents
+	if err := json.Unmarshal(data, &contents); err != nil {
+		return fmt.Errorf("%w: %s", ErrCorrupt, err)
+	}
+
+	if _, err := f.contents(); err != nil {
+		return fmt.Errorf("%w: %s", ErrCorrupt, err)
+	}
+
+	return nil
+}
+
+// Compact rewrites the file, which drops anything in it that isn't part of the store and
+// repairs it if it can't be read.
+func (f *File) Compact() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.save()
+}
+
+// read reads the file, and remembers its modification time and size.
+func (f *File) read() (fileContents, error) {
+	var contents fileContents
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return contents, fmt.Errorf("unable to stat store file: %w", err)
+	}
+	f.modTime, f.size = info.ModTime(), info.Size()
+
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return contents, fmt.Errorf("unable to read store file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &contents); err != nil {
+		return contents, fmt.Errorf("unable to unmarshal store file: %w", err)
+	}
+
+	return contents, nil
+}
+
+// load replaces everything in memory with the contents.
+func (f *File) load(contents fileContents) error {
+	if err := f.memory.Import(contents.Backup); err != nil {
+		return err
+	}
+
+	return f.memory.importRevisions(contents.Revisions)
+}
+
+// contents returns everything in memory.
+func (f *File) contents() (fileContents, error) {
+	backup, err := f.memory.Export()
+	if err != nil {
+		return fileContents{}, err
+	}
+
+	revisions, err := f.memory.exportRevisions()
+	if err != nil {
+		return fileContents{}, err
+	}
+
+	return fileContents{Backup: backup, Revisions: revisions}, nil
+}
+
+// poll reloads the file whenever it's edited, until the store is closed.
+func (f *File) poll() {
+	ticker := time.NewTicker(filePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+		}
+
+		f.reload()
+	}
+}
+
+// reload reloads the file if it was edited since it was last read or written, sending
+// external events for what changed.
+func (f *File) reload() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.path)
+	if err != nil || (info.ModTime().Equal(f.modTime) && info.Size() == f.size) {
+		return
+	}
+
+	after, err := f.read()
+	if err != nil {
+		return
+	}
+
+	before, err := f.contents()
+	if err != nil {
+		return
+	}
+
+	if err := f.load(after); err != nil {
+		return
+	}
+
+	events := diffEvents(before.Backup, after.Backup)
+	for i := range events {
+		events[i].External = true
+	}
+	f.publish(events...)
+}
+
+// update makes a change in memory and saves it to the file, undoing the change if it can't be
+// saved. The events are sent once it's saved.
+func (f *File) update(change func(m *Memory) error, events ...Event) error {
+	return f.updateEvents(func(m *Memory) ([]Event, error) { return events, change(m) })
+}
+
+// updateEvents is update for changes whose events depend on what's in memory when they're
+// made, so the change returns them.
+func (f *File) updateEvents(change func(m *Memory) ([]Event, error)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	before, err := f.contents()
+	if err != nil {
+		return err
+	}
+
+	events, err := change(f.memory)
+	if err != nil {
+		return err
+	}
+
+	if err := f.save(); err != nil {
+		if undoErr := f.load(before); undoErr != nil {
+			return fmt.Errorf("%s, and unable to undo the change: %w", err, undoErr)
+		}
+
+		return err
+	}
+
+	f.publish(events...)
+
+	return nil
+}
+
+// save writes every config to the file atomically, by writing them to a temporary file and
+// renaming it over the file, so a power cut can't leave it half written.
+func (f *File) save() error {
+	data, err := f.marshal()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), "."+filepath.Base(f.path)+".*")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary store file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write temporary store file: %w", err)
+	}
+
+	if err := os.Chmod(tmp.Name(), f.mode); err != nil {
+		return fmt.Errorf("unable to set store file mode: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("unable to replace store file: %w", err)
+	}
+
+	// the file's own changes aren't reloaded
+	if info, err := os.Stat(f.path); err == nil {
+		f.modTime, f.size = info.ModTime(), info.Size()
+	}
+
+	return nil
+}
+
+// marshal returns what's written to the file.
+func (f *File) marshal() ([]byte, error) {
+	contents, err := f.contents()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(contents, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal store file: %w", err)
+	}
+
+	return append(data, '\n'), nil
+}
+
+// WriteTo writes a copy of the store file to w, as it would be saved.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	f.mu.Lock()
+	data, err := f.marshal()
+	f.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(data)
+	if err != nil {
+		return int64(n), fmt.Errorf("unable to write store file copy: %w", err)
+	}
+
+	return int64(n), nil
+}
+
+func (f *File) PipelineConfig(name string) (pipeline.Config, error) {
+	return f.memory.PipelineConfig(name)
+}
+
+func (f *File) ListPipelineConfigs() ([]PipelineConfigEntry, error) {
+	return f.memory.ListPipelineConfigs()
+}
+
+func (f *File) PutPipelineConfig(name string, p pipeline.Config) error {
+	return f.update(func(m *Memory) error { return m.PutPipelineConfig(name, p) }, Event{Kind: EventPipelineConfig, Name: name})
+}
+
+func (f *File) PipelineConfigMetadata(name string) (PipelineConfigMetadata, error) {
+	return f.memory.PipelineConfigMetadata(name)
+}
+
+func (f *File) PutPipelineConfigMetadata(name string, metadata PipelineConfigMetadata) error {
+	return f.update(func(m *Memory) error { return m.PutPipelineConfigMetadata(name, metadata) }, Event{Kind: EventPipelineConfig, Name: name})
+}
+
+func (f *File) DeletePipelineConfig(name string) error {
+	return f.update(func(m *Memory) error { return m.DeletePipelineConfig(name) }, Event{Kind: EventPipelineConfig, Name: name, Deleted: true})
+}
+
+func (f *File) CopyPipelineConfig(from, to string) error {
+	return f.update(func(m *Memory) error { return m.CopyPipelineConfig(from, to) }, Event{Kind: EventPipelineConfig, Name: to})
+}
+
+func (f *File) RenamePipelineConfig(from, to string) error {
+	return f.updateEvents(func(m *Memory) ([]Event, error) {
+		renamedDefault, err := m.renamePipelineConfig(from, to)
+		return renameEvents(from, to, renamedDefault), err
+	})
+}
+
+func (f *File) PipelineConfigRevisions(name string) ([]Revision, error) {
+	return f.memory.PipelineConfigRevisions(name)
+}
+
+func (f *File) RestorePipelineConfig(name string, number int) error {
+	return f.update(func(m *Memory) error { return m.RestorePipelineConfig(name, number) }, Event{Kind: EventPipelineConfig, Name: name})
+}
+
+func (f *File) DefaultPipelineConfig() (string, error) {
+	return f.memory.DefaultPipelineConfig()
+}
+
+func (f *File) PutDefaultPipelineConfig(name string) error {
+	return f.update(func(m *Memory) error { return m.PutDefaultPipelineConfig(name) }, Event{Kind: EventDefaultPipelineConfig, Name: name})
+}
+
+func (f *File) HardwareConfig() (hardware.Config, error) {
+	return f.memory.HardwareConfig()
+}
+
+func (f *File) PutHardwareConfig(h hardware.Config) error {
+	return f.update(func(m *Memory) error { return m.PutHardwareConfig(h) }, Event{Kind: EventHardwareConfig})
+}
+
+func (f *File) Settings() (Settings, error) {
+	return f.memory.Settings()
+}
+
+func (f *File) PutSettings(settings Settings) error {
+	return f.update(func(m *Memory) error { return m.PutSettings(settings) }, Event{Kind: EventSettings})
+}
+
+func (f *File) GetSetting(key string, v interface{}) error {
+	return f.memory.GetSetting(key, v)
+}
+
+func (f *File) PutSetting(key string, v interface{}) error {
+	return f.update(func(m *Memory) error { return m.PutSetting(key, v) }, Event{Kind: EventSettings, Name: key})
+}
+
+func (f *File) Export() (Backup, error) {
+	return f.memory.Export()
+}
+
+func (f *File) Import(backup Backup) error {
+	return f.update(func(m *Memory) error { return m.Import(backup) }, importEvents(backup)...)
+}
@@ -0,0 +1,623 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gloworm-vision/gloworm-app/hardware"
+	"github.com/gloworm-vision/gloworm-app/pipeline"
+)
+
+// compile-time check for whether Memory satisfies the Store interface
+var _ Store = &Memory{}
+
+// Memory is a Store that keeps everything in memory, for tests and for demos where nothing
+// should persist. Like BBolt, values are stored as JSON, so callers never share them.
+type Memory struct {
+	watchers
+
+	mu sync.RWMutex
+
+	pipelineConfigs       map[string][]byte
+	revisions             map[string][]memoryRevision
+	metadata              map[string][]byte
+	defaultPipelineConfig string
+	hardwareConfig        []byte
+	settings              map[string]json.RawMessage
+}
+
+// NewMemory returns an empty in-memory store.
+func NewMemory() *Memory {
+	return &Memory{
+		pipelineConfigs: make(map[string][]byte),
+		revisions:       make(map[string][]memoryRevision),
+		metadata:        make(map[string][]byte),
+		settings:        make(map[string]json.RawMessage),
+	}
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+// Check does nothing, since every value is marshaled before it's kept.
+func (m *Memory) Check() error {
+	return nil
+}
+
+// Compact does nothing, since memory that's no longer used is garbage collected.
+func (m *Memory) Compact() error {
+	return nil
+}
+
+func (m *Memory) PipelineConfig(name string) (pipeline.Config, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var p pipeline.Config
+
+	pipelineJSON, ok := m.pipelineConfigs[name]
+	if !ok {
+		return p, fmt.Errorf("unable to get pipeline config %q: %w", name, ErrPipelineConfigNotExist)
+	}
+
+	if err := json.Unmarshal(pipelineJSON, &p); err != nil {
+		return p, fmt.Errorf("unable to unmarshal pipeline config JSON: %w", err)
+	}
+
+	return p, nil
+}
+
+func (m *Memory) ListPipelineConfigs() ([]PipelineConfigEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries := make([]PipelineConfigEntry, 0, len(m.pipelineConfigs))
+	for name := range m.pipelineConfigs {
+		entry := PipelineConfigEntry{Name: name}
+		if metadataJSON, ok := m.metadata[name]; ok {
+			if err := json.Unmarshal(metadataJSON, &entry.PipelineConfigMetadata); err != nil {
+				return nil, fmt.Errorf("unable to unmarshal pipeline config %q metadata JSON: %w", name, err)
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	// sorted, as BBolt lists them
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	return entries, nil
+}
+
+func (m *Memory) PutPipelineConfig(name string, p pipeline.Config) error {
+	pipelineJSON, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("unable to marshal pipeline config: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.replacePipelineConfig(name, pipelineJSON); err != nil {
+		return fmt.Errorf("unable to update pipeline config %q: %w", name, err)
+	}
+	m.publish(Event{Kind: EventPipelineConfig, Name: name})
+
+	return nil
+}
+
+func (m *Memory) PipelineConfigMetadata(name string) (PipelineConfigMetadata, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var metadata PipelineConfigMetadata
+
+	if _, ok := m.pipelineConfigs[name]; !ok {
+		return metadata, fmt.Errorf("unable to get pipeline config %q metadata: %w", name, ErrPipelineConfigNotExist)
+	}
+
+	if metadataJSON, ok := m.metadata[name]; ok {
+		if err := json.Unmarshal(metadataJSON, &metadata); err != nil {
+			return metadata, fmt.Errorf("unable to unmarshal pipeline config metadata JSON: %w", err)
+		}
+	}
+
+	return metadata, nil
+}
+
+func (m *Memory) PutPipelineConfigMetadata(name string, metadata PipelineConfigMetadata) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.pipelineConfigs[name]; !ok {
+		return fmt.Errorf("unable to update pipeline config %q metadata: %w", name, ErrPipelineConfigNotExist)
+	}
+
+	metadataJSON, err := putMetadata(m.metadata[name], metadata, time.Now())
+	if err != nil {
+		return fmt.Errorf("unable to update pipeline config %q metadata: %w", name, err)
+	}
+
+	m.metadata[name] = metadataJSON
+	m.publish(Event{Kind: EventPipelineConfig, Name: name})
+
+	return nil
+}
+
+func (m *Memory) DeletePipelineConfig(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.defaultPipelineConfig == name {
+		return fmt.Errorf("unable to delete pipeline config %q: %w", name, ErrDefaultPipelineConfig)
+	}
+
+	if _, ok := m.pipelineConfigs[name]; !ok {
+		return fmt.Errorf("unable to delete pipeline config %q: %w", name, ErrPipelineConfigNotExist)
+	}
+
+	delete(m.pipelineConfigs, name)
+	delete(m.revisions, name)
+	delete(m.metadata, name)
+	m.publish(Event{Kind: EventPipelineConfig, Name: name, Deleted: true})
+
+	return nil
+}
+
+func (m *Memory) CopyPipelineConfig(from, to string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.copyPipelineConfig(from, to); err != nil {
+		return fmt.Errorf("unable to copy pipeline config %q to %q: %w", from, to, err)
+	}
+
+	// the copy has the same description, author and tags, but is created now
+	metadataJSON, err := touchMetadata(m.metadata[from], time.Now(), true)
+	if err != nil {
+		delete(m.pipelineConfigs, to)
+		return fmt.Errorf("unable to copy pipeline config %q to %q: %w", from, to, err)
+	}
+	m.metadata[to] = metadataJSON
+	m.publish(Event{Kind: EventPipelineConfig, Name: to})
+
+	return nil
+}
+
+func (m *Memory) RenamePipelineConfig(from, to string) error {
+	_, err := m.renamePipelineConfig(from, to)
+	return err
+}
+
+// renamePipelineConfig renames a pipeline config, returning whether the default was renamed
+// too.
+func (m *Memory) renamePipelineConfig(from, to string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.copyPipelineConfig(from, to); err != nil {
+		return false, fmt.Errorf("unable to rename pipeline config %q to %q: %w", from, to, err)
+	}
+
+	delete(m.pipelineConfigs, from)
+
+	if revisions, ok := m.revisions[from]; ok {
+		m.revisions[to] = revisions
+		delete(m.revisions, from)
+	}
+
+	if metadataJSON, ok := m.metadata[from]; ok {
+		m.metadata[to] = metadataJSON
+		delete(m.metadata, from)
+	}
+
+	renamedDefault := m.defaultPipelineConfig == from
+	if renamedDefault {
+		m.defaultPipelineConfig = to
+	}
+	m.publish(renameEvents(from, to, renamedDefault)...)
+
+	return renamedDefault, nil
+}
+
+// copyPipelineConfig copies a pipeline config. m.mu must be held.
+func (m *Memory) copyPipelineConfig(from, to string) error {
+	pipelineJSON, ok := m.pipelineConfigs[from]
+	if !ok {
+		return ErrPipelineConfigNotExist
+	}
+
+	if _, ok := m.pipelineConfigs[to]; ok {
+		return ErrPipelineConfigExists
+	}
+
+	// stored values are never modified, so they can be shared
+	m.pipelineConfigs[to] = pipelineJSON
+
+	return nil
+}
+
+// memoryRevision is a pipeline config revision, with the config as JSON.
+type memoryRevision struct {
+	number       int
+	replaced     time.Time
+	pipelineJSON []byte
+}
+
+func (m *Memory) PipelineConfigRevisions(name string) ([]Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.pipelineConfigs[name]; !ok {
+		return nil, fmt.Errorf("unable to list pipeline config %q revisions: %w", name, ErrPipelineConfigNotExist)
+	}
+
+	stored := m.revisions[name]
+	revisions := make([]Revision, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		revision := Revision{Number: stored[i].number, Replaced: stored[i].replaced}
+		if err := json.Unmarshal(stored[i].pipelineJSON, &revision.Config); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal pipeline config revision JSON: %w", err)
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, nil
+}
+
+func (m *Memory) RestorePipelineConfig(name string, number int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.pipelineConfigs[name]; !ok {
+		return fmt.Errorf("unable to restore pipeline config %q revision %d: %w", name, number, ErrPipelineConfigNotExist)
+	}
+
+	for _, revision := range m.revisions[name] {
+		if revision.number == number {
+			if err := m.replacePipelineConfig(name, revision.pipelineJSON); err != nil {
+				return fmt.Errorf("unable to restore pipeline config %q revision %d: %w", name, number, err)
+			}
+			m.publish(Event{Kind: EventPipelineConfig, Name: name})
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unable to restore pipeline config %q revision %d: %w", name, number, ErrRevisionNotExist)
+}
+
+// replacePipelineConfig puts the pipeline config JSON unless it's unchanged, keeping the config
+// it replaces as the newest revision, discarding revisions beyond MaxPipelineConfigRevisions,
+// and updating its metadata's modified time. m.mu must be held.
+func (m *Memory) replacePipelineConfig(name string, pipelineJSON []byte) error {
+	old, ok := m.pipelineConfigs[name]
+	if ok && bytes.Equal(old, pipelineJSON) {
+		return nil
+	}
+
+	metadataJSON, err := touchMetadata(m.metadata[name], time.Now(), !ok)
+	if err != nil {
+		return err
+	}
+	m.metadata[name] = metadataJSON
+
+	if ok {
+		revisions := m.revisions[name]
+
+		number := 1
+		if len(revisions) > 0 {
+			number = revisions[len(revisions)-1].number + 1
+		}
+
+		revisions = append(revisions, memoryRevision{number: number, replaced: time.Now(), pipelineJSON: old})
+		if len(revisions) > MaxPipelineConfigRevisions {
+			revisions = append([]memoryRevision(nil), revisions[len(revisions)-MaxPipelineConfigRevisions:]...)
+		}
+
+		m.revisions[name] = revisions
+	}
+
+	m.pipelineConfigs[name] = pipelineJSON
+
+	return nil
+}
+
+// exportRevisions returns the revisions of every pipeline config, oldest first.
+func (m *Memory) exportRevisions() (map[string][]Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	all := make(map[string][]Revision, len(m.revisions))
+	for name, stored := range m.revisions {
+		revisions := make([]Revision, 0, len(stored))
+		for _, revision := range stored {
+			r := Revision{Number: revision.number, Replaced: revision.replaced}
+			if err := json.Unmarshal(revision.pipelineJSON, &r.Config); err != nil {
+				return nil, fmt.Errorf("unable to unmarshal pipeline config %q revision JSON: %w", name, err)
+			}
+
+			revisions = append(revisions, r)
+		}
+
+		all[name] = revisions
+	}
+
+	return all, nil
+}
+
+// importRevisions replaces the revisions of every pipeline config with those given. Revisions
+// of pipeline configs that don't exist are discarded.
+func (m *Memory) importRevisions(all map[string][]Revision) error {
+	revisions := make(map[string][]memoryRevision, len(all))
+	for name, given := range all {
+		stored := make([]memoryRevision, 0, len(given))
+		for _, revision := range given {
+			pipelineJSON, err := json.Marshal(revision.Config)
+			if err != nil {
+				return fmt.Errorf("unable to marshal pipeline config %q revision %d: %w", name, revision.Number, err)
+			}
+
+			stored = append(stored, memoryRevision{number: revision.Number, replaced: revision.Replaced, pipelineJSON: pipelineJSON})
+		}
+
+		// new revisions are numbered after the last, so they're kept in order
+		sort.Slice(stored, func(i, j int) bool { return stored[i].number < stored[j].number })
+		revisions[name] = stored
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name := range revisions {
+		if _, ok := m.pipelineConfigs[name]; !ok {
+			delete(revisions, name)
+		}
+	}
+	m.revisions = revisions
+
+	return nil
+}
+
+func (m *Memory) DefaultPipelineConfig() (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.defaultPipelineConfig, nil
+}
+
+func (m *Memory) PutDefaultPipelineConfig(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.defaultPipelineConfig = name
+	m.publish(Event{Kind: EventDefaultPipelineConfig, Name: name})
+
+	return nil
+}
+
+func (m *Memory) HardwareConfig() (hardware.Config, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var h hardware.Config
+
+	if m.hardwareConfig == nil {
+		return h, fmt.Errorf("unable to get hardware config: %w", ErrHardwareConfigNotExist)
+	}
+
+	if err := json.Unmarshal(m.hardwareConfig, &h); err != nil {
+		return h, fmt.Errorf("unable to unmarshal hardware config JSON: %w", err)
+	}
+
+	return h, nil
+}
+
+func (m *Memory) PutHardwareConfig(h hardware.Config) error {
+	hardwareJSON, err := json.Marshal(h)
+	if err != nil {
+		return fmt.Errorf("unable to marshal hardware config: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.hardwareConfig = hardwareJSON
+	m.publish(Event{Kind: EventHardwareConfig})
+
+	return nil
+}
+
+func (m *Memory) Settings() (Settings, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	settings, err := settingsFromValues(m.settings)
+	if err != nil {
+		return settings, fmt.Errorf("unable to get settings: %w", err)
+	}
+
+	return settings, nil
+}
+
+func (m *Memory) PutSettings(settings Settings) error {
+	values, err := settingsValues(settings)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, value := range values {
+		m.settings[key] = value
+	}
+	m.publish(Event{Kind: EventSettings})
+
+	return nil
+}
+
+func (m *Memory) GetSetting(key string, v interface{}) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	valueJSON, ok := m.settings[key]
+	if !ok {
+		return fmt.Errorf("unable to get setting %q: %w", key, ErrSettingNotExist)
+	}
+
+	if err := json.Unmarshal(valueJSON, v); err != nil {
+		return fmt.Errorf("unable to unmarshal setting %q JSON: %w", key, err)
+	}
+
+	return nil
+}
+
+func (m *Memory) PutSetting(key string, v interface{}) error {
+	if key == "" {
+		return errors.New("setting key must not be empty")
+	}
+
+	valueJSON, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("unable to marshal setting %q: %w", key, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := validateSetting(m.settings, key, valueJSON); err != nil {
+		return fmt.Errorf("unable to put setting %q: %w", key, err)
+	}
+
+	m.settings[key] = valueJSON
+	m.publish(Event{Kind: EventSettings, Name: key})
+
+	return nil
+}
+
+func (m *Memory) Export() (Backup, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	backup := Backup{
+		Version:               BackupVersion,
+		PipelineConfigs:       make(map[string]pipeline.Config, len(m.pipelineConfigs)),
+		DefaultPipelineConfig: m.defaultPipelineConfig,
+	}
+
+	for name, metadataJSON := range m.metadata {
+		var metadata PipelineConfigMetadata
+		if err := json.Unmarshal(metadataJSON, &metadata); err != nil {
+			return Backup{}, fmt.Errorf("unable to unmarshal pipeline config %q metadata JSON: %w", name, err)
+		}
+
+		if backup.PipelineConfigMetadata == nil {
+			backup.PipelineConfigMetadata = make(map[string]PipelineConfigMetadata, len(m.metadata))
+		}
+		backup.PipelineConfigMetadata[name] = metadata
+	}
+
+	for name, pipelineJSON := range m.pipelineConfigs {
+		var p pipeline.Config
+		if err := json.Unmarshal(pipelineJSON, &p); err != nil {
+			return Backup{}, fmt.Errorf("unable to unmarshal pipeline config %q JSON: %w", name, err)
+		}
+
+		backup.PipelineConfigs[name] = p
+	}
+
+	if m.hardwareConfig != nil {
+		backup.HardwareConfig = new(hardware.Config)
+		if err := json.Unmarshal(m.hardwareConfig, backup.HardwareConfig); err != nil {
+			return Backup{}, fmt.Errorf("unable to unmarshal hardware config JSON: %w", err)
+		}
+	}
+
+	var err error
+	backup.Settings, backup.ExtraSettings, err = splitSettings(m.settings)
+	if err != nil {
+		return Backup{}, fmt.Errorf("unable to export configs: %w", err)
+	}
+
+	return backup, nil
+}
+
+func (m *Memory) Import(backup Backup) error {
+	if backup.Version > BackupVersion {
+		return fmt.Errorf("backup is version %d, but only up to %d is supported: %w", backup.Version, BackupVersion, ErrBackupVersion)
+	}
+
+	if _, ok := backup.PipelineConfigs[backup.DefaultPipelineConfig]; backup.DefaultPipelineConfig != "" && !ok {
+		return fmt.Errorf("default pipeline config %q does not exist", backup.DefaultPipelineConfig)
+	}
+
+	// everything is marshaled before anything is replaced, so the import is atomic
+	pipelineConfigs := make(map[string][]byte, len(backup.PipelineConfigs))
+	for name, p := range backup.PipelineConfigs {
+		pipelineJSON, err := json.Marshal(p)
+		if err != nil {
+			return fmt.Errorf("unable to marshal pipeline config %q: %w", name, err)
+		}
+
+		pipelineConfigs[name] = pipelineJSON
+	}
+
+	metadata := make(map[string][]byte, len(backup.PipelineConfigMetadata))
+	for name, value := range backup.PipelineConfigMetadata {
+		if _, ok := backup.PipelineConfigs[name]; !ok {
+			continue
+		}
+
+		metadataJSON, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("unable to marshal pipeline config %q metadata: %w", name, err)
+		}
+
+		metadata[name] = metadataJSON
+	}
+
+	var hardwareJSON []byte
+	if backup.HardwareConfig != nil {
+		var err error
+		if hardwareJSON, err = json.Marshal(backup.HardwareConfig); err != nil {
+			return fmt.Errorf("unable to marshal hardware config: %w", err)
+		}
+	}
+
+	settings := make(map[string]json.RawMessage, len(backup.ExtraSettings))
+	for key, value := range backup.ExtraSettings {
+		settings[key] = append(json.RawMessage(nil), value...)
+	}
+
+	if backup.Settings != nil {
+		values, err := settingsValues(*backup.Settings)
+		if err != nil {
+			return err
+		}
+
+		for key, value := range values {
+			settings[key] = value
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pipelineConfigs = pipelineConfigs
+	m.revisions = make(map[string][]memoryRevision)
+	m.metadata = metadata
+	m.defaultPipelineConfig = backup.DefaultPipelineConfig
+	m.hardwareConfig = hardwareJSON
+	m.settings = settings
+	m.publish(importEvents(backup)...)
+
+	return nil
+}
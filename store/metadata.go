@@ -0,0 +1,105 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PipelineConfigMetadata describes a pipeline config, so teams can keep track of which config
+// is for which event or lighting.
+type PipelineConfigMetadata struct {
+	Description string   `json:"description,omitempty"`
+	Author      string   `json:"author,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+
+	// Created and Modified are when the pipeline config was created and last changed. They're
+	// set by the store, and ignored when metadata is put. They're zero for pipeline configs
+	// created before metadata was kept.
+	Created  time.Time `json:"created"`
+	Modified time.Time `json:"modified"`
+}
+
+// PipelineConfigEntry is a pipeline config's name and metadata, as it's listed.
+type PipelineConfigEntry struct {
+	Name string `json:"name"`
+
+	PipelineConfigMetadata
+}
+
+// Validate returns an error describing the first invalid tag, if any are.
+func (m PipelineConfigMetadata) Validate() error {
+	seen := make(map[string]bool, len(m.Tags))
+	for _, tag := range m.Tags {
+		if strings.TrimSpace(tag) == "" {
+			return errors.New("tags must not be empty")
+		}
+
+		if seen[tag] {
+			return fmt.Errorf("tag %q must not be repeated", tag)
+		}
+		seen[tag] = true
+	}
+
+	return nil
+}
+
+// HasTag returns whether the metadata has the tag.
+func (m PipelineConfigMetadata) HasTag(tag string) bool {
+	for _, t := range m.Tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// touchMetadata returns the metadata JSON of a pipeline config being changed at now, given
+// its current metadata JSON, which is nil if it has none. If it's being created, its created
+// time is set too.
+func touchMetadata(metadataJSON []byte, now time.Time, created bool) ([]byte, error) {
+	var metadata PipelineConfigMetadata
+	if metadataJSON != nil {
+		if err := json.Unmarshal(metadataJSON, &metadata); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal pipeline config metadata JSON: %w", err)
+		}
+	}
+
+	if created || metadata.Created.IsZero() {
+		metadata.Created = now
+	}
+	metadata.Modified = now
+
+	touched, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal pipeline config metadata: %w", err)
+	}
+
+	return touched, nil
+}
+
+// putMetadata returns the metadata JSON of a pipeline config after putting its metadata at now,
+// given its current metadata JSON, keeping its created time.
+func putMetadata(metadataJSON []byte, metadata PipelineConfigMetadata, now time.Time) ([]byte, error) {
+	var current PipelineConfigMetadata
+	if metadataJSON != nil {
+		if err := json.Unmarshal(metadataJSON, &current); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal pipeline config metadata JSON: %w", err)
+		}
+	}
+
+	metadata.Created, metadata.Modified = current.Created, now
+	if metadata.Created.IsZero() {
+		metadata.Created = now
+	}
+
+	put, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal pipeline config metadata: %w", err)
+	}
+
+	return put, nil
+}
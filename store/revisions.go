@@ -0,0 +1,28 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gloworm-vision/gloworm-app/pipeline"
+)
+
+// MaxPipelineConfigRevisions is the number of previous revisions kept of each pipeline config.
+// Older revisions are discarded.
+const MaxPipelineConfigRevisions = 20
+
+// ErrRevisionNotExist is returned when restoring a pipeline config revision that doesn't
+// exist, or was discarded.
+var ErrRevisionNotExist = errors.New("pipeline config revision does not exist")
+
+// Revision is a previous revision of a pipeline config, kept when the config was replaced.
+type Revision struct {
+	// Number counts up from 1 for each pipeline config, so revisions keep their numbers as
+	// older ones are discarded.
+	Number int `json:"number"`
+
+	// Replaced is when the config was replaced by the next revision.
+	Replaced time.Time `json:"replaced"`
+
+	Config pipeline.Config `json:"config"`
+}
@@ -0,0 +1,329 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/gloworm-vision/gloworm-app/network"
+	"github.com/sirupsen/logrus"
+)
+
+// NTMode is how the networktables client finds the server.
+type NTMode string
+
+const (
+	// NTModeTeam connects to the roboRIO of the team, at 10.TE.AM.2. It's the default.
+	NTModeTeam NTMode = "team"
+
+	// NTModeAddress connects to the NTAddress, for example a simulator on a laptop.
+	NTModeAddress NTMode = "address"
+)
+
+// ntPort is the port networktables servers listen on.
+const ntPort = "1735"
+
+// maxTeamNumber is the largest team number that fits in a 10.TE.AM.x address.
+const maxTeamNumber = 25599
+
+// Settings are the general settings of the device. The networktables settings are applied
+// when the server starts, the stream settings are applied to new stream clients, and the
+// logging settings are applied immediately.
+type Settings struct {
+	TeamNumber int    `json:"teamNumber"`
+	NTMode     NTMode `json:"ntMode"`
+
+	// NTAddress is the host (and optionally port) of the networktables server in
+	// NTModeAddress.
+	NTAddress string `json:"ntAddress"`
+
+	// Hostname is the hostname the device is reachable at, which is set when the settings are
+	// put if the server manages the network, and Nickname is a friendlier name that's shown
+	// in the UI and used as the networktables client identity.
+	Hostname string `json:"hostname"`
+	Nickname string `json:"nickname"`
+
+	Stream  StreamSettings  `json:"stream"`
+	Logging LoggingSettings `json:"logging"`
+	Match   MatchSettings   `json:"match"`
+}
+
+// StreamSettings are the defaults for the MJPEG streams.
+type StreamSettings struct {
+	// FPS is the frame rate clients are limited to when they don't ask for one, or 0 for
+	// the capture's frame rate.
+	FPS float64 `json:"fps"`
+
+	// Quality is the JPEG quality of the main stream from 1 to 100, or 0 for OpenCV's
+	// default (95).
+	Quality int `json:"quality"`
+}
+
+// LoggingSettings control what's logged, and how.
+type LoggingSettings struct {
+	// Level is the least severe level logged, such as "debug" or "warning", or empty for
+	// "info".
+	Level string `json:"level"`
+
+	// JSON enables logging an object per line instead of text, for log collectors.
+	JSON bool `json:"json"`
+}
+
+// MatchSettings switch the active pipeline as a match moves between phases, such as to an
+// auto-aim pipeline during autonomous and a driver pipeline during the endgame. Each is the
+// name of the pipeline config to switch to when the phase starts, or empty to leave the
+// active pipeline as it is.
+type MatchSettings struct {
+	Disabled string `json:"disabled"`
+	Auto     string `json:"auto"`
+	Teleop   string `json:"teleop"`
+	Endgame  string `json:"endgame"`
+
+	// EndgameStart is how many seconds into teleop the endgame starts, or 0 for 105 (with
+	// 30 seconds left in a 135 second teleop).
+	EndgameStart float64 `json:"endgameStart"`
+}
+
+// Validate returns an error describing the first invalid setting, if any are.
+func (s Settings) Validate() error {
+	switch s.NTMode {
+	case "", NTModeTeam:
+	case NTModeAddress:
+		if s.NTAddress == "" {
+			return errors.New("ntAddress is required in address mode")
+		}
+	default:
+		return fmt.Errorf("unknown ntMode %q", s.NTMode)
+	}
+
+	if err := validateTeamNumber(s.TeamNumber); err != nil {
+		return err
+	}
+
+	if s.Hostname != "" && !network.ValidHostname(s.Hostname) {
+		return fmt.Errorf("invalid hostname %q", s.Hostname)
+	}
+
+	if err := s.Stream.Validate(); err != nil {
+		return err
+	}
+
+	if s.Logging.Level != "" {
+		if _, err := logrus.ParseLevel(s.Logging.Level); err != nil {
+			return err
+		}
+	}
+
+	if s.Match.EndgameStart < 0 {
+		return errors.New("match endgameStart must not be negative")
+	}
+
+	return nil
+}
+
+// validateTeamNumber returns an error if the team number doesn't fit in an address.
+func validateTeamNumber(team int) error {
+	if team < 0 || team > maxTeamNumber {
+		return fmt.Errorf("teamNumber must be from 0 to %d", maxTeamNumber)
+	}
+
+	return nil
+}
+
+// Validate returns an error describing the first invalid stream setting, if any are.
+func (s StreamSettings) Validate() error {
+	if s.FPS < 0 {
+		return errors.New("stream fps must not be negative")
+	}
+
+	if s.Quality < 0 || s.Quality > 100 {
+		return errors.New("stream quality must be from 0 to 100")
+	}
+
+	return nil
+}
+
+// NTServerAddr returns the address of the networktables server, or an empty string if
+// it's unknown because no team number is set.
+func (s Settings) NTServerAddr() string {
+	if s.NTMode == NTModeAddress {
+		if _, _, err := net.SplitHostPort(s.NTAddress); err != nil {
+			return net.JoinHostPort(s.NTAddress, ntPort)
+		}
+
+		return s.NTAddress
+	}
+
+	if s.TeamNumber == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("10.%d.%d.2:%s", s.TeamNumber/100, s.TeamNumber%100, ntPort)
+}
+
+// Keys of the settings that have typed helpers. Each of Settings' fields is stored as a
+// setting under its JSON name, so they're the same settings as in Settings.
+const (
+	SettingTeamNumber = "teamNumber"
+	SettingNTAddress  = "ntAddress"
+	SettingNickname   = "nickname"
+	SettingStream     = "stream"
+)
+
+// TeamNumber returns the team number setting, or 0 if it isn't set.
+func TeamNumber(s Store) (int, error) {
+	var team int
+	return team, getSetting(s, SettingTeamNumber, &team)
+}
+
+// PutTeamNumber sets the team number setting.
+func PutTeamNumber(s Store, team int) error {
+	if err := validateTeamNumber(team); err != nil {
+		return err
+	}
+
+	return s.PutSetting(SettingTeamNumber, team)
+}
+
+// NTAddress returns the networktables address setting, or an empty string if it isn't set.
+func NTAddress(s Store) (string, error) {
+	var address string
+	return address, getSetting(s, SettingNTAddress, &address)
+}
+
+// PutNTAddress sets the networktables address setting.
+func PutNTAddress(s Store, address string) error {
+	return s.PutSetting(SettingNTAddress, address)
+}
+
+// Nickname returns the device nickname setting, or an empty string if it isn't set.
+func Nickname(s Store) (string, error) {
+	var nickname string
+	return nickname, getSetting(s, SettingNickname, &nickname)
+}
+
+// PutNickname sets the device nickname setting.
+func PutNickname(s Store, nickname string) error {
+	return s.PutSetting(SettingNickname, nickname)
+}
+
+// StreamDefaults returns the stream settings, which are the zero value if they aren't set.
+func StreamDefaults(s Store) (StreamSettings, error) {
+	var stream StreamSettings
+	return stream, getSetting(s, SettingStream, &stream)
+}
+
+// PutStreamDefaults sets the stream settings.
+func PutStreamDefaults(s Store, stream StreamSettings) error {
+	if err := stream.Validate(); err != nil {
+		return err
+	}
+
+	return s.PutSetting(SettingStream, stream)
+}
+
+// getSetting gets the setting into v, leaving it unchanged if the setting isn't set.
+func getSetting(s Store, key string, v interface{}) error {
+	if err := s.GetSetting(key, v); err != nil && !errors.Is(err, ErrSettingNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// settingsFromValues returns the Settings made up of the setting values by key. Settings
+// that aren't one of its fields are ignored.
+func settingsFromValues(values map[string]json.RawMessage) (Settings, error) {
+	var settings Settings
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return settings, fmt.Errorf("unable to marshal settings: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return settings, fmt.Errorf("unable to unmarshal settings: %w", err)
+	}
+
+	return settings, nil
+}
+
+// validateSetting returns an error if putting the setting's JSON value into the setting
+// values would make Settings invalid. Settings that aren't fields of Settings aren't checked.
+func validateSetting(values map[string]json.RawMessage, key string, valueJSON json.RawMessage) error {
+	fields, err := settingsValues(Settings{})
+	if err != nil {
+		return err
+	}
+
+	if _, ok := fields[key]; !ok {
+		return nil
+	}
+
+	merged := make(map[string]json.RawMessage, len(values)+1)
+	for k, v := range values {
+		merged[k] = v
+	}
+	merged[key] = valueJSON
+
+	settings, err := settingsFromValues(merged)
+	if err != nil {
+		return err
+	}
+
+	if err := settings.Validate(); err != nil {
+		return fmt.Errorf("invalid settings: %w", err)
+	}
+
+	return nil
+}
+
+// settingsValues splits the settings into a setting value for each of its fields.
+func settingsValues(settings Settings) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal settings: %w", err)
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal settings: %w", err)
+	}
+
+	return values, nil
+}
+
+// splitSettings splits the setting values into Settings, which is nil if none of its fields
+// are set, and the other settings, which are nil if there aren't any.
+func splitSettings(values map[string]json.RawMessage) (*Settings, map[string]json.RawMessage, error) {
+	fields, err := settingsValues(Settings{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var hasFields bool
+	var extra map[string]json.RawMessage
+	for key, value := range values {
+		if _, ok := fields[key]; ok {
+			hasFields = true
+			continue
+		}
+
+		if extra == nil {
+			extra = make(map[string]json.RawMessage)
+		}
+		extra[key] = value
+	}
+
+	if !hasFields {
+		return nil, extra, nil
+	}
+
+	settings, err := settingsFromValues(values)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &settings, extra, nil
+}
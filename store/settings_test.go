@@ -0,0 +1,60 @@
+package store
+
+import (
+	"testing"
+)
+
+func TestSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		valid    bool
+	}{
+		{"zero value", Settings{}, true},
+		{"team", Settings{TeamNumber: 1234, NTMode: NTModeTeam}, true},
+		{"address", Settings{NTMode: NTModeAddress, NTAddress: "10.0.0.5"}, true},
+		{"address mode without address", Settings{NTMode: NTModeAddress}, false},
+		{"unknown mode", Settings{NTMode: "broadcast"}, false},
+		{"negative team", Settings{TeamNumber: -1}, false},
+		{"team too large", Settings{TeamNumber: maxTeamNumber + 1}, false},
+		{"hostname", Settings{Hostname: "gloworm-1"}, true},
+		{"invalid hostname", Settings{Hostname: "glo worm"}, false},
+		{"stream", Settings{Stream: StreamSettings{FPS: 30, Quality: 100}}, true},
+		{"negative stream fps", Settings{Stream: StreamSettings{FPS: -1}}, false},
+		{"stream quality too high", Settings{Stream: StreamSettings{Quality: 101}}, false},
+		{"log level", Settings{Logging: LoggingSettings{Level: "debug"}}, true},
+		{"unknown log level", Settings{Logging: LoggingSettings{Level: "loud"}}, false},
+		{"negative endgame start", Settings{Match: MatchSettings{EndgameStart: -1}}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.settings.Validate()
+			if valid := err == nil; valid != test.valid {
+				t.Errorf("valid is %t (%v), want %t", valid, err, test.valid)
+			}
+		})
+	}
+}
+
+func TestNTServerAddr(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		want     string
+	}{
+		{"no team", Settings{}, ""},
+		{"team", Settings{TeamNumber: 1234}, "10.12.34.2:1735"},
+		{"short team", Settings{TeamNumber: 7}, "10.0.7.2:1735"},
+		{"address", Settings{NTMode: NTModeAddress, NTAddress: "localhost"}, "localhost:1735"},
+		{"address with port", Settings{NTMode: NTModeAddress, NTAddress: "localhost:5810"}, "localhost:5810"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.settings.NTServerAddr(); got != test.want {
+				t.Errorf("address is %q, want %q", got, test.want)
+			}
+		})
+	}
+}
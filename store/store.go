@@ -1,23 +1,115 @@
 package store
 
 import (
+	"errors"
 	"io"
 
 	"github.com/gloworm-vision/gloworm-app/hardware"
 	"github.com/gloworm-vision/gloworm-app/pipeline"
 )
 
+// ErrPipelineConfigNotExist is returned when a pipeline config doesn't exist.
+var ErrPipelineConfigNotExist = errors.New("pipeline config does not exist")
+
+// ErrHardwareConfigNotExist is returned when no hardware config is set.
+var ErrHardwareConfigNotExist = errors.New("hardware config does not exist")
+
+// ErrDefaultPipelineConfig is returned when deleting the default pipeline config.
+var ErrDefaultPipelineConfig = errors.New("pipeline config is the default")
+
+// ErrPipelineConfigExists is returned when copying or renaming a pipeline config to a name
+// that's already used.
+var ErrPipelineConfigExists = errors.New("pipeline config already exists")
+
+// ErrSettingNotExist is returned when getting a setting that isn't set.
+var ErrSettingNotExist = errors.New("setting does not exist")
+
+// ErrCorrupt is returned when checking a store that's corrupt.
+var ErrCorrupt = errors.New("store is corrupt")
+
 // Store describes a persistent storage engine for gloworm-app information.
 type Store interface {
 	PipelineConfig(name string) (pipeline.Config, error)
-	ListPipelineConfigs() ([]string, error)
+
+	// ListPipelineConfigs lists the name and metadata of every pipeline config, sorted by
+	// name.
+	ListPipelineConfigs() ([]PipelineConfigEntry, error)
+
+	// PutPipelineConfig puts the named pipeline config, setting its metadata's created time
+	// if it's new and its modified time if it changed.
 	PutPipelineConfig(name string, p pipeline.Config) error
 
+	// PipelineConfigMetadata returns the named pipeline config's metadata, returning an error
+	// wrapping ErrPipelineConfigNotExist if it doesn't exist.
+	PipelineConfigMetadata(name string) (PipelineConfigMetadata, error)
+
+	// PutPipelineConfigMetadata replaces the named pipeline config's description, author and
+	// tags, returning an error wrapping ErrPipelineConfigNotExist if it doesn't exist.
+	PutPipelineConfigMetadata(name string, m PipelineConfigMetadata) error
+
+	// DeletePipelineConfig deletes the named pipeline config, returning an error wrapping
+	// ErrDefaultPipelineConfig if it's the default.
+	DeletePipelineConfig(name string) error
+
+	// CopyPipelineConfig copies the pipeline config named from, and its metadata, to a new
+	// pipeline config named to, returning an error wrapping ErrPipelineConfigExists if it
+	// already exists.
+	CopyPipelineConfig(from, to string) error
+
+	// RenamePipelineConfig renames the pipeline config named from, and its metadata, to a new
+	// name, returning an error wrapping ErrPipelineConfigExists if it already exists. If it's
+	// the default, the default is renamed too.
+	RenamePipelineConfig(from, to string) error
+
+	// PipelineConfigRevisions returns the previous revisions of the named pipeline config,
+	// newest first. A revision is kept whenever the config is replaced with a different one,
+	// and they're deleted and renamed with it.
+	PipelineConfigRevisions(name string) ([]Revision, error)
+
+	// RestorePipelineConfig replaces the named pipeline config with one of its previous
+	// revisions, returning an error wrapping ErrRevisionNotExist if it isn't kept. The
+	// replaced config is kept as a revision, so restoring can be undone too.
+	RestorePipelineConfig(name string, number int) error
+
 	DefaultPipelineConfig() (string, error)
 	PutDefaultPipelineConfig(name string) error
 
 	HardwareConfig() (hardware.Config, error)
 	PutHardwareConfig(h hardware.Config) error
 
+	// Settings returns the device settings, which are the zero value if they haven't been
+	// put.
+	Settings() (Settings, error)
+	PutSettings(settings Settings) error
+
+	// GetSetting unmarshals the JSON value of the setting with the key into v, returning an
+	// error wrapping ErrSettingNotExist if it isn't set. Settings' fields are settings keyed
+	// by their JSON names, and other settings can be added without changing the interface.
+	GetSetting(key string, v interface{}) error
+
+	// PutSetting puts the JSON value of the setting with the key, returning an error if it's
+	// one of Settings' fields and would make the settings invalid.
+	PutSetting(key string, v interface{}) error
+
+	// Export returns every config in the store, as a backup of the current BackupVersion.
+	Export() (Backup, error)
+
+	// Watch returns a channel that receives an event for each change to the store, and a
+	// function that stops watching. Events are dropped for watchers that fall behind.
+	Watch() (<-chan Event, func())
+
+	// Import atomically replaces every config in the store with those in the backup,
+	// returning an error wrapping ErrBackupVersion if it's from a newer version of the format.
+	// Pipeline config revisions aren't backed up, so they're discarded.
+	Import(b Backup) error
+
+	// Check checks the store's integrity, returning an error wrapping ErrCorrupt if it's
+	// corrupt, such as after an unclean shutdown.
+	Check() error
+
+	// Compact reclaims space the store no longer uses, such as free pages accumulated by a
+	// long-lived database.
+	Compact() error
+
 	io.Closer
 }
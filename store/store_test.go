@@ -0,0 +1,566 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gloworm-vision/gloworm-app/hardware"
+	"github.com/gloworm-vision/gloworm-app/pipeline"
+)
+
+func TestMemory(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		return NewMemory()
+	})
+}
+
+func TestFile(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		s, err := OpenFile(filepath.Join(t.TempDir(), "store.json"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return s
+	})
+}
+
+func TestBBolt(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		s, err := OpenBBolt(filepath.Join(t.TempDir(), "store.db"), 0666, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return s
+	})
+}
+
+// storeTests are the behavior every Store has, each run against an empty store.
+var storeTests = []struct {
+	name string
+	test func(t *testing.T, s Store)
+}{
+	{"pipeline configs", testPipelineConfigs},
+	{"metadata", testMetadata},
+	{"revisions", testRevisions},
+	{"copy", testCopy},
+	{"rename", testRename},
+	{"delete", testDelete},
+	{"settings", testSettings},
+	{"export and import", testExportImport},
+	{"import errors", testImportErrors},
+	{"watch", testWatch},
+}
+
+// testStore runs every store test against a new store from open, which is closed after.
+func testStore(t *testing.T, open func(t *testing.T) Store) {
+	for _, test := range storeTests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			s := open(t)
+			defer s.Close()
+
+			test.test(t, s)
+		})
+	}
+}
+
+// testConfig returns a valid pipeline config, which differs for each min contour.
+func testConfig(minContour float64) pipeline.Config {
+	return pipeline.Config{
+		SchemaVersion: pipeline.CurrentSchemaVersion,
+		Type:          pipeline.TypeReflective,
+		ColorSpace:    pipeline.ColorSpaceHSV,
+		SortMode:      pipeline.SortLargest,
+		MaxTargets:    1,
+		MinThresh:     pipeline.HSV{H: 50, S: 100, V: 100},
+		MaxThresh:     pipeline.HSV{H: 70, S: 255, V: 255},
+		MinContour:    minContour,
+		MaxContour:    1,
+	}
+}
+
+// mustPut puts the pipeline configs, failing the test if they can't be put.
+func mustPut(t *testing.T, s Store, configs map[string]pipeline.Config) {
+	t.Helper()
+
+	for name, config := range configs {
+		if err := s.PutPipelineConfig(name, config); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// checkConfig checks that the named pipeline config is the one given.
+func checkConfig(t *testing.T, s Store, name string, want pipeline.Config) {
+	t.Helper()
+
+	got, err := s.PipelineConfig(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !equalJSON(got, want) {
+		t.Errorf("pipeline config %q is %+v, want %+v", name, got, want)
+	}
+}
+
+// checkErr checks that err wraps target.
+func checkErr(t *testing.T, err, target error) {
+	t.Helper()
+
+	if !errors.Is(err, target) {
+		t.Errorf("error is %v, want %v", err, target)
+	}
+}
+
+func testPipelineConfigs(t *testing.T, s Store) {
+	_, err := s.PipelineConfig("missing")
+	checkErr(t, err, ErrPipelineConfigNotExist)
+
+	mustPut(t, s, map[string]pipeline.Config{"b": testConfig(0.2), "a": testConfig(0.1)})
+	checkConfig(t, s, "a", testConfig(0.1))
+	checkConfig(t, s, "b", testConfig(0.2))
+
+	entries, err := s.ListPipelineConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+
+		if entry.Created.IsZero() || entry.Modified.IsZero() {
+			t.Errorf("pipeline config %q created or modified time isn't set", entry.Name)
+		}
+	}
+
+	if want := []string{"a", "b"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("pipeline configs are %v, want %v", names, want)
+	}
+}
+
+func testMetadata(t *testing.T, s Store) {
+	err := s.PutPipelineConfigMetadata("missing", PipelineConfigMetadata{})
+	checkErr(t, err, ErrPipelineConfigNotExist)
+
+	mustPut(t, s, map[string]pipeline.Config{"a": testConfig(0.1)})
+
+	created, err := s.PipelineConfigMetadata("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	put := PipelineConfigMetadata{Description: "practice field", Author: "jo", Tags: []string{"week 1"}}
+	if err := s.PutPipelineConfigMetadata("a", put); err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, err := s.PipelineConfigMetadata("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if metadata.Description != put.Description || metadata.Author != put.Author || !reflect.DeepEqual(metadata.Tags, put.Tags) {
+		t.Errorf("metadata is %+v, want %+v", metadata, put)
+	}
+
+	if !metadata.Created.Equal(created.Created) {
+		t.Errorf("created time changed from %s to %s", created.Created, metadata.Created)
+	}
+}
+
+func testRevisions(t *testing.T, s Store) {
+	// each put after the first keeps the replaced config, so one more than are kept are made
+	puts := MaxPipelineConfigRevisions + 2
+	for i := 1; i <= puts; i++ {
+		mustPut(t, s, map[string]pipeline.Config{"a": testConfig(float64(i) / 100)})
+	}
+
+	// putting the same config again isn't a revision
+	mustPut(t, s, map[string]pipeline.Config{"a": testConfig(float64(puts) / 100)})
+
+	revisions, err := s.PipelineConfigRevisions("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(revisions) != MaxPipelineConfigRevisions {
+		t.Fatalf("%d revisions are kept, want %d", len(revisions), MaxPipelineConfigRevisions)
+	}
+
+	for i, revision := range revisions {
+		// newest first, and the oldest revision (number 1) was discarded
+		number := puts - 1 - i
+		if revision.Number != number {
+			t.Errorf("revision %d is number %d, want %d", i, revision.Number, number)
+		}
+
+		if want := testConfig(float64(number) / 100); !equalJSON(revision.Config, want) {
+			t.Errorf("revision %d config is %+v, want %+v", number, revision.Config, want)
+		}
+	}
+
+	checkErr(t, s.RestorePipelineConfig("a", 1), ErrRevisionNotExist)
+	checkErr(t, s.RestorePipelineConfig("missing", 2), ErrPipelineConfigNotExist)
+
+	if err := s.RestorePipelineConfig("a", 2); err != nil {
+		t.Fatal(err)
+	}
+	checkConfig(t, s, "a", testConfig(0.02))
+
+	// restoring is a revision too, so it can be undone
+	revisions, err = s.PipelineConfigRevisions("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if revisions[0].Number != puts || !equalJSON(revisions[0].Config, testConfig(float64(puts)/100)) {
+		t.Errorf("newest revision is number %d with %+v, want the restored config", revisions[0].Number, revisions[0].Config)
+	}
+}
+
+func testCopy(t *testing.T, s Store) {
+	mustPut(t, s, map[string]pipeline.Config{"a": testConfig(0.1), "b": testConfig(0.2)})
+	mustPut(t, s, map[string]pipeline.Config{"a": testConfig(0.15)})
+
+	if err := s.PutPipelineConfigMetadata("a", PipelineConfigMetadata{Description: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	checkErr(t, s.CopyPipelineConfig("a", "b"), ErrPipelineConfigExists)
+	checkErr(t, s.CopyPipelineConfig("missing", "c"), ErrPipelineConfigNotExist)
+
+	if err := s.CopyPipelineConfig("a", "c"); err != nil {
+		t.Fatal(err)
+	}
+	checkConfig(t, s, "a", testConfig(0.15))
+	checkConfig(t, s, "c", testConfig(0.15))
+
+	metadata, err := s.PipelineConfigMetadata("c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if metadata.Description != "a" {
+		t.Errorf("copy's description is %q, want %q", metadata.Description, "a")
+	}
+
+	// the copy is a new config, without the revisions of the one it was copied from
+	revisions, err := s.PipelineConfigRevisions("c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(revisions) != 0 {
+		t.Errorf("copy has %d revisions, want none", len(revisions))
+	}
+}
+
+func testRename(t *testing.T, s Store) {
+	mustPut(t, s, map[string]pipeline.Config{"a": testConfig(0.1), "b": testConfig(0.2)})
+	mustPut(t, s, map[string]pipeline.Config{"a": testConfig(0.15)})
+
+	if err := s.PutPipelineConfigMetadata("a", PipelineConfigMetadata{Description: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.PutDefaultPipelineConfig("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	checkErr(t, s.RenamePipelineConfig("a", "b"), ErrPipelineConfigExists)
+	checkErr(t, s.RenamePipelineConfig("missing", "c"), ErrPipelineConfigNotExist)
+
+	if err := s.RenamePipelineConfig("a", "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := s.PipelineConfig("a")
+	checkErr(t, err, ErrPipelineConfigNotExist)
+	checkConfig(t, s, "c", testConfig(0.15))
+
+	def, err := s.DefaultPipelineConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if def != "c" {
+		t.Errorf("default pipeline config is %q, want the renamed %q", def, "c")
+	}
+
+	metadata, err := s.PipelineConfigMetadata("c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if metadata.Description != "a" {
+		t.Errorf("renamed description is %q, want %q", metadata.Description, "a")
+	}
+
+	revisions, err := s.PipelineConfigRevisions("c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(revisions) != 1 || !equalJSON(revisions[0].Config, testConfig(0.1)) {
+		t.Errorf("renamed revisions are %+v, want the one of %q", revisions, "a")
+	}
+}
+
+func testDelete(t *testing.T, s Store) {
+	mustPut(t, s, map[string]pipeline.Config{"a": testConfig(0.1), "b": testConfig(0.2)})
+	mustPut(t, s, map[string]pipeline.Config{"b": testConfig(0.25)})
+
+	if err := s.PutDefaultPipelineConfig("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	checkErr(t, s.DeletePipelineConfig("a"), ErrDefaultPipelineConfig)
+	checkErr(t, s.DeletePipelineConfig("missing"), ErrPipelineConfigNotExist)
+
+	if err := s.DeletePipelineConfig("b"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := s.PipelineConfig("b")
+	checkErr(t, err, ErrPipelineConfigNotExist)
+
+	// a new config with the same name doesn't get the deleted one's revisions
+	mustPut(t, s, map[string]pipeline.Config{"b": testConfig(0.3)})
+
+	revisions, err := s.PipelineConfigRevisions("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(revisions) != 0 {
+		t.Errorf("recreated config has %d revisions, want none", len(revisions))
+	}
+}
+
+func testSettings(t *testing.T, s Store) {
+	settings, err := s.Settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(settings, Settings{}) {
+		t.Errorf("settings are %+v before they're put, want the zero value", settings)
+	}
+
+	var team int
+	checkErr(t, s.GetSetting(SettingTeamNumber, &team), ErrSettingNotExist)
+
+	put := Settings{TeamNumber: 1234, Nickname: "front", Stream: StreamSettings{Quality: 80}}
+	if err := s.PutSettings(put); err != nil {
+		t.Fatal(err)
+	}
+
+	if settings, err = s.Settings(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(settings, put) {
+		t.Errorf("settings are %+v, want %+v", settings, put)
+	}
+
+	// Settings' fields are settings themselves
+	if team, err := TeamNumber(s); err != nil || team != 1234 {
+		t.Errorf("team number is %d (%v), want 1234", team, err)
+	}
+
+	if err := PutNickname(s, "back"); err != nil {
+		t.Fatal(err)
+	}
+
+	if settings, err = s.Settings(); err != nil {
+		t.Fatal(err)
+	}
+
+	if settings.Nickname != "back" {
+		t.Errorf("nickname is %q, want %q", settings.Nickname, "back")
+	}
+
+	// settings that would make Settings invalid aren't put
+	for key, value := range map[string]interface{}{
+		SettingTeamNumber: maxTeamNumber + 1,
+		"ntMode":          NTModeAddress,
+		SettingStream:     StreamSettings{Quality: 101},
+	} {
+		if err := s.PutSetting(key, value); err == nil {
+			t.Errorf("setting %q to %v isn't an error", key, value)
+		}
+	}
+
+	if settings, err = s.Settings(); err != nil {
+		t.Fatal(err)
+	}
+
+	if settings.TeamNumber != 1234 || settings.NTMode != "" || settings.Stream.Quality != 80 {
+		t.Errorf("settings are %+v after putting invalid settings, want them unchanged", settings)
+	}
+
+	// settings that aren't fields of Settings can be anything
+	extra := map[string]string{"theme": "dark"}
+	if err := s.PutSetting("ui", extra); err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]string
+	if err := s.GetSetting("ui", &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, extra) {
+		t.Errorf("setting %q is %v, want %v", "ui", got, extra)
+	}
+}
+
+func testExportImport(t *testing.T, s Store) {
+	mustPut(t, s, map[string]pipeline.Config{"old": testConfig(0.1)})
+	mustPut(t, s, map[string]pipeline.Config{"a": testConfig(0.2)})
+	mustPut(t, s, map[string]pipeline.Config{"a": testConfig(0.25)})
+
+	backup := Backup{
+		Version: BackupVersion,
+		PipelineConfigs: map[string]pipeline.Config{
+			"a": testConfig(0.3),
+			"b": testConfig(0.4),
+		},
+		DefaultPipelineConfig: "b",
+		PipelineConfigMetadata: map[string]PipelineConfigMetadata{
+			"b": {Description: "b", Tags: []string{"competition"}},
+		},
+		HardwareConfig: &hardware.Config{},
+		Settings:       &Settings{TeamNumber: 1234, Stream: StreamSettings{FPS: 15}},
+		ExtraSettings:  map[string]json.RawMessage{"ui": json.RawMessage(`{"theme":"dark"}`)},
+	}
+
+	if err := s.Import(backup); err != nil {
+		t.Fatal(err)
+	}
+
+	exported, err := s.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !equalJSON(exported, backup) {
+		got, _ := json.Marshal(exported)
+		want, _ := json.Marshal(backup)
+		t.Errorf("exported backup is\n%s\nwant\n%s", got, want)
+	}
+
+	// everything is replaced, and revisions aren't backed up
+	_, err = s.PipelineConfig("old")
+	checkErr(t, err, ErrPipelineConfigNotExist)
+
+	revisions, err := s.PipelineConfigRevisions("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(revisions) != 0 {
+		t.Errorf("imported config has %d revisions, want none", len(revisions))
+	}
+
+	// and an empty backup clears the store
+	if err := s.Import(Backup{Version: BackupVersion}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.HardwareConfig(); !errors.Is(err, ErrHardwareConfigNotExist) {
+		t.Errorf("hardware config error is %v after importing an empty backup, want %v", err, ErrHardwareConfigNotExist)
+	}
+
+	var ui json.RawMessage
+	checkErr(t, s.GetSetting("ui", &ui), ErrSettingNotExist)
+}
+
+func testImportErrors(t *testing.T, s Store) {
+	mustPut(t, s, map[string]pipeline.Config{"a": testConfig(0.1)})
+
+	checkErr(t, s.Import(Backup{Version: BackupVersion + 1}), ErrBackupVersion)
+
+	err := s.Import(Backup{
+		Version:               BackupVersion,
+		PipelineConfigs:       map[string]pipeline.Config{"b": testConfig(0.2)},
+		DefaultPipelineConfig: "missing",
+	})
+	if err == nil {
+		t.Error("importing a backup with a missing default pipeline config isn't an error")
+	}
+
+	// failed imports don't change anything
+	checkConfig(t, s, "a", testConfig(0.1))
+}
+
+func testWatch(t *testing.T, s Store) {
+	mustPut(t, s, map[string]pipeline.Config{"a": testConfig(0.1), "b": testConfig(0.2)})
+
+	if err := s.PutDefaultPipelineConfig("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	events, stop := s.Watch()
+	defer stop()
+
+	tests := []struct {
+		name   string
+		change func() error
+		want   []Event
+	}{
+		{
+			name:   "put",
+			change: func() error { return s.PutPipelineConfig("b", testConfig(0.25)) },
+			want:   []Event{{Kind: EventPipelineConfig, Name: "b"}},
+		},
+		{
+			name:   "rename",
+			change: func() error { return s.RenamePipelineConfig("b", "c") },
+			want:   renameEvents("b", "c", false),
+		},
+		{
+			name:   "rename default",
+			change: func() error { return s.RenamePipelineConfig("a", "d") },
+			want:   renameEvents("a", "d", true),
+		},
+		{
+			name:   "delete",
+			change: func() error { return s.DeletePipelineConfig("c") },
+			want:   []Event{{Kind: EventPipelineConfig, Name: "c", Deleted: true}},
+		},
+		{
+			name:   "setting",
+			change: func() error { return PutTeamNumber(s, 1234) },
+			want:   []Event{{Kind: EventSettings, Name: SettingTeamNumber}},
+		},
+		{
+			name:   "failed change",
+			change: func() error { return s.DeletePipelineConfig("missing") },
+		},
+	}
+
+	for _, test := range tests {
+		// failed changes are expected to fail
+		if err := test.change(); err != nil && test.want != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		// events are sent before the change returns
+		var got []Event
+		for len(events) > 0 {
+			got = append(got, <-events)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s events are %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// EventKind is what changed in a store.
+type EventKind string
+
+const (
+	// EventPipelineConfig is a pipeline config being put, copied, renamed or deleted, or its
+	// metadata being put.
+	EventPipelineConfig EventKind = "pipeline"
+
+	EventDefaultPipelineConfig EventKind = "default"
+	EventHardwareConfig        EventKind = "hardware"
+	EventSettings              EventKind = "settings"
+)
+
+// watchBuffer is the number of events buffered for each watcher. Events are dropped for
+// watchers that fall further behind.
+const watchBuffer = 64
+
+// Event is a change to a store.
+type Event struct {
+	Kind EventKind `json:"kind"`
+
+	// Name is the pipeline config that changed, the new default pipeline config, or the key
+	// of the setting that changed. It's empty if every pipeline config or setting may have
+	// changed, such as after importing a backup.
+	Name string `json:"name,omitempty"`
+
+	// Deleted is set if the pipeline config was deleted (or renamed from).
+	Deleted bool `json:"deleted,omitempty"`
+
+	// External is set if the change was made outside of the store, such as by editing the
+	// file a File store is kept in, so whoever is using the store didn't make it.
+	External bool `json:"external,omitempty"`
+}
+
+// watchers fans out a store's events to its watchers. The zero value is ready to use.
+type watchers struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+// Watch returns a channel that receives the store's events once they've been made, and a
+// function that stops watching.
+func (w *watchers) Watch() (<-chan Event, func()) {
+	ch := make(chan Event, watchBuffer)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.subscribers == nil {
+		w.subscribers = make(map[chan Event]struct{})
+	}
+	w.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		delete(w.subscribers, ch)
+	}
+}
+
+// publish sends the events to every watcher without blocking.
+func (w *watchers) publish(events ...Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for ch := range w.subscribers {
+		for _, e := range events {
+			select {
+			case ch <- e:
+			default:
+			}
+		}
+	}
+}
+
+// importEvents are the events of importing a backup, which may have changed everything.
+func importEvents(backup Backup) []Event {
+	return []Event{
+		{Kind: EventPipelineConfig},
+		{Kind: EventDefaultPipelineConfig, Name: backup.DefaultPipelineConfig},
+		{Kind: EventHardwareConfig},
+		{Kind: EventSettings},
+	}
+}
+
+// renameEvents are the events of renaming a pipeline config, which renames the default too if
+// it was the default.
+func renameEvents(from, to string, renamedDefault bool) []Event {
+	events := []Event{
+		{Kind: EventPipelineConfig, Name: from, Deleted: true},
+		{Kind: EventPipelineConfig, Name: to},
+	}
+	if renamedDefault {
+		events = append(events, Event{Kind: EventDefaultPipelineConfig, Name: to})
+	}
+
+	return events
+}
+
+// diffEvents are the events of the changes from one backup to another.
+func diffEvents(before, after Backup) []Event {
+	var events []Event
+
+	for name, config := range after.PipelineConfigs {
+		old, ok := before.PipelineConfigs[name]
+		if !ok || !equalJSON(old, config) || !equalJSON(before.PipelineConfigMetadata[name], after.PipelineConfigMetadata[name]) {
+			events = append(events, Event{Kind: EventPipelineConfig, Name: name})
+		}
+	}
+	for name := range before.PipelineConfigs {
+		if _, ok := after.PipelineConfigs[name]; !ok {
+			events = append(events, Event{Kind: EventPipelineConfig, Name: name, Deleted: true})
+		}
+	}
+
+	if before.DefaultPipelineConfig != after.DefaultPipelineConfig {
+		events = append(events, Event{Kind: EventDefaultPipelineConfig, Name: after.DefaultPipelineConfig})
+	}
+
+	if !equalJSON(before.HardwareConfig, after.HardwareConfig) {
+		events = append(events, Event{Kind: EventHardwareConfig})
+	}
+
+	if !equalJSON(before.Settings, after.Settings) || !equalJSON(before.ExtraSettings, after.ExtraSettings) {
+		events = append(events, Event{Kind: EventSettings})
+	}
+
+	return events
+}
+
+// equalJSON returns whether the values marshal to the same JSON. Values that can't be
+// marshaled are never equal.
+func equalJSON(a, b interface{}) bool {
+	aJSON, aErr := json.Marshal(a)
+	bJSON, bErr := json.Marshal(b)
+
+	return aErr == nil && bErr == nil && bytes.Equal(aJSON, bJSON)
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/gloworm-vision/gloworm-app/hardware"
+	"github.com/gloworm-vision/gloworm-app/pipeline"
+)
+
+func TestDiffEvents(t *testing.T) {
+	before := Backup{
+		PipelineConfigs: map[string]pipeline.Config{
+			"a": testConfig(0.1),
+			"b": testConfig(0.2),
+		},
+		DefaultPipelineConfig: "a",
+		Settings:              &Settings{TeamNumber: 1234},
+	}
+
+	tests := []struct {
+		name   string
+		change func(after *Backup)
+		want   []Event
+	}{
+		{
+			name:   "unchanged",
+			change: func(after *Backup) {},
+		},
+		{
+			name:   "pipeline config put",
+			change: func(after *Backup) { after.PipelineConfigs["b"] = testConfig(0.25) },
+			want:   []Event{{Kind: EventPipelineConfig, Name: "b"}},
+		},
+		{
+			name:   "pipeline config created",
+			change: func(after *Backup) { after.PipelineConfigs["c"] = testConfig(0.3) },
+			want:   []Event{{Kind: EventPipelineConfig, Name: "c"}},
+		},
+		{
+			name:   "pipeline config deleted",
+			change: func(after *Backup) { delete(after.PipelineConfigs, "b") },
+			want:   []Event{{Kind: EventPipelineConfig, Name: "b", Deleted: true}},
+		},
+		{
+			name: "metadata put",
+			change: func(after *Backup) {
+				after.PipelineConfigMetadata = map[string]PipelineConfigMetadata{"a": {Description: "a"}}
+			},
+			want: []Event{{Kind: EventPipelineConfig, Name: "a"}},
+		},
+		{
+			name:   "default",
+			change: func(after *Backup) { after.DefaultPipelineConfig = "b" },
+			want:   []Event{{Kind: EventDefaultPipelineConfig, Name: "b"}},
+		},
+		{
+			name:   "hardware config",
+			change: func(after *Backup) { after.HardwareConfig = &hardware.Config{} },
+			want:   []Event{{Kind: EventHardwareConfig}},
+		},
+		{
+			name:   "settings",
+			change: func(after *Backup) { after.Settings = &Settings{TeamNumber: 4321} },
+			want:   []Event{{Kind: EventSettings}},
+		},
+		{
+			name:   "extra settings",
+			change: func(after *Backup) { after.ExtraSettings = map[string]json.RawMessage{"ui": json.RawMessage(`{}`)} },
+			want:   []Event{{Kind: EventSettings}},
+		},
+		{
+			name: "renamed",
+			change: func(after *Backup) {
+				after.PipelineConfigs["c"] = after.PipelineConfigs["a"]
+				delete(after.PipelineConfigs, "a")
+				after.DefaultPipelineConfig = "c"
+			},
+			want: []Event{
+				{Kind: EventPipelineConfig, Name: "a", Deleted: true},
+				{Kind: EventPipelineConfig, Name: "c"},
+				{Kind: EventDefaultPipelineConfig, Name: "c"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			after := before
+			after.PipelineConfigs = make(map[string]pipeline.Config, len(before.PipelineConfigs))
+			for name, config := range before.PipelineConfigs {
+				after.PipelineConfigs[name] = config
+			}
+			test.change(&after)
+
+			got := diffEvents(before, after)
+
+			// pipeline config events come first, in map order
+			configs := 0
+			for configs < len(got) && got[configs].Kind == EventPipelineConfig {
+				configs++
+			}
+			sort.Slice(got[:configs], func(i, j int) bool { return got[i].Name < got[j].Name })
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("events are %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package system
+
+import (
+	"fmt"
+	"syscall"
+)
+
+func readDisk(path string) (Disk, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return Disk{}, fmt.Errorf("unable to stat filesystem of %q: %w", path, err)
+	}
+
+	return Disk{
+		Path:  path,
+		Total: stat.Blocks * uint64(stat.Bsize),
+		Free:  stat.Bavail * uint64(stat.Bsize),
+	}, nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package system
+
+import "errors"
+
+func readDisk(path string) (Disk, error) {
+	return Disk{}, errors.New("disk usage is only supported on linux")
+}
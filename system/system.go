@@ -0,0 +1,280 @@
+// Package system reads the state of the device gloworm-app runs on, such as CPU usage and
+// temperature, so problems like overheating or a full disk are visible before they cause
+// dropped frames. Stats are read from Linux's procfs and sysfs, and from vcgencmd on
+// Raspberry Pis, and are omitted where they're unavailable.
+package system
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// vcgencmdTimeout is how long vcgencmd has to report the throttling state.
+const vcgencmdTimeout = time.Second
+
+// Stats are the state of the device. Stats that couldn't be read are nil.
+type Stats struct {
+	// CPUUsage is the fraction of time the CPUs were busy since the stats were last read.
+	CPUUsage *float64 `json:"cpuUsage,omitempty"`
+
+	// Load is the 1, 5 and 15 minute load averages.
+	Load *[3]float64 `json:"load,omitempty"`
+
+	Memory *Memory `json:"memory,omitempty"`
+	Disk   *Disk   `json:"disk,omitempty"`
+
+	// Temperature is the CPU temperature in degrees Celsius.
+	Temperature *float64 `json:"temperature,omitempty"`
+
+	// Throttled is the Raspberry Pi's throttling state.
+	Throttled *Throttled `json:"throttled,omitempty"`
+}
+
+// Memory is the device's memory usage, in bytes.
+type Memory struct {
+	Total     uint64 `json:"total"`
+	Available uint64 `json:"available"`
+}
+
+// Disk is the usage of the filesystem containing a path, in bytes.
+type Disk struct {
+	Path  string `json:"path"`
+	Total uint64 `json:"total"`
+	Free  uint64 `json:"free"`
+}
+
+// Throttled is the Raspberry Pi's throttling state, as reported by vcgencmd get_throttled.
+// The current state is reported along with whether each condition has occurred since boot.
+type Throttled struct {
+	UnderVoltage    bool `json:"underVoltage"`
+	FrequencyCapped bool `json:"frequencyCapped"`
+	Throttled       bool `json:"throttled"`
+	SoftTempLimit   bool `json:"softTempLimit"`
+
+	UnderVoltageOccurred    bool `json:"underVoltageOccurred"`
+	FrequencyCappedOccurred bool `json:"frequencyCappedOccurred"`
+	ThrottledOccurred       bool `json:"throttledOccurred"`
+	SoftTempLimitOccurred   bool `json:"softTempLimitOccurred"`
+}
+
+// Monitor reads the device's stats. CPU usage is measured between reads, so a Monitor
+// should be reused.
+type Monitor struct {
+	// DiskPath is a path on the filesystem whose usage is reported. It defaults to "/".
+	DiskPath string
+
+	mu             sync.Mutex
+	lastBusy       uint64
+	lastTotal      uint64
+	hasLastCPUTime bool
+}
+
+// Stats reads the device's stats.
+func (m *Monitor) Stats(ctx context.Context) Stats {
+	var stats Stats
+
+	if usage, err := m.cpuUsage(); err == nil {
+		stats.CPUUsage = &usage
+	}
+
+	if load, err := readLoad(); err == nil {
+		stats.Load = &load
+	}
+
+	if memory, err := readMemory(); err == nil {
+		stats.Memory = &memory
+	}
+
+	path := m.DiskPath
+	if path == "" {
+		path = "/"
+	}
+	if disk, err := readDisk(path); err == nil {
+		stats.Disk = &disk
+	}
+
+	if temperature, err := readTemperature(); err == nil {
+		stats.Temperature = &temperature
+	}
+
+	if throttled, err := readThrottled(ctx); err == nil {
+		stats.Throttled = &throttled
+	}
+
+	return stats
+}
+
+// cpuUsage returns the fraction of time the CPUs were busy since it was last called, or
+// since boot the first time.
+func (m *Monitor) cpuUsage() (float64, error) {
+	busy, total, err := readCPUTime()
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deltaBusy, deltaTotal := busy, total
+	if m.hasLastCPUTime {
+		deltaBusy, deltaTotal = busy-m.lastBusy, total-m.lastTotal
+	}
+	m.lastBusy, m.lastTotal, m.hasLastCPUTime = busy, total, true
+
+	if deltaTotal == 0 {
+		return 0, nil
+	}
+
+	return float64(deltaBusy) / float64(deltaTotal), nil
+}
+
+// readCPUTime returns the busy and total time spent by all CPUs since boot, in clock ticks.
+func readCPUTime() (busy, total uint64, err error) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to open /proc/stat: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+
+		// user nice system idle iowait irq softirq steal ...
+		for i, field := range fields[1:] {
+			v, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return 0, 0, fmt.Errorf("unable to parse cpu time %q: %w", field, err)
+			}
+
+			total += v
+			if i != 3 && i != 4 { // idle and iowait
+				busy += v
+			}
+		}
+
+		return busy, total, nil
+	}
+
+	return 0, 0, fmt.Errorf("no cpu line in /proc/stat")
+}
+
+func readLoad() ([3]float64, error) {
+	var load [3]float64
+
+	data, err := ioutil.ReadFile("/proc/loadavg")
+	if err != nil {
+		return load, fmt.Errorf("unable to read /proc/loadavg: %w", err)
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return load, fmt.Errorf("unexpected /proc/loadavg format %q", data)
+	}
+
+	for i := range load {
+		if load[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return load, fmt.Errorf("unable to parse load %q: %w", fields[i], err)
+		}
+	}
+
+	return load, nil
+}
+
+func readMemory() (Memory, error) {
+	var memory Memory
+
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return memory, fmt.Errorf("unable to open /proc/meminfo: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// for example, "MemTotal:        3884328 kB"
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		var dest *uint64
+		switch fields[0] {
+		case "MemTotal:":
+			dest = &memory.Total
+		case "MemAvailable:":
+			dest = &memory.Available
+		default:
+			continue
+		}
+
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return memory, fmt.Errorf("unable to parse %s %q: %w", fields[0], fields[1], err)
+		}
+		*dest = kb * 1024
+	}
+
+	if memory.Total == 0 {
+		return memory, fmt.Errorf("no MemTotal in /proc/meminfo")
+	}
+
+	return memory, nil
+}
+
+func readTemperature() (float64, error) {
+	data, err := ioutil.ReadFile("/sys/class/thermal/thermal_zone0/temp")
+	if err != nil {
+		return 0, fmt.Errorf("unable to read temperature: %w", err)
+	}
+
+	// the temperature is in millidegrees
+	milli, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse temperature %q: %w", data, err)
+	}
+
+	return milli / 1000, nil
+}
+
+func readThrottled(ctx context.Context) (Throttled, error) {
+	ctx, cancel := context.WithTimeout(ctx, vcgencmdTimeout)
+	defer cancel()
+
+	// for example, "throttled=0x50000"
+	out, err := exec.CommandContext(ctx, "vcgencmd", "get_throttled").Output()
+	if err != nil {
+		return Throttled{}, fmt.Errorf("unable to run vcgencmd: %w", err)
+	}
+
+	raw := strings.TrimPrefix(strings.TrimSpace(string(out)), "throttled=")
+	bits, err := strconv.ParseUint(raw, 0, 32)
+	if err != nil {
+		return Throttled{}, fmt.Errorf("unable to parse throttled state %q: %w", out, err)
+	}
+
+	bit := func(n uint) bool { return bits&(1<<n) != 0 }
+
+	return Throttled{
+		UnderVoltage:    bit(0),
+		FrequencyCapped: bit(1),
+		Throttled:       bit(2),
+		SoftTempLimit:   bit(3),
+
+		UnderVoltageOccurred:    bit(16),
+		FrequencyCappedOccurred: bit(17),
+		ThrottledOccurred:       bit(18),
+		SoftTempLimitOccurred:   bit(19),
+	}, nil
+}
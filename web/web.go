@@ -0,0 +1,22 @@
+// Package web bundles the built web UI into the binary, so it can be served by the vision
+// server without any other files. The UI build outputs to the dist directory.
+package web
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+)
+
+//go:embed dist
+var dist embed.FS
+
+// UI returns the files of the built web UI.
+func UI() (fs.FS, error) {
+	ui, err := fs.Sub(dist, "dist")
+	if err != nil {
+		return nil, fmt.Errorf("unable to open web UI: %w", err)
+	}
+
+	return ui, nil
+}